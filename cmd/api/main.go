@@ -2,55 +2,191 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+	"github.com/andrimuhayat/crud-test/internal/graphql"
+	"github.com/andrimuhayat/crud-test/internal/grpcapi"
 	"github.com/andrimuhayat/crud-test/internal/handler"
+	v1 "github.com/andrimuhayat/crud-test/internal/handler/v1"
 	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/repository/eventsourced"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
 	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
 	"github.com/andrimuhayat/crud-test/internal/usecase"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
 	// --- Dependency wiring (composition root) ---
-	bookRepo := memory.NewBookRepository()
-	bookUC := usecase.NewBookUseCase(bookRepo)
+	coverDir := envOr("COVER_STORAGE_DIR", "./data/covers")
+	blobStore, err := filesystem.NewBlobStore(coverDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	coverRepo, err := filesystem.NewCoverRepository(filepath.Join(coverDir, "meta"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auditRepo, err := filesystem.NewAuditRepository(envOr("AUDIT_LOG_PATH", "./data/audit.log"))
+	if err != nil {
+		log.Fatalf("audit log: %v (run cmd/auditverify to inspect it)", err)
+	}
+
+	webhookRepo, err := filesystem.NewWebhookRepository(envOr("WEBHOOK_STORE_DIR", "./data/webhooks"))
+	if err != nil {
+		log.Fatalf("webhook store: %v", err)
+	}
+
+	bookRepo, err := newBookRepository()
+	if err != nil {
+		log.Fatalf("book store: %v", err)
+	}
+	reviewRepo := memory.NewReviewRepository()
+	shelfRepo := memory.NewShelfRepository()
+	revisionRepo := memory.NewRevisionRepository()
+	broker := eventbus.NewBroker(eventbus.DefaultReplaySize, eventbus.DefaultSubscriberSize)
+	shelfUC := usecase.NewShelfUseCase(shelfRepo, bookRepo)
+	reviewUC := usecase.NewReviewUseCase(reviewRepo, bookRepo)
+	bookUC := usecase.NewBookUseCase(bookRepo, revisionRepo, shelfUC.Cascade(), coverRepo, reviewUC.Cascade())
+	coverUC := usecase.NewCoverUseCase(coverRepo, bookRepo, blobStore)
+
+	retention, err := time.ParseDuration(envOr("TRASH_RETENTION", "720h"))
+	if err != nil {
+		log.Fatalf("TRASH_RETENTION: %v", err)
+	}
+	purgeInterval, err := time.ParseDuration(envOr("TRASH_PURGE_INTERVAL", "1h"))
+	if err != nil {
+		log.Fatalf("TRASH_PURGE_INTERVAL: %v", err)
+	}
+	allowPrivateWebhooks, err := strconv.ParseBool(envOr("WEBHOOK_ALLOW_PRIVATE_TARGETS", "false"))
+	if err != nil {
+		log.Fatalf("WEBHOOK_ALLOW_PRIVATE_TARGETS: %v", err)
+	}
+	webhookUC := usecase.NewWebhookUseCase(webhookRepo, webhookRepo)
+	webhookUC.AllowPrivateTargets = allowPrivateWebhooks
+	webhookCfg := usecase.DefaultWebhookDispatcherConfig()
+	webhookCfg.AllowPrivateTargets = allowPrivateWebhooks
+	dispatcher := usecase.NewWebhookDispatcher(webhookRepo, webhookRepo, webhookCfg)
+	go dispatcher.Run(context.Background())
+
+	// Book events are written to the repository's outbox with each change and
+	// relayed to the broker and the webhook queue from there.
+	go usecase.NewOutboxRelay(bookRepo, broker, time.Second, dispatcher).Run(context.Background())
+	go usecase.NewTrashPurger(bookUC, retention, purgeInterval).Run(context.Background())
 	authUC := usecase.NewAuthUseCase()
+	auditUC := usecase.NewAuditUseCase(auditRepo)
+	idempotencyTTL, err := time.ParseDuration(envOr("IDEMPOTENCY_TTL", "24h"))
+	if err != nil {
+		log.Fatalf("IDEMPOTENCY_TTL: %v", err)
+	}
+	idempotencyUC := usecase.NewIdempotencyUseCase(memory.NewIdempotencyRepository(), idempotencyTTL)
+	formats := serializer.Default()
+	go idempotencyUC.Run(context.Background(), 10*time.Minute)
 
-	pingH := handler.NewPingHandler()
-	echoH := handler.NewEchoHandler()
-	authH := handler.NewAuthHandler(authUC)
-	bookH := handler.NewBookHandler(bookUC)
+	routes := handler.Routes{
+		Authenticated: []fiber.Handler{middleware.Auth(authUC), middleware.Audit(auditUC)},
+		Idempotency:   middleware.Idempotency(idempotencyUC, formats),
+		WSAuth:        []fiber.Handler{middleware.WebSocketAuth(authUC), middleware.Audit(auditUC)},
+		Auth:          handler.NewAuthHandler(authUC),
+		Book:          handler.NewBookHandler(bookUC, reviewUC, bookUC, v1.BookAdapter{}, formats),
+		Review:        handler.NewReviewHandler(reviewUC),
+		Shelf:         handler.NewShelfHandler(shelfUC),
+		Cover:         handler.NewCoverHandler(coverUC),
+		Revision:      handler.NewRevisionHandler(bookUC, v1.BookAdapter{}),
+		Audit:         handler.NewAuditHandler(auditUC),
+		Event:         handler.NewEventHandler(broker),
+		WS:            handler.NewWSHandler(broker, handler.DefaultWSTimeouts()),
+		Webhook:       handler.NewWebhookHandler(webhookUC),
+		GraphQL:       handler.NewGraphQLHandler(graphql.NewServer(bookUC, reviewUC, graphql.DefaultLimits())),
+	}
 
 	// --- Fiber app ---
 	app := fiber.New(fiber.Config{
-		// Return errors as JSON instead of plain text.
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{"error": err.Error()})
-		},
+		// Leave room for a full-size cover upload plus multipart overhead.
+		BodyLimit: domain.MaxCoverSize + 1<<20,
+		// Params, query values and headers are otherwise views into fasthttp's
+		// reusable buffers; repositories keep IDs taken from them as map keys.
+		Immutable: true,
+		// Return errors as problem details instead of plain text.
+		ErrorHandler: problem.ErrorHandler,
 	})
 
-	app.Use(logger.New())
+	// The request ID is echoed in X-Request-ID, logged and included in every
+	// problem response so that a client report can be matched to the logs.
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:requestid} | ${error}\n",
+	}))
 	app.Use(recover.New())
 
-	// --- Public routes ---
-	app.Get("/ping", pingH.Ping)
-	app.Post("/echo", echoH.Echo)
-	app.Post("/auth/token", authH.GenerateToken)
+	// --- Routes ---
+	// Service routes, /v1, its deprecated unversioned aliases and GraphQL.
+	routes.Register(app)
 
-	// --- Protected book routes (Level 5 — JWT required) ---
-	books := app.Group("/books", middleware.Auth(authUC))
-	books.Post("/", bookH.CreateBook)
-	books.Get("/", bookH.GetBooks)
-	books.Get("/:id", bookH.GetBook)
-	books.Put("/:id", bookH.UpdateBook)
-	books.Delete("/:id", bookH.DeleteBook)
+	// --- gRPC ---
+	// BookService listens on a port of its own, next to the Fiber app.
+	grpcLis, err := net.Listen("tcp", envOr("GRPC_ADDR", ":9090"))
+	if err != nil {
+		log.Fatalf("grpc: %v", err)
+	}
+	grpcServer := grpcapi.NewServer(bookUC, broker, authUC)
+	go func() {
+		log.Fatal(grpcServer.Serve(grpcLis))
+	}()
 
 	log.Fatal(app.Listen(":8080"))
 }
+
+// envOr returns the value of the environment variable key, or fallback when unset.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// bookStore is a book repository that also exposes its event outbox.
+type bookStore interface {
+	domain.BookRepository
+	domain.BookOutbox
+}
+
+// newBookRepository selects the book repository named by BOOK_STORE:
+// "memory" (default), "sharded", which spreads books over BOOK_SHARDS
+// independently locked shards, or "eventsourced", which keeps an append-only
+// event stream and snapshots in BOOK_EVENT_DIR.
+func newBookRepository() (bookStore, error) {
+	switch kind := envOr("BOOK_STORE", "memory"); kind {
+	case "memory":
+		return memory.NewBookRepository(), nil
+	case "sharded":
+		shards, err := strconv.Atoi(envOr("BOOK_SHARDS", strconv.Itoa(memory.DefaultBookShards)))
+		if err != nil || shards < 1 {
+			return nil, fmt.Errorf("BOOK_SHARDS must be a positive number")
+		}
+		return memory.NewShardedBookRepository(shards), nil
+	case "eventsourced":
+		store, err := eventsourced.NewFileStore(envOr("BOOK_EVENT_DIR", "./data/books"))
+		if err != nil {
+			return nil, err
+		}
+		return eventsourced.NewBookRepository(store, eventsourced.DefaultSnapshotEvery)
+	default:
+		return nil, fmt.Errorf("unknown BOOK_STORE %q (want memory, sharded or eventsourced)", kind)
+	}
+}
@@ -0,0 +1,34 @@
+// Command auditverify checks the hash chain of an audit log file written by
+// the API and exits non-zero if any entry was altered, removed or reordered.
+//
+// Usage:
+//
+//	go run ./cmd/auditverify -file ./data/audit.log
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+func main() {
+	path := flag.String("file", "./data/audit.log", "path to the audit log")
+	flag.Parse()
+
+	entries, err := filesystem.ReadAuditLog(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TAMPERED or unreadable: %v\n", err)
+		os.Exit(1)
+	}
+
+	v := usecase.VerifyAuditChain(entries)
+	if !v.Valid {
+		fmt.Fprintf(os.Stderr, "TAMPERED: entry %d: %s\n", v.FirstInvalidSeq, v.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("OK: %d entries, last hash %s\n", v.Entries, v.LastHash)
+}
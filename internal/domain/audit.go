@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+// Audit outcomes derived from the HTTP status of the audited request.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records one authenticated request. Entries form a hash chain:
+// Hash is the SHA-256 of PrevHash and the entry's other fields, and PrevHash
+// is the Hash of the entry with the previous Seq, so altering, removing or
+// reordering any entry breaks every hash after it.
+type AuditEntry struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Subject  string    `json:"subject"`
+	Action   string    `json:"action"`
+	BookID   string    `json:"book_id,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Status   int       `json:"status"`
+	ClientIP string    `json:"client_ip"`
+	PrevHash string    `json:"prev_hash"`
+	Hash     string    `json:"hash"`
+}
+
+// AuditFilter selects audit entries. Zero-valued fields match everything.
+type AuditFilter struct {
+	Subject string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// AuditVerification is the result of checking the audit hash chain.
+// When Valid is false, FirstInvalidSeq and Reason describe the first break.
+type AuditVerification struct {
+	Valid           bool   `json:"valid"`
+	Entries         int    `json:"entries"`
+	LastHash        string `json:"last_hash,omitempty"`
+	FirstInvalidSeq uint64 `json:"first_invalid_seq,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// AuditRepository defines the persistence contract for the audit log.
+// It is append-only: there is no way to modify or remove an entry.
+// Implementations must be safe for concurrent use.
+type AuditRepository interface {
+	Append(entry *AuditEntry) error
+	// Last returns the newest entry, or ErrNotFound when the log is empty.
+	Last() (*AuditEntry, error)
+	// All returns every entry in Seq order.
+	All() ([]*AuditEntry, error)
+}
+
+// AuditUseCase defines the business-logic contract for the audit log.
+type AuditUseCase interface {
+	// Record chains and appends an entry; Seq, PrevHash and Hash are assigned.
+	Record(entry AuditEntry) error
+	// Query returns matching entries, newest first.
+	Query(filter AuditFilter) ([]*AuditEntry, error)
+	Verify() (*AuditVerification, error)
+}
@@ -1,10 +1,14 @@
 package domain
 
+import "time"
+
 // AuthUseCase defines the business-logic contract for authentication.
 type AuthUseCase interface {
 	// GenerateToken validates credentials and returns a signed JWT.
 	GenerateToken(username, password string) (string, error)
 	// ValidateToken parses and validates a JWT, returning the subject claim.
 	ValidateToken(token string) (string, error)
+	// TokenExpiry validates a JWT and returns when it expires, or the zero
+	// time if it never does.
+	TokenExpiry(token string) (time.Time, error)
 }
-
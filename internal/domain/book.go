@@ -3,37 +3,226 @@ package domain
 import "time"
 
 // Book represents the core book entity.
+// AverageRating and ReviewCount are derived from the book's reviews and are
+// maintained by the review use-case; they are never set from client input.
+// A non-nil DeletedAt marks the book as trashed (soft-deleted).
+// The validate tags declare the rules every stored book satisfies; see
+// package validation.
 type Book struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	Author    string    `json:"author"`
-	Year      int       `json:"year,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string     `json:"id"`
+	Title         string     `json:"title" validate:"trim,required,max=300"`
+	Author        string     `json:"author" validate:"trim,required,max=200"`
+	Year          int        `json:"year,omitempty" validate:"omitempty,year"`
+	ISBN          string     `json:"isbn,omitempty" validate:"omitempty,isbn"`
+	Tags          []string   `json:"tags,omitempty" validate:"omitempty,tags,max=20"`
+	AverageRating float64    `json:"average_rating"`
+	ReviewCount   int        `json:"review_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy     string     `json:"deleted_by,omitempty"`
 }
 
+// MinBookYear is the earliest accepted publication year. The latest is the
+// year after the current one, for announced titles.
+const MinBookYear = 1
+
+// MaxTagLength is the longest accepted tag, in characters. Tags are stored in
+// lower case with inner whitespace collapsed; see package validation.
+const MaxTagLength = 50
+
+// BookInput holds the client-supplied fields of a book for a create or a full
+// update. Nil Tags keep a book's tags on update, so that clients predating
+// tags do not clear them; empty Tags remove them.
+type BookInput struct {
+	Title  string
+	Author string
+	Year   int
+	ISBN   string
+	Tags   []string
+}
+
+// BookPatch holds the fields of a partial update; nil fields are left as they
+// are.
+type BookPatch struct {
+	Title  *string
+	Author *string
+	Year   *int
+	ISBN   *string
+	Tags   *[]string
+}
+
+// Sort keys accepted by BookFilter.Sort. Prefix a key with "-" to sort in
+// descending order. An empty Sort keeps insertion order.
+const (
+	SortByRating  = "rating"
+	SortByReviews = "reviews"
+)
+
+// TrashFilter selects which books a listing returns with respect to soft deletion.
+type TrashFilter int
+
+// TrashFilter values. The zero value hides trashed books.
+const (
+	ExcludeTrashed TrashFilter = iota
+	OnlyTrashed
+	IncludeTrashed
+)
+
 // BookFilter holds query parameters for listing books.
 type BookFilter struct {
 	Author string
-	Page   int
-	Limit  int
+	Year   int    // publication year; 0 matches any
+	Tag    string // a tag the book has, as stored; "" matches any
+	// Query, when set, matches the books whose title, author or one of
+	// whose tags contains each of its words, ignoring case.
+	Query string
+	Sort  string
+	Trash TrashFilter
+	Page  int
+	Limit int
+}
+
+// Facets a listing can count its books by.
+const (
+	FacetAuthor = "author"
+	FacetDecade = "decade"
+	FacetTag    = "tag"
+)
+
+// FacetCount is the number of books sharing a facet value.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// BookFacets counts the books matching a filter, before pagination, per
+// author, per decade and per tag. Decades are keyed as by BookStatsQuery
+// ("1960s", "" for books without a year); a book counts once for each of its
+// tags, and untagged books for none. Each facet is ordered by descending
+// count, then by value.
+type BookFacets struct {
+	Author []FacetCount
+	Decade []FacetCount
+	Tag    []FacetCount
+}
+
+// Group keys accepted by BookStatsQuery.GroupBy.
+const (
+	GroupByAuthor       = "author"
+	GroupByYear         = "year"
+	GroupByDecade       = "decade"
+	GroupByCreatedMonth = "created_month"
+)
+
+// BookStatsQuery selects the live books to aggregate and how to group them.
+type BookStatsQuery struct {
+	GroupBy string
+	// Author, when set, limits the aggregate to one author's books.
+	Author string
+}
+
+// BookGroup aggregates the books sharing a group key. The key is the author,
+// the year ("1965"), the decade ("1960s") or the UTC month the book was
+// created in ("2024-03"); books without a year are grouped under "" when
+// grouping by year or decade. MinYear and MaxYear are 0 when no book of the
+// group has a year.
+type BookGroup struct {
+	Key     string
+	Count   int
+	MinYear int
+	MaxYear int
+}
+
+// ValidGroupBy reports whether s is an accepted BookStatsQuery.GroupBy value.
+func ValidGroupBy(s string) bool {
+	switch s {
+	case GroupByAuthor, GroupByYear, GroupByDecade, GroupByCreatedMonth:
+		return true
+	}
+	return false
 }
 
 // BookRepository defines the persistence contract for books.
 // Implementations must be safe for concurrent use.
+//
+// Trashed books are invisible to GetByID and Update, which return ErrNotFound
+// for them; only GetAll with a TrashFilter, Restore and Delete can reach them.
+//
+// The write methods Create, Update, SoftDelete and Restore accept events to
+// record in the repository's outbox (see BookOutbox) atomically with the write:
+// either the write succeeds and every event is recorded, or neither happens.
+// The repository completes each event with an ID if it has none, the book ID,
+// and a snapshot of the book as stored after the write.
+//
+// Update keeps the stored AverageRating and ReviewCount: they are derived from
+// the book's reviews and change only through UpdateRating, so that an edit
+// racing a review cannot write back a stale rating, nor a review a stale edit.
+//
+// Books cross the interface by value. Implementations store copies of the
+// books passed to them, so a caller may reuse or modify a book once the call
+// returns, and return copies, which belong to the caller: modifying one
+// changes neither the stored book nor what any other caller sees. A change
+// reaches the store only through a write method.
 type BookRepository interface {
-	Create(book *Book) error
+	Create(book *Book, events ...BookEvent) error
 	GetByID(id string) (*Book, error)
 	GetAll(filter BookFilter) ([]*Book, int, error)
-	Update(book *Book) error
+	// GetAllFaceted is GetAll that also counts the matching books per facet,
+	// in the same pass as the filtering.
+	GetAllFaceted(filter BookFilter) ([]*Book, int, BookFacets, error)
+	Update(book *Book, events ...BookEvent) error
+	// UpdateRating sets a book's AverageRating and ReviewCount, trashed or
+	// not, and leaves its other fields alone. Returns ErrNotFound if absent.
+	UpdateRating(id string, rating RatingSummary) error
+	// SoftDelete moves a live book to the trash.
+	SoftDelete(id, deletedBy string, at time.Time, events ...BookEvent) error
+	// Restore takes a trashed book out of the trash.
+	Restore(id string, events ...BookEvent) error
+	// PurgeTrashed permanently deletes every book trashed before the cutoff
+	// and returns their IDs.
+	PurgeTrashed(before time.Time) ([]string, error)
+	// Delete permanently removes a book, trashed or not.
 	Delete(id string) error
+	// Stats aggregates the live books matching query, one BookGroup per key.
+	// Groups are ordered by key: authors and months as strings, years and
+	// decades numerically, with the "" group first. Returns ErrInvalidData
+	// for an unknown GroupBy.
+	Stats(query BookStatsQuery) ([]BookGroup, error)
 }
 
 // BookUseCase defines the business-logic contract for books.
+// The actor is the authenticated user on whose behalf a change is made.
 type BookUseCase interface {
-	CreateBook(title, author string, year int) (*Book, error)
+	// CreateBook, UpdateBook and PatchBook normalize and validate the
+	// resulting book and return a *ValidationError listing every violation.
+	CreateBook(input BookInput, actor string) (*Book, error)
 	GetBook(id string) (*Book, error)
 	GetBooks(filter BookFilter) ([]*Book, int, error)
-	UpdateBook(id, title, author string, year int) (*Book, error)
-	DeleteBook(id string) error
+	// GetBooksFaceted is GetBooks with the facet counts of the matching books.
+	GetBooksFaceted(filter BookFilter) ([]*Book, int, BookFacets, error)
+	UpdateBook(id string, input BookInput, actor string) (*Book, error)
+	// PatchBook changes only the fields set in patch.
+	PatchBook(id string, patch BookPatch, actor string) (*Book, error)
+	// DeleteBook moves a book to the trash on behalf of actor.
+	DeleteBook(id, actor string) error
+	GetTrash(filter BookFilter) ([]*Book, int, error)
+	RestoreBook(id, actor string) (*Book, error)
+	// PurgeTrash permanently deletes books trashed before the cutoff and
+	// returns how many were removed. A dependent that fails to forget a
+	// purged book does not stop the others; the failures are joined.
+	PurgeTrash(before time.Time) (int, error)
+	// GetBookStats aggregates the live books; see BookRepository.Stats.
+	GetBookStats(query BookStatsQuery) ([]BookGroup, error)
 }
 
+// ValidSort reports whether s is an accepted BookFilter.Sort value.
+func ValidSort(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	switch s {
+	case "", SortByRating, SortByReviews:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+// MaxCoverSize is the largest accepted cover upload, in bytes.
+const MaxCoverSize = 5 << 20
+
+// MaxCoverDimension bounds the width and height of an uploaded cover so that a
+// small, highly compressed file cannot expand into a huge decoded bitmap.
+const MaxCoverDimension = 8000
+
+// Cover sizes served by GET /books/:id/cover?size=.
+const (
+	CoverSizeOriginal = "original"
+	CoverSizeSmall    = "small"
+	CoverSizeMedium   = "medium"
+	CoverSizeLarge    = "large"
+)
+
+// CoverThumbnailWidths maps each generated thumbnail size to its maximum width
+// in pixels. Thumbnails keep the original aspect ratio and are never upscaled.
+var CoverThumbnailWidths = map[string]int{
+	CoverSizeSmall:  128,
+	CoverSizeMedium: 320,
+	CoverSizeLarge:  640,
+}
+
+// CoverImage describes one stored rendition of a cover.
+type CoverImage struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int    `json:"size"`
+}
+
+// Cover is the set of renditions stored for a book's cover image, keyed by
+// size name (CoverSizeOriginal plus every CoverThumbnailWidths entry).
+type Cover struct {
+	BookID    string                `json:"book_id"`
+	Images    map[string]CoverImage `json:"images"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// BlobStore stores immutable binary objects addressed by the hex SHA-256 of
+// their content. Put is idempotent: storing the same bytes twice yields the
+// same key. Implementations must be safe for concurrent use.
+type BlobStore interface {
+	Put(data []byte) (string, error)
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// CoverRepository defines the persistence contract for cover metadata.
+// The image bytes themselves live in a BlobStore.
+type CoverRepository interface {
+	BookDependent
+
+	Save(cover *Cover) error
+	Get(bookID string) (*Cover, error)
+}
+
+// CoverUseCase defines the business-logic contract for book covers.
+type CoverUseCase interface {
+	// UploadCover sniffs, validates and stores an image together with its
+	// thumbnails. Returns ErrTooLarge or ErrUnsupportedMedia for bad uploads.
+	UploadCover(bookID string, data []byte) (*Cover, error)
+	// GetCover returns the requested rendition, its bytes and its upload time.
+	GetCover(bookID, size string) (CoverImage, []byte, time.Time, error)
+}
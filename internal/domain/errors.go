@@ -1,12 +1,53 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 // Sentinel errors for domain-level error handling.
 var (
-	ErrNotFound     = errors.New("not found")
-	ErrInvalidData  = errors.New("invalid data")
-	ErrUnauthorized = errors.New("unauthorized")
-	ErrConflict     = errors.New("conflict")
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidData      = errors.New("invalid data")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrForbidden        = errors.New("forbidden")
+	ErrConflict         = errors.New("conflict")
+	ErrTooLarge         = errors.New("payload too large")
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+)
+
+// Validation error codes reported in FieldError.Code.
+const (
+	CodeRequired      = "required"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeOutOfRange    = "out_of_range"
+	CodeInvalidFormat = "invalid_format"
 )
 
+// FieldError describes one invalid field of an input. Field is the field's
+// JSON name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of an input. It matches
+// ErrInvalidData with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+	}
+	return ErrInvalidData.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidData.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidData
+}
@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+// Book change event types.
+const (
+	EventBookCreated  = "book.created"
+	EventBookUpdated  = "book.updated"
+	EventBookDeleted  = "book.deleted"
+	EventBookRestored = "book.restored"
+)
+
+// BookEvent describes one change to a book. ID is assigned when the event is
+// recorded in the outbox and stays the same if the event is delivered more
+// than once, so consumers can deduplicate on it. Seq is assigned by the
+// publisher and increases monotonically across all books. Book is the state
+// after the change; for book.deleted that is the trashed book.
+type BookEvent struct {
+	ID         string    `json:"id"`
+	Seq        uint64    `json:"seq"`
+	Type       string    `json:"type"`
+	BookID     string    `json:"book_id"`
+	Book       *Book     `json:"book"`
+	Actor      string    `json:"actor"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// BookEventPublisher receives book change events from the outbox relay.
+// Publish must not block on slow consumers. Publishing an event whose ID was
+// already published should not deliver it twice.
+type BookEventPublisher interface {
+	Publish(event BookEvent) BookEvent
+}
+
+// BookEventSink durably takes over book events from the outbox relay, which
+// marks an event dispatched only once every sink has accepted it. A sink may
+// therefore see an event again after a crash or an error and must treat a
+// repeated event ID as already accepted.
+type BookEventSink interface {
+	Accept(event BookEvent) error
+}
+
+// BookOutbox is the read side of a BookRepository's transactional outbox.
+// A relay drains it into a BookEventPublisher: it reads Pending events,
+// publishes them and only then marks them dispatched, so an event is
+// published at least once even if the relay stops in between.
+type BookOutbox interface {
+	// Pending returns up to limit undispatched events in the order their
+	// writes were committed. A limit <= 0 returns them all.
+	Pending(limit int) ([]BookEvent, error)
+	// MarkDispatched removes the events with the given IDs from the outbox.
+	MarkDispatched(ids ...string) error
+	// Notify receives a value whenever new events are recorded, so a relay
+	// need not poll. Notifications are coalesced.
+	Notify() <-chan struct{}
+}
+
+// BookEventSubscription is a live feed of book events.
+type BookEventSubscription interface {
+	// Events delivers events in Seq order. The channel is closed when the
+	// subscription is closed or dropped for falling too far behind.
+	Events() <-chan BookEvent
+	// Gap reports whether events after the requested sequence number had
+	// already been evicted from the replay buffer, so some were skipped.
+	Gap() bool
+	Close()
+}
+
+// BookEventStream lets delivery adapters follow book changes.
+type BookEventStream interface {
+	// Subscribe replays retained events with Seq > afterSeq and then streams
+	// new ones. afterSeq 0 means "only new events".
+	Subscribe(afterSeq uint64) BookEventSubscription
+}
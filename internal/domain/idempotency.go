@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Idempotency errors.
+var (
+	// ErrIdempotencyKeyInUse reports that a request with the same key is
+	// still being processed.
+	ErrIdempotencyKeyInUse = errors.New("idempotency key in use")
+	// ErrIdempotencyKeyReused reports that a key was reused for a request
+	// with a different method, path or body.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused")
+)
+
+// IdempotentResponse is the stored outcome of a request, replayed verbatim
+// for retries with the same key.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Location    string
+	Body        []byte
+}
+
+// IdempotencyRecord tracks one idempotency key of one user. Fingerprint
+// identifies the request the key was first used for. Response is nil while
+// that request is in flight. Fingerprint and CreatedAt together tell one
+// reservation of a key from a later one made after it expired.
+type IdempotencyRecord struct {
+	Owner       string
+	Key         string
+	Fingerprint string
+	Response    *IdempotentResponse
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IdempotencyRepository stores idempotency records. Implementations must be
+// safe for concurrent use; an expired record is treated as absent.
+type IdempotencyRepository interface {
+	// Reserve stores rec unless a live record exists for its owner and key,
+	// in which case it returns a copy of that record and stores nothing.
+	// The check and the store are atomic, so of two concurrent reservations
+	// exactly one succeeds.
+	Reserve(rec *IdempotencyRecord, now time.Time) (*IdempotencyRecord, error)
+	// Complete attaches the response to a reserved record. Returns
+	// ErrNotFound if there is no such record.
+	Complete(owner, key string, resp IdempotentResponse) error
+	// Release removes the owner's record for rec's key so the key can be used
+	// again, but only if it is the reservation rec describes: a record with
+	// another Fingerprint or CreatedAt, made after rec expired, is kept.
+	Release(rec *IdempotencyRecord) error
+	// PurgeExpired removes records that expired before now and returns how
+	// many were removed.
+	PurgeExpired(now time.Time) (int, error)
+}
+
+// IdempotencyUseCase defines the business-logic contract for idempotency
+// keys. A caller Begins a request before carrying it out and then Completes
+// it with the response, or Abandons it so that a retry runs it again.
+type IdempotencyUseCase interface {
+	// Begin claims key for the owner's request with the given fingerprint.
+	// It returns the record with its stored Response when the request
+	// already completed, the new reservation (without a Response) when the
+	// caller should carry the request out, ErrIdempotencyKeyInUse while it
+	// is in flight, and ErrIdempotencyKeyReused when the key belongs to a
+	// different request.
+	Begin(owner, key, fingerprint string) (*IdempotencyRecord, error)
+	Complete(owner, key string, resp IdempotentResponse) error
+	// Abandon releases a reservation returned by Begin.
+	Abandon(rec *IdempotencyRecord) error
+	// PurgeExpired removes expired keys and returns how many were removed.
+	PurgeExpired() (int, error)
+}
@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+// Rating bounds enforced on every review.
+const (
+	MinRating = 1
+	MaxRating = 5
+
+	// MaxReviewBodyLength caps the review text, counted in runes.
+	MaxReviewBodyLength = 5000
+)
+
+// Review is a single user's rating and commentary on a book.
+// Each user may hold at most one review per book.
+type Review struct {
+	ID        string    `json:"id"`
+	BookID    string    `json:"book_id"`
+	Username  string    `json:"username"`
+	Rating    int       `json:"rating"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RatingSummary aggregates all reviews of a single book.
+type RatingSummary struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// ReviewRepository defines the persistence contract for reviews.
+// Implementations must be safe for concurrent use and must return ErrConflict
+// when a user reviews the same book twice. RemoveBook deletes every review of
+// a book.
+type ReviewRepository interface {
+	BookDependent
+
+	Create(review *Review) error
+	GetByID(id string) (*Review, error)
+	ListByBook(bookID string) ([]*Review, error)
+	// ListByBooks returns the reviews of several books at once, keyed by
+	// book ID, each in creation order. Books without reviews are absent.
+	ListByBooks(bookIDs []string) (map[string][]*Review, error)
+	Update(review *Review) error
+	Delete(id string) error
+	Summary(bookID string) (RatingSummary, error)
+}
+
+// ReviewUseCase defines the business-logic contract for reviews.
+// Update and delete are restricted to the review's author (ErrForbidden).
+// Every method but GetReviewsByBooks reports a trashed book as ErrNotFound.
+type ReviewUseCase interface {
+	CreateReview(bookID, username string, rating int, body string) (*Review, error)
+	GetReviews(bookID string) ([]*Review, error)
+	// GetReviewsByBooks lists the reviews of several books in one call, keyed
+	// by book ID, for callers that batch lookups. It does not check that the
+	// books exist: unknown IDs simply have no reviews.
+	GetReviewsByBooks(bookIDs []string) (map[string][]*Review, error)
+	UpdateReview(bookID, reviewID, username string, rating int, body string) (*Review, error)
+	DeleteReview(bookID, reviewID, username string) error
+}
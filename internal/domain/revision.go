@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// Revision actions recorded by the book use-case.
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionDelete   = "delete"
+	RevisionRestore  = "restore"
+	RevisionRollback = "rollback"
+)
+
+// Revision is an immutable record of one change to a book. Before is nil for
+// the create revision. Numbers start at 1 and increase by one per book.
+type Revision struct {
+	BookID       string    `json:"book_id"`
+	Number       int       `json:"number"`
+	Action       string    `json:"action"`
+	Actor        string    `json:"actor"`
+	At           time.Time `json:"at"`
+	Before       *Book     `json:"before"`
+	After        *Book     `json:"after"`
+	RestoredFrom int       `json:"restored_from,omitempty"`
+}
+
+// FieldChange is one field that differs between two book states.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// RevisionDiff lists the field-level changes between two revisions of a book,
+// comparing the state after each revision.
+type RevisionDiff struct {
+	BookID  string        `json:"book_id"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// RevisionRepository defines the persistence contract for book revisions.
+// Revisions are append-only: implementations must store their own copies so
+// that later changes to the passed-in books cannot alter history.
+type RevisionRepository interface {
+	// Append assigns rev the next number for its book and stores it.
+	Append(rev *Revision) error
+	List(bookID string) ([]*Revision, error)
+	Get(bookID string, number int) (*Revision, error)
+}
+
+// RevisionUseCase defines the business-logic contract for book history.
+type RevisionUseCase interface {
+	GetRevisions(bookID string) ([]*Revision, error)
+	GetRevision(bookID string, number int) (*Revision, error)
+	DiffRevisions(bookID string, from, to int) (*RevisionDiff, error)
+	// RollbackBook restores the book's fields to their state after revision
+	// number, recording the rollback as a new revision.
+	RollbackBook(bookID string, number int, actor string) (*Book, error)
+}
@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// Names of the built-in shelves every user owns. A book sits on at most one
+// built-in shelf at a time, so together they track a user's reading status.
+const (
+	ShelfToRead  = "to-read"
+	ShelfReading = "reading"
+	ShelfDone    = "done"
+)
+
+// DefaultShelves lists the built-in shelves in display order.
+var DefaultShelves = []string{ShelfToRead, ShelfReading, ShelfDone}
+
+// MaxShelfNameLength caps custom shelf names, counted in runes.
+const MaxShelfNameLength = 100
+
+// Shelf is an ordered, user-owned list of books.
+// A non-empty ShareToken grants read-only public access to the shelf.
+type Shelf struct {
+	ID         string    `json:"id"`
+	Owner      string    `json:"owner"`
+	Name       string    `json:"name"`
+	Default    bool      `json:"default"`
+	BookIDs    []string  `json:"book_ids"`
+	ShareToken string    `json:"share_token,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SharedShelf is the public, read-only view of a shared shelf.
+type SharedShelf struct {
+	Name  string  `json:"name"`
+	Owner string  `json:"owner"`
+	Books []*Book `json:"books"`
+}
+
+// ShelfProgress summarises a user's reading status across the built-in shelves.
+type ShelfProgress struct {
+	ToRead      int     `json:"to_read"`
+	Reading     int     `json:"reading"`
+	Done        int     `json:"done"`
+	Total       int     `json:"total"`
+	PercentDone float64 `json:"percent_done"`
+}
+
+// BookDependent is implemented by stores that hold references to books and
+// must drop them when a book is deleted.
+type BookDependent interface {
+	RemoveBook(bookID string) error
+}
+
+// ShelfRepository defines the persistence contract for shelves.
+// Implementations must be safe for concurrent use and must return ErrConflict
+// when an owner already has a shelf with the same name.
+type ShelfRepository interface {
+	BookDependent
+
+	Create(shelf *Shelf) error
+	GetByID(id string) (*Shelf, error)
+	GetByShareToken(token string) (*Shelf, error)
+	ListByOwner(owner string) ([]*Shelf, error)
+	Update(shelf *Shelf) error
+	Delete(id string) error
+}
+
+// ShelfUseCase defines the business-logic contract for shelves.
+// Every owner-scoped method reports another user's shelf as ErrNotFound.
+type ShelfUseCase interface {
+	GetShelves(owner string) ([]*Shelf, error)
+	CreateShelf(owner, name string) (*Shelf, error)
+	GetShelf(owner, id string) (*Shelf, error)
+	DeleteShelf(owner, id string) error
+	AddBook(owner, shelfID, bookID string, position int) (*Shelf, error)
+	RemoveBook(owner, shelfID, bookID string) (*Shelf, error)
+	ReorderBooks(owner, shelfID string, bookIDs []string) (*Shelf, error)
+	GetProgress(owner string) (*ShelfProgress, error)
+	ShareShelf(owner, id string) (*Shelf, error)
+	UnshareShelf(owner, id string) (*Shelf, error)
+	GetSharedShelf(token string) (*SharedShelf, error)
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Delivery states. A delivery is retried while pending and moves to the
+// dead-letter list once it has failed MaxAttempts times in a row.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookEvents lists the event types a webhook may subscribe to.
+var WebhookEvents = []string{EventBookCreated, EventBookUpdated, EventBookDeleted, EventBookRestored}
+
+// Webhook is a user-owned subscription that POSTs book events to URL.
+// An empty Events list subscribes to every type in WebhookEvents. Secret keys
+// the HMAC-SHA256 signature of each payload; it is only revealed on creation.
+type Webhook struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryAttempt records one HTTP request made for a delivery.
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// WebhookDelivery is one event queued for one webhook. Payload is the exact
+// request body, so retries and manual redeliveries are byte-identical and the
+// receiver can deduplicate on ID. Attempts counts tries since the delivery was
+// last queued; History keeps every try, including those before a redelivery.
+// Version counts stored updates, so that an update based on a stale read is
+// rejected instead of overwriting a newer state.
+type WebhookDelivery struct {
+	ID            string            `json:"id"`
+	WebhookID     string            `json:"webhook_id"`
+	EventSeq      uint64            `json:"event_seq"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at,omitzero"`
+	History       []DeliveryAttempt `json:"history"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Version       int               `json:"version"`
+}
+
+// DeliveryFilter narrows a delivery listing. Empty fields match everything.
+// A zero Limit means no limit.
+type DeliveryFilter struct {
+	WebhookIDs []string
+	Status     string
+	Limit      int
+}
+
+// WebhookRepository defines the persistence contract for webhooks.
+// Implementations must be safe for concurrent use.
+type WebhookRepository interface {
+	Create(hook *Webhook) error
+	GetByID(id string) (*Webhook, error)
+	// List returns the owner's webhooks oldest first; "" lists every webhook.
+	List(owner string) ([]*Webhook, error)
+	Update(hook *Webhook) error
+	// Delete removes the webhook together with its deliveries.
+	Delete(id string) error
+}
+
+// WebhookDeliveryRepository is the durable delivery queue. Implementations
+// must be safe for concurrent use.
+type WebhookDeliveryRepository interface {
+	// Enqueue stores a new delivery. Returns ErrConflict if one with the same
+	// ID exists.
+	Enqueue(delivery *WebhookDelivery) error
+	GetDelivery(id string) (*WebhookDelivery, error)
+	// UpdateDelivery stores delivery if its Version is the stored one and
+	// advances delivery.Version. Returns ErrConflict if the delivery was
+	// updated since it was read.
+	UpdateDelivery(delivery *WebhookDelivery) error
+	// Due returns up to limit pending deliveries whose NextAttemptAt is not
+	// after now, earliest first.
+	Due(now time.Time, limit int) ([]*WebhookDelivery, error)
+	// ListDeliveries returns matching deliveries, newest first.
+	ListDeliveries(filter DeliveryFilter) ([]*WebhookDelivery, error)
+}
+
+// WebhookUseCase defines the business-logic contract for managing webhooks
+// and inspecting their deliveries. Every method is scoped to owner; another
+// user's webhooks and deliveries are reported as ErrNotFound.
+type WebhookUseCase interface {
+	CreateWebhook(owner, url string, events []string) (*Webhook, error)
+	GetWebhooks(owner string) ([]*Webhook, error)
+	GetWebhook(owner, id string) (*Webhook, error)
+	UpdateWebhook(owner, id, url string, events []string, active bool) (*Webhook, error)
+	DeleteWebhook(owner, id string) error
+	GetDeliveries(owner, webhookID, status string) ([]*WebhookDelivery, error)
+	GetDelivery(owner, webhookID, deliveryID string) (*WebhookDelivery, error)
+	// GetDeadLetters lists dead deliveries across all of owner's webhooks.
+	GetDeadLetters(owner string) ([]*WebhookDelivery, error)
+	// Redeliver queues a dead or succeeded delivery for immediate retry.
+	// Returns ErrConflict if it is still pending.
+	Redeliver(owner, webhookID, deliveryID string) (*WebhookDelivery, error)
+}
@@ -0,0 +1,167 @@
+// Package eventbus provides an in-process publish/subscribe broker for domain
+// events with a bounded replay buffer.
+package eventbus
+
+import (
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// Default sizes used by NewBroker when given non-positive values.
+const (
+	DefaultReplaySize     = 1024
+	DefaultSubscriberSize = 256
+)
+
+// Broker implements domain.BookEventPublisher and domain.BookEventStream.
+// It numbers events with a monotonic sequence, keeps the most recent events in
+// a ring buffer for resumption, and fans them out to subscribers. A subscriber
+// whose channel is full is dropped rather than allowed to block publishers;
+// it can resume from its last seen sequence number.
+//
+// Events with an ID are deduplicated while they are retained: republishing
+// one (as an at-least-once outbox relay may) returns the original event
+// without delivering it again.
+type Broker struct {
+	mu          sync.Mutex
+	seq         uint64
+	ring        []domain.BookEvent
+	retained    map[string]uint64 // event ID → Seq for events in ring
+	next        int               // index in ring of the next write
+	full        bool
+	subs        map[*subscription]struct{}
+	subCapacity int
+}
+
+// NewBroker returns a broker retaining replaySize events and giving each
+// subscriber subscriberSize slots of headroom beyond its replay.
+func NewBroker(replaySize, subscriberSize int) *Broker {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	if subscriberSize <= 0 {
+		subscriberSize = DefaultSubscriberSize
+	}
+	return &Broker{
+		ring:        make([]domain.BookEvent, replaySize),
+		retained:    make(map[string]uint64, replaySize),
+		subs:        make(map[*subscription]struct{}),
+		subCapacity: subscriberSize,
+	}
+}
+
+// Publish assigns the next sequence number, retains the event and delivers it
+// to every subscriber. It returns the event with Seq set. An event whose ID is
+// still retained is not delivered again; the retained copy is returned.
+func (b *Broker) Publish(event domain.BookEvent) domain.BookEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq, ok := b.retained[event.ID]; ok {
+		return b.ring[b.index(seq)]
+	}
+
+	b.seq++
+	event.Seq = b.seq
+	if evicted := b.ring[b.next]; evicted.ID != "" && b.retained[evicted.ID] == evicted.Seq {
+		delete(b.retained, evicted.ID)
+	}
+	if event.ID != "" {
+		b.retained[event.ID] = event.Seq
+	}
+	b.ring[b.next] = event
+	b.next = (b.next + 1) % len(b.ring)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for s := range b.subs {
+		select {
+		case s.ch <- event:
+		default:
+			b.drop(s)
+		}
+	}
+	return event
+}
+
+// Subscribe replays retained events newer than afterSeq (none when afterSeq is
+// 0) and then streams new ones. The subscription must be closed by the caller.
+func (b *Broker) Subscribe(afterSeq uint64) domain.BookEventSubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []domain.BookEvent
+	if afterSeq > 0 {
+		replay = b.retainedAfter(afterSeq)
+	}
+	s := &subscription{
+		broker: b,
+		ch:     make(chan domain.BookEvent, len(replay)+b.subCapacity),
+	}
+	if afterSeq > 0 {
+		oldest := b.seq + 1
+		if len(replay) > 0 {
+			oldest = replay[0].Seq
+		} else if r := b.retainedAfter(0); len(r) > 0 {
+			oldest = r[0].Seq
+		}
+		// A gap exists if events between afterSeq and the oldest retained
+		// event were evicted, or if afterSeq is from before a restart.
+		s.gap = afterSeq+1 < oldest || afterSeq > b.seq
+	}
+	for _, e := range replay {
+		s.ch <- e
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// index returns the ring position of the retained event with the given Seq.
+// Callers must hold b.mu.
+func (b *Broker) index(seq uint64) int {
+	back := int(b.seq - seq) // 0 for the newest event
+	return (b.next - 1 - back + 2*len(b.ring)) % len(b.ring)
+}
+
+// retainedAfter returns retained events with Seq > afterSeq in order.
+// Callers must hold b.mu.
+func (b *Broker) retainedAfter(afterSeq uint64) []domain.BookEvent {
+	var ordered []domain.BookEvent
+	if b.full {
+		ordered = append(ordered, b.ring[b.next:]...)
+	}
+	ordered = append(ordered, b.ring[:b.next]...)
+
+	for i, e := range ordered {
+		if e.Seq > afterSeq {
+			return ordered[i:]
+		}
+	}
+	return nil
+}
+
+// drop removes and closes a subscription. Callers must hold b.mu.
+func (b *Broker) drop(s *subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+type subscription struct {
+	broker *Broker
+	ch     chan domain.BookEvent
+	gap    bool
+}
+
+func (s *subscription) Events() <-chan domain.BookEvent { return s.ch }
+
+func (s *subscription) Gap() bool { return s.gap }
+
+func (s *subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
@@ -0,0 +1,122 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+)
+
+func drain(sub domain.BookEventSubscription) []uint64 {
+	var seqs []uint64
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return seqs
+			}
+			seqs = append(seqs, e.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+// TestBrokerResume verifies sequence numbering and Last-Event-ID style replay.
+func TestBrokerResume(t *testing.T) {
+	b := eventbus.NewBroker(4, 8)
+	for i := 0; i < 6; i++ {
+		b.Publish(domain.BookEvent{Type: domain.EventBookCreated})
+	}
+
+	sub := b.Subscribe(4)
+	defer sub.Close()
+	if got := drain(sub); len(got) != 2 || got[0] != 5 || got[1] != 6 {
+		t.Errorf("resume after 4: got %v, want [5 6]", got)
+	}
+	if sub.Gap() {
+		t.Error("resume after 4: unexpected gap")
+	}
+
+	b.Publish(domain.BookEvent{Type: domain.EventBookUpdated})
+	if got := drain(sub); len(got) != 1 || got[0] != 7 {
+		t.Errorf("live: got %v, want [7]", got)
+	}
+}
+
+// TestBrokerGap verifies resuming from an evicted or future sequence number
+// is reported as a gap.
+func TestBrokerGap(t *testing.T) {
+	b := eventbus.NewBroker(4, 8)
+	for i := 0; i < 10; i++ {
+		b.Publish(domain.BookEvent{})
+	}
+
+	evicted := b.Subscribe(2)
+	defer evicted.Close()
+	if !evicted.Gap() {
+		t.Error("resume after evicted seq: want gap")
+	}
+	if got := drain(evicted); len(got) != 4 || got[0] != 7 {
+		t.Errorf("resume after evicted seq: got %v, want [7 8 9 10]", got)
+	}
+
+	future := b.Subscribe(42)
+	defer future.Close()
+	if !future.Gap() {
+		t.Error("resume after unknown future seq: want gap")
+	}
+
+	fresh := b.Subscribe(0)
+	defer fresh.Close()
+	if fresh.Gap() || len(drain(fresh)) != 0 {
+		t.Error("fresh subscription: want no gap and no replay")
+	}
+}
+
+// TestBrokerDropsSlowConsumer verifies a full subscriber is closed instead of
+// blocking Publish.
+func TestBrokerDropsSlowConsumer(t *testing.T) {
+	b := eventbus.NewBroker(16, 2)
+	sub := b.Subscribe(0)
+
+	for i := 0; i < 5; i++ {
+		b.Publish(domain.BookEvent{})
+	}
+
+	var n int
+	for range sub.Events() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("slow consumer received %d events before being dropped, want 2", n)
+	}
+	sub.Close() // closing a dropped subscription is a no-op
+}
+
+// TestBrokerDeduplicatesByID verifies a republished event is neither
+// renumbered nor delivered again while it is retained, and is treated as new
+// once evicted.
+func TestBrokerDeduplicatesByID(t *testing.T) {
+	b := eventbus.NewBroker(2, 8)
+	sub := b.Subscribe(0)
+	defer sub.Close()
+
+	first := b.Publish(domain.BookEvent{ID: "a", Type: domain.EventBookCreated})
+	again := b.Publish(domain.BookEvent{ID: "a", Type: domain.EventBookCreated})
+	if again.Seq != first.Seq {
+		t.Errorf("republish: got seq %d, want %d", again.Seq, first.Seq)
+	}
+	b.Publish(domain.BookEvent{ID: "b"})
+	b.Publish(domain.BookEvent{ID: "c"}) // evicts "a"
+	if got := b.Publish(domain.BookEvent{ID: "a"}); got.Seq != 4 {
+		t.Errorf("republish after eviction: got seq %d, want 4", got.Seq)
+	}
+	if got := b.Publish(domain.BookEvent{ID: "c"}); got.Seq != 3 {
+		t.Errorf("republish retained c: got seq %d, want 3", got.Seq)
+	}
+
+	if got := drain(sub); len(got) != 4 || got[0] != 1 || got[3] != 4 {
+		t.Errorf("delivered: got %v, want [1 2 3 4]", got)
+	}
+}
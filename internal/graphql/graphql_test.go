@@ -0,0 +1,282 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/graphql"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// countingReviews counts the review lookups the server makes.
+type countingReviews struct {
+	domain.ReviewUseCase
+	single, batch atomic.Int32
+}
+
+func (c *countingReviews) GetReviews(bookID string) ([]*domain.Review, error) {
+	c.single.Add(1)
+	return c.ReviewUseCase.GetReviews(bookID)
+}
+
+func (c *countingReviews) GetReviewsByBooks(bookIDs []string) (map[string][]*domain.Review, error) {
+	c.batch.Add(1)
+	return c.ReviewUseCase.GetReviewsByBooks(bookIDs)
+}
+
+type fixture struct {
+	t       *testing.T
+	server  *graphql.Server
+	books   domain.BookUseCase
+	reviews domain.ReviewUseCase
+	counts  *countingReviews
+}
+
+func newFixture(t *testing.T, limits graphql.Limits) *fixture {
+	t.Helper()
+	bookRepo := memory.NewBookRepository()
+	books := usecase.NewBookUseCase(bookRepo, memory.NewRevisionRepository())
+	reviews := usecase.NewReviewUseCase(memory.NewReviewRepository(), bookRepo)
+	counts := &countingReviews{ReviewUseCase: reviews}
+	return &fixture{t: t, server: graphql.NewServer(books, counts, limits), books: books, reviews: reviews, counts: counts}
+}
+
+type gqlError struct {
+	Message    string         `json:"message"`
+	Extensions map[string]any `json:"extensions"`
+}
+
+// do runs query as actor and decodes the JSON response.
+func (f *fixture) do(actor, query string, variables map[string]any) (map[string]any, []gqlError) {
+	f.t.Helper()
+	resp := f.server.Execute(context.Background(), actor, graphql.Request{Query: query, Variables: variables})
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		f.t.Fatalf("encode response: %v", err)
+	}
+	var out struct {
+		Data   map[string]any `json:"data"`
+		Errors []gqlError     `json:"errors"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		f.t.Fatalf("decode %s: %v", raw, err)
+	}
+	return out.Data, out.Errors
+}
+
+func (f *fixture) mustDo(actor, query string, variables map[string]any) map[string]any {
+	f.t.Helper()
+	data, errs := f.do(actor, query, variables)
+	if len(errs) > 0 {
+		f.t.Fatalf("%s: unexpected errors %+v", query, errs)
+	}
+	return data
+}
+
+func codeOf(errs []gqlError) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	code, _ := errs[0].Extensions["code"].(string)
+	return code
+}
+
+// TestReviewsAreBatched verifies that nested reviews of a whole page of books
+// are fetched with one batch call and no per-book calls.
+func TestReviewsAreBatched(t *testing.T) {
+	f := newFixture(t, graphql.DefaultLimits())
+	for i := 0; i < 5; i++ {
+		b, err := f.books.CreateBook(domain.BookInput{Title: fmt.Sprintf("Book %d", i), Author: "Ann"}, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < i; j++ {
+			if _, err := f.reviews.CreateReview(b.ID, fmt.Sprintf("user%d", j), 4, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	data := f.mustDo("admin", `{ books(limit: 10) { title reviewCount reviews(limit: 3) { username rating } } bookCount }`, nil)
+	books := data["books"].([]any)
+	if len(books) != 5 || data["bookCount"] != float64(5) {
+		t.Fatalf("got %d books, bookCount %v", len(books), data["bookCount"])
+	}
+	for i, b := range books {
+		b := b.(map[string]any)
+		if want := min(i, 3); len(b["reviews"].([]any)) != want {
+			t.Errorf("%s: got %d reviews, want %d", b["title"], len(b["reviews"].([]any)), want)
+		}
+		if b["reviewCount"] != float64(i) {
+			t.Errorf("%s: reviewCount %v, want %d", b["title"], b["reviewCount"], i)
+		}
+	}
+	if single, batch := f.counts.single.Load(), f.counts.batch.Load(); single != 0 || batch != 1 {
+		t.Errorf("review lookups: %d single, %d batch; want 0 and 1", single, batch)
+	}
+}
+
+// TestBooksFilterAndPagination verifies the author filter, sorting and paging,
+// with arguments passed inline and as variables.
+func TestBooksFilterAndPagination(t *testing.T) {
+	f := newFixture(t, graphql.DefaultLimits())
+	for i, author := range []string{"Ann", "Bob", "Ann", "Ann", "Bob"} {
+		if _, err := f.books.CreateBook(domain.BookInput{Title: fmt.Sprintf("T%d", i), Author: author}, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := `query Page($author: String, $page: Int, $limit: Int) {
+		books(author: $author, page: $page, limit: $limit) { title author }
+		bookCount(author: $author)
+	}`
+	data := f.mustDo("admin", query, map[string]any{"author": "Ann", "page": 2, "limit": 2})
+	books := data["books"].([]any)
+	if len(books) != 1 || books[0].(map[string]any)["title"] != "T3" || data["bookCount"] != float64(3) {
+		t.Errorf("page 2 of Ann: got %v, count %v", books, data["bookCount"])
+	}
+
+	data = f.mustDo("admin", `{ books(sort: RATING_DESC, limit: 2) { title } }`, nil)
+	if len(data["books"].([]any)) != 2 {
+		t.Errorf("sorted page: got %v", data["books"])
+	}
+
+	_, errs := f.do("admin", `{ books(limit: 1000) { title } }`, nil)
+	if codeOf(errs) != "invalid_data" {
+		t.Errorf("limit 1000: got %+v, want invalid_data", errs)
+	}
+}
+
+// TestMutations verifies create, update and delete, and that their errors
+// carry the REST API's codes.
+func TestMutations(t *testing.T) {
+	f := newFixture(t, graphql.DefaultLimits())
+
+	data := f.mustDo("admin", `mutation { createBook(input: {title: " Dune ", author: "Frank Herbert", year: 1965}) { id title year isbn } }`, nil)
+	created := data["createBook"].(map[string]any)
+	id := created["id"].(string)
+	if created["title"] != "Dune" || created["year"] != float64(1965) || created["isbn"] != nil {
+		t.Errorf("created: %v", created)
+	}
+
+	data = f.mustDo("admin", `mutation($id: ID!, $in: BookInput!) { updateBook(id: $id, input: $in) { title isbn } }`,
+		map[string]any{"id": id, "in": map[string]any{"title": "Dune Messiah", "author": "Frank Herbert", "isbn": "978-0-441-17271-9"}})
+	if updated := data["updateBook"].(map[string]any); updated["title"] != "Dune Messiah" || updated["isbn"] != "9780441172719" {
+		t.Errorf("updated: %v", updated)
+	}
+
+	_, errs := f.do("admin", `mutation { createBook(input: {title: "", author: "x", year: -5}) { id } }`, nil)
+	if codeOf(errs) != "validation_failed" {
+		t.Fatalf("invalid create: got %+v, want validation_failed", errs)
+	}
+	if fields, _ := errs[0].Extensions["fields"].([]any); len(fields) != 2 {
+		t.Errorf("invalid create: fields %v, want title and year", errs[0].Extensions["fields"])
+	}
+
+	_, errs = f.do("admin", `mutation { updateBook(id: "missing", input: {title: "x", author: "y"}) { id } }`, nil)
+	if codeOf(errs) != "not_found" {
+		t.Errorf("update missing: got %+v, want not_found", errs)
+	}
+
+	_, errs = f.do("", `mutation { deleteBook(id: "`+id+`") }`, nil)
+	if codeOf(errs) != "unauthorized" {
+		t.Errorf("anonymous delete: got %+v, want unauthorized", errs)
+	}
+	data = f.mustDo("admin", `mutation { deleteBook(id: "`+id+`") }`, nil)
+	if data["deleteBook"] != id {
+		t.Errorf("deleteBook: got %v", data["deleteBook"])
+	}
+	data = f.mustDo("admin", `{ book(id: "`+id+`") { id } }`, nil)
+	if data["book"] != nil {
+		t.Errorf("trashed book: got %v, want null", data["book"])
+	}
+}
+
+// TestLimits verifies that queries over the depth or complexity limit are
+// rejected before running, counting fragments and variables, and that a
+// standard introspection query fits the default limits.
+func TestLimits(t *testing.T) {
+	f := newFixture(t, graphql.Limits{MaxDepth: 2, MaxComplexity: 100})
+
+	cases := []struct {
+		query     string
+		variables map[string]any
+		code      string
+	}{
+		{`{ books { title } }`, nil, ""},                                                          // 1 + 20*1
+		{`{ books { reviews { rating } } }`, nil, "limit_exceeded"},                               // depth 3
+		{`{ books { ...deep } } fragment deep on Book { reviews { id } }`, nil, "limit_exceeded"}, // depth 3
+		{`{ books(limit: 99) { title } }`, nil, ""},                                               // 1 + 99
+		{`{ books(limit: 50) { id title } }`, nil, "limit_exceeded"},                              // 1 + 50*2
+		{`query($n: Int) { books(limit: $n) { id title } }`, map[string]any{"n": 50.0}, "limit_exceeded"},
+		{`{ books { nope } }`, nil, "invalid_query"},
+		{`{ books {`, nil, "invalid_query"},
+	}
+	for _, tc := range cases {
+		_, errs := f.do("admin", tc.query, tc.variables)
+		if got := codeOf(errs); got != tc.code {
+			t.Errorf("%s: got code %q (%+v), want %q", tc.query, got, errs, tc.code)
+		}
+	}
+
+	f = newFixture(t, graphql.DefaultLimits())
+	data := f.mustDo("admin", introspectionQuery, nil)
+	if data["__schema"] == nil {
+		t.Errorf("introspection: got %v", data)
+	}
+}
+
+// TestOutOfRangeLimitsCount verifies that a field with a negative or zero
+// limit cannot offset the complexity of an aliased sibling.
+func TestOutOfRangeLimitsCount(t *testing.T) {
+	f := newFixture(t, graphql.Limits{MaxDepth: 15, MaxComplexity: 50})
+
+	cases := []struct {
+		query     string
+		variables map[string]any
+	}{
+		{`{ big: books(limit: 100) { reviews(limit: 100) { id } } neg: books(limit: -1000) { reviews(limit: 100) { id } } }`, nil},
+		{`query($n: Int) { big: books(limit: 100) { reviews(limit: 100) { id } } neg: books(limit: $n) { id } }`, map[string]any{"n": -1e6}},
+		{`{ a: books(limit: 0) { id } b: books(limit: 0) { id } c: books(limit: 40) { id title } }`, nil}, // 2 + 2 + 81
+	}
+	for _, tc := range cases {
+		if _, errs := f.do("admin", tc.query, tc.variables); codeOf(errs) != "limit_exceeded" {
+			t.Errorf("%s: got %+v, want limit_exceeded", tc.query, errs)
+		}
+	}
+}
+
+// introspectionQuery is the query GraphQL tooling sends to discover a schema.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives { name description locations args { ...InputValue } }
+  }
+}
+fragment FullType on __Type {
+  kind name description
+  fields(includeDeprecated: true) {
+    name description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+  possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name
+    ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
@@ -0,0 +1,180 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Default query limits.
+const (
+	DefaultMaxDepth      = 15
+	DefaultMaxComplexity = 50000
+
+	// defaultListSize is the assumed length of a list field without a limit
+	// argument, such as the lists of the introspection schema.
+	defaultListSize = 10
+)
+
+// Limits bound the cost of a query; queries over either limit are rejected
+// before any resolver runs.
+//
+// Depth counts nested fields: { books { reviews { id } } } has depth 3.
+// Complexity estimates the number of values a query produces: every field
+// costs 1, and a list field multiplies the cost of its selection by its limit
+// argument, or by 10 when it has none. The multiplier is clamped to
+// [1, MaxLimit], so an out-of-range limit, which its resolver rejects, cannot
+// lower the cost of the fields beside it.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// DefaultLimits returns the limits used by the API.
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: DefaultMaxDepth, MaxComplexity: DefaultMaxComplexity}
+}
+
+// cost holds the measurements of a selection set.
+type cost struct {
+	depth      int
+	complexity int
+}
+
+// analyzer measures an operation of a valid document against a schema.
+type analyzer struct {
+	schema    *gql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// check returns an error with a limit_exceeded code when op exceeds limits.
+func (l Limits) check(schema *gql.Schema, doc *ast.Document, op *ast.OperationDefinition, variables map[string]any) error {
+	a := analyzer{schema: schema, fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			a.fragments[f.Name.Value] = f
+		}
+	}
+
+	root := schema.QueryType()
+	if op.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	c := a.selectionSet(op.SelectionSet, root, map[string]bool{})
+
+	switch {
+	case l.MaxDepth > 0 && c.depth > l.MaxDepth:
+		return limitError(fmt.Sprintf("query depth %d exceeds the limit of %d", c.depth, l.MaxDepth))
+	case l.MaxComplexity > 0 && c.complexity > l.MaxComplexity:
+		return limitError(fmt.Sprintf("query complexity %d exceeds the limit of %d", c.complexity, l.MaxComplexity))
+	}
+	return nil
+}
+
+// selectionSet measures set, whose fields belong to parent. spread holds the
+// fragments being expanded on the current path.
+func (a analyzer) selectionSet(set *ast.SelectionSet, parent gql.Type, spread map[string]bool) cost {
+	var total cost
+	if set == nil {
+		return total
+	}
+	add := func(c cost) {
+		total.depth = max(total.depth, c.depth)
+		total.complexity += c.complexity
+	}
+
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			add(a.field(sel, parent, spread))
+		case *ast.InlineFragment:
+			t := parent
+			if sel.TypeCondition != nil {
+				t = a.schema.Type(sel.TypeCondition.Name.Value)
+			}
+			add(a.selectionSet(sel.SelectionSet, t, spread))
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			f, ok := a.fragments[name]
+			if !ok || spread[name] {
+				continue
+			}
+			spread[name] = true
+			add(a.selectionSet(f.SelectionSet, a.schema.Type(f.TypeCondition.Name.Value), spread))
+			delete(spread, name)
+		}
+	}
+	return total
+}
+
+func (a analyzer) field(f *ast.Field, parent gql.Type, spread map[string]bool) cost {
+	def := a.fieldDef(parent, f.Name.Value)
+	if def == nil {
+		return cost{depth: 1, complexity: 1}
+	}
+
+	t := def.Type
+	if nn, ok := t.(*gql.NonNull); ok {
+		t = nn.OfType
+	}
+	multiplier := 1
+	if list, ok := t.(*gql.List); ok {
+		multiplier = min(max(a.listSize(f, def), 1), MaxLimit)
+		t = list.OfType
+		if nn, ok := t.(*gql.NonNull); ok {
+			t = nn.OfType
+		}
+	}
+
+	child := a.selectionSet(f.SelectionSet, t, spread)
+	return cost{depth: 1 + child.depth, complexity: 1 + multiplier*child.complexity}
+}
+
+// listSize returns the limit argument of a list field, from the query, its
+// variables or its default, or defaultListSize.
+func (a analyzer) listSize(f *ast.Field, def *gql.FieldDefinition) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := a.variables[v.Name.Value].(float64); ok {
+				return int(n)
+			}
+		}
+	}
+	for _, arg := range def.Args {
+		if arg.Name() == "limit" {
+			if n, ok := arg.DefaultValue.(int); ok {
+				return n
+			}
+		}
+	}
+	return defaultListSize
+}
+
+func (a analyzer) fieldDef(parent gql.Type, name string) *gql.FieldDefinition {
+	switch name {
+	case gql.SchemaMetaFieldDef.Name:
+		return gql.SchemaMetaFieldDef
+	case gql.TypeMetaFieldDef.Name:
+		return gql.TypeMetaFieldDef
+	case gql.TypeNameMetaFieldDef.Name:
+		return gql.TypeNameMetaFieldDef
+	}
+	switch t := parent.(type) {
+	case *gql.Object:
+		return t.Fields()[name]
+	case *gql.Interface:
+		return t.Fields()[name]
+	}
+	return nil
+}
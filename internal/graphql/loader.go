@@ -0,0 +1,61 @@
+package graphql
+
+import "sync"
+
+// loader batches and caches lookups by key for the lifetime of one request,
+// in the manner of a dataloader. Resolvers call Load for every key they need
+// and get back a thunk; the executor runs the thunks only after it has
+// resolved the whole level of the query, so the first thunk to run fetches
+// every key queued by then in a single call.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	done    map[K]V
+	err     error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, queued: make(map[K]bool), done: make(map[K]V)}
+}
+
+// Load queues key and returns a thunk yielding its value. A key absent from
+// the fetched map yields the zero value.
+func (l *loader[K, V]) Load(key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.done[key]; !ok && !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.flush()
+		}
+		if l.err != nil {
+			var zero V
+			return zero, l.err
+		}
+		return l.done[key], nil
+	}
+}
+
+// flush fetches every pending key. The caller holds l.mu.
+func (l *loader[K, V]) flush() {
+	keys := l.pending
+	l.pending = nil
+	clear(l.queued)
+	values, err := l.fetch(keys)
+	if err != nil {
+		l.err = err
+		return
+	}
+	for _, k := range keys {
+		l.done[k] = values[k]
+	}
+}
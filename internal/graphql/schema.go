@@ -0,0 +1,301 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	gql "github.com/graphql-go/graphql"
+)
+
+// Pagination defaults and bounds of list fields.
+const (
+	DefaultBooksLimit   = 20
+	DefaultReviewsLimit = 20
+	MaxLimit            = 100
+)
+
+// newSchema defines:
+//
+//	type Query {
+//	  book(id: ID!): Book
+//	  books(author: String, sort: BookSort, page: Int = 1, limit: Int = 20): [Book!]!
+//	  bookCount(author: String): Int!
+//	}
+//	type Mutation {
+//	  createBook(input: BookInput!): Book!
+//	  updateBook(id: ID!, input: BookInput!): Book!
+//	  deleteBook(id: ID!): ID!
+//	}
+//	type Book {
+//	  id: ID!  title: String!  author: String!  year: Int  isbn: String
+//	  averageRating: Float!  reviewCount: Int!  createdAt: DateTime!
+//	  reviews(limit: Int = 20): [Review!]!
+//	}
+//	type Review {
+//	  id: ID!  username: String!  rating: Int!  body: String!
+//	  createdAt: DateTime!  updatedAt: DateTime!
+//	}
+//	input BookInput { title: String!  author: String!  year: Int  isbn: String }
+//	enum BookSort { RATING RATING_DESC REVIEWS REVIEWS_DESC }
+func (s *Server) newSchema() (gql.Schema, error) {
+	review := gql.NewObject(gql.ObjectConfig{
+		Name:        "Review",
+		Description: "A user's rating and commentary on a book.",
+		Fields: gql.Fields{
+			"id":        reviewField(gql.ID, func(r *domain.Review) any { return r.ID }),
+			"username":  reviewField(gql.String, func(r *domain.Review) any { return r.Username }),
+			"rating":    reviewField(gql.Int, func(r *domain.Review) any { return r.Rating }),
+			"body":      reviewField(gql.String, func(r *domain.Review) any { return r.Body }),
+			"createdAt": reviewField(gql.DateTime, func(r *domain.Review) any { return r.CreatedAt }),
+			"updatedAt": reviewField(gql.DateTime, func(r *domain.Review) any { return r.UpdatedAt }),
+		},
+	})
+
+	book := gql.NewObject(gql.ObjectConfig{
+		Name:        "Book",
+		Description: "A book in the catalogue.",
+		Fields: gql.Fields{
+			"id":            bookField(gql.NewNonNull(gql.ID), func(b *domain.Book) any { return b.ID }),
+			"title":         bookField(gql.NewNonNull(gql.String), func(b *domain.Book) any { return b.Title }),
+			"author":        bookField(gql.NewNonNull(gql.String), func(b *domain.Book) any { return b.Author }),
+			"year":          bookField(gql.Int, func(b *domain.Book) any { return nonZero(b.Year) }),
+			"isbn":          bookField(gql.String, func(b *domain.Book) any { return nonZero(b.ISBN) }),
+			"averageRating": bookField(gql.NewNonNull(gql.Float), func(b *domain.Book) any { return b.AverageRating }),
+			"reviewCount":   bookField(gql.NewNonNull(gql.Int), func(b *domain.Book) any { return b.ReviewCount }),
+			"createdAt":     bookField(gql.NewNonNull(gql.DateTime), func(b *domain.Book) any { return b.CreatedAt }),
+			"reviews": &gql.Field{
+				Type:        gql.NewNonNull(gql.NewList(gql.NewNonNull(review))),
+				Description: "The book's reviews in creation order. Loaded in one batch for every book in the response.",
+				Args: gql.FieldConfigArgument{
+					"limit": {Type: gql.Int, DefaultValue: DefaultReviewsLimit},
+				},
+				Resolve: s.resolveReviews,
+			},
+		},
+	})
+
+	bookInput := gql.NewInputObject(gql.InputObjectConfig{
+		Name:        "BookInput",
+		Description: "The client-supplied fields of a book. The REST API's validation rules apply.",
+		Fields: gql.InputObjectConfigFieldMap{
+			"title":  {Type: gql.NewNonNull(gql.String)},
+			"author": {Type: gql.NewNonNull(gql.String)},
+			"year":   {Type: gql.Int},
+			"isbn":   {Type: gql.String},
+		},
+	})
+
+	bookSort := gql.NewEnum(gql.EnumConfig{
+		Name: "BookSort",
+		Values: gql.EnumValueConfigMap{
+			"RATING":       {Value: domain.SortByRating},
+			"RATING_DESC":  {Value: "-" + domain.SortByRating},
+			"REVIEWS":      {Value: domain.SortByReviews},
+			"REVIEWS_DESC": {Value: "-" + domain.SortByReviews},
+		},
+	})
+
+	query := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"book": &gql.Field{
+				Type:        book,
+				Description: "The book with the given ID, or null.",
+				Args:        gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.ID)}},
+				Resolve:     s.resolveBook,
+			},
+			"books": &gql.Field{
+				Type:        gql.NewNonNull(gql.NewList(gql.NewNonNull(book))),
+				Description: "A page of books, optionally by one author, in insertion order unless sorted.",
+				Args: gql.FieldConfigArgument{
+					"author": {Type: gql.String},
+					"sort":   {Type: bookSort},
+					"page":   {Type: gql.Int, DefaultValue: 1},
+					"limit":  {Type: gql.Int, DefaultValue: DefaultBooksLimit},
+				},
+				Resolve: s.resolveBooks,
+			},
+			"bookCount": &gql.Field{
+				Type:        gql.NewNonNull(gql.Int),
+				Description: "The number of books, optionally by one author.",
+				Args:        gql.FieldConfigArgument{"author": {Type: gql.String}},
+				Resolve:     s.resolveBookCount,
+			},
+		},
+	})
+
+	mutation := gql.NewObject(gql.ObjectConfig{
+		Name: "Mutation",
+		Fields: gql.Fields{
+			"createBook": &gql.Field{
+				Type:    gql.NewNonNull(book),
+				Args:    gql.FieldConfigArgument{"input": {Type: gql.NewNonNull(bookInput)}},
+				Resolve: s.resolveCreateBook,
+			},
+			"updateBook": &gql.Field{
+				Type: gql.NewNonNull(book),
+				Args: gql.FieldConfigArgument{
+					"id":    {Type: gql.NewNonNull(gql.ID)},
+					"input": {Type: gql.NewNonNull(bookInput)},
+				},
+				Resolve: s.resolveUpdateBook,
+			},
+			"deleteBook": &gql.Field{
+				Type:        gql.NewNonNull(gql.ID),
+				Description: "Moves the book to the trash and returns its ID.",
+				Args:        gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.ID)}},
+				Resolve:     s.resolveDeleteBook,
+			},
+		},
+	})
+
+	return gql.NewSchema(gql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func bookField(t gql.Output, get func(*domain.Book) any) *gql.Field {
+	return &gql.Field{Type: t, Resolve: func(p gql.ResolveParams) (any, error) {
+		return get(p.Source.(*domain.Book)), nil
+	}}
+}
+
+func reviewField(t gql.Output, get func(*domain.Review) any) *gql.Field {
+	return &gql.Field{Type: gql.NewNonNull(t), Resolve: func(p gql.ResolveParams) (any, error) {
+		return get(p.Source.(*domain.Review)), nil
+	}}
+}
+
+// nonZero maps the zero value of an optional field to null.
+func nonZero[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+func (s *Server) resolveBook(p gql.ResolveParams) (any, error) {
+	b, err := s.books.GetBook(p.Args["id"].(string))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Server) resolveBooks(p gql.ResolveParams) (any, error) {
+	filter := domain.BookFilter{Page: intArg(p, "page", 1), Limit: intArg(p, "limit", DefaultBooksLimit)}
+	filter.Author, _ = p.Args["author"].(string)
+	filter.Sort, _ = p.Args["sort"].(string)
+	if filter.Page < 1 {
+		return nil, fmt.Errorf("%w: page must be at least 1", domain.ErrInvalidData)
+	}
+	if err := checkLimit(filter.Limit); err != nil {
+		return nil, err
+	}
+
+	books, _, err := s.books.GetBooks(filter)
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (s *Server) resolveBookCount(p gql.ResolveParams) (any, error) {
+	filter := domain.BookFilter{Page: 1, Limit: 1}
+	filter.Author, _ = p.Args["author"].(string)
+	_, total, err := s.books.GetBooks(filter)
+	return total, err
+}
+
+// resolveReviews queues the book for the request's review loader and returns
+// a thunk, so that the reviews of every book at this level are fetched
+// together.
+func (s *Server) resolveReviews(p gql.ResolveParams) (any, error) {
+	limit := intArg(p, "limit", DefaultReviewsLimit)
+	if err := checkLimit(limit); err != nil {
+		return nil, err
+	}
+	load := state(p.Context).reviews.Load(p.Source.(*domain.Book).ID)
+	return func() (any, error) {
+		reviews, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if len(reviews) > limit {
+			reviews = reviews[:limit]
+		}
+		if reviews == nil {
+			reviews = []*domain.Review{}
+		}
+		return reviews, nil
+	}, nil
+}
+
+func (s *Server) resolveCreateBook(p gql.ResolveParams) (any, error) {
+	actor, err := actorOf(p)
+	if err != nil {
+		return nil, err
+	}
+	return s.books.CreateBook(bookInput(p.Args["input"]), actor)
+}
+
+func (s *Server) resolveUpdateBook(p gql.ResolveParams) (any, error) {
+	actor, err := actorOf(p)
+	if err != nil {
+		return nil, err
+	}
+	id := p.Args["id"].(string)
+	b, err := s.books.UpdateBook(id, bookInput(p.Args["input"]), actor)
+	if err != nil {
+		return nil, fmt.Errorf("book %s: %w", id, err)
+	}
+	return b, nil
+}
+
+func (s *Server) resolveDeleteBook(p gql.ResolveParams) (any, error) {
+	actor, err := actorOf(p)
+	if err != nil {
+		return nil, err
+	}
+	id := p.Args["id"].(string)
+	if err := s.books.DeleteBook(id, actor); err != nil {
+		return nil, fmt.Errorf("book %s: %w", id, err)
+	}
+	return id, nil
+}
+
+func actorOf(p gql.ResolveParams) (string, error) {
+	st := state(p.Context)
+	if st == nil || st.actor == "" {
+		return "", domain.ErrUnauthorized
+	}
+	return st.actor, nil
+}
+
+func bookInput(arg any) domain.BookInput {
+	m := arg.(map[string]any)
+	var in domain.BookInput
+	in.Title, _ = m["title"].(string)
+	in.Author, _ = m["author"].(string)
+	in.Year, _ = m["year"].(int)
+	in.ISBN, _ = m["isbn"].(string)
+	return in
+}
+
+// intArg returns an Int argument, or def when it is null.
+func intArg(p gql.ResolveParams, name string, def int) int {
+	if n, ok := p.Args[name].(int); ok {
+		return n
+	}
+	return def
+}
+
+func checkLimit(limit int) error {
+	if limit < 1 || limit > MaxLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidData, MaxLimit)
+	}
+	return nil
+}
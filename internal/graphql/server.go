@@ -0,0 +1,186 @@
+// Package graphql serves the book domain over GraphQL. Queries and mutations
+// are resolved through domain.BookUseCase and domain.ReviewUseCase, the same
+// use-cases behind the REST API; reviews are loaded in batches, one use-case
+// call per query level, however many books a query returns.
+//
+// Errors carry the same stable codes as the REST API's problem details in
+// their extensions, plus the codes of this package: invalid_query for a
+// query that does not parse or validate and limit_exceeded for one over
+// Limits.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Error codes specific to GraphQL.
+const (
+	CodeInvalidQuery  = "invalid_query"
+	CodeLimitExceeded = "limit_exceeded"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL response. Data is absent when the query was rejected
+// before execution.
+type Response struct {
+	Data   any                        `json:"data,omitempty"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// Server executes GraphQL requests against the book schema.
+type Server struct {
+	schema  gql.Schema
+	books   domain.BookUseCase
+	reviews domain.ReviewUseCase
+	limits  Limits
+}
+
+// NewServer builds the schema over the given use-cases.
+func NewServer(books domain.BookUseCase, reviews domain.ReviewUseCase, limits Limits) *Server {
+	s := &Server{books: books, reviews: reviews, limits: limits}
+	schema, err := s.newSchema()
+	if err != nil {
+		panic("graphql: build schema: " + err.Error())
+	}
+	s.schema = schema
+	return s
+}
+
+// requestState is the per-request state resolvers find in their context.
+type requestState struct {
+	actor   string
+	reviews *loader[string, []*domain.Review]
+}
+
+type requestStateKey struct{}
+
+func state(ctx context.Context) *requestState {
+	st, _ := ctx.Value(requestStateKey{}).(*requestState)
+	return st
+}
+
+// Execute parses, validates, checks the limits of and runs req on behalf of
+// actor.
+func (s *Server) Execute(ctx context.Context, actor string, req Request) *Response {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &Response{Errors: withCodes(gqlerrors.FormatErrors(err))}
+	}
+	if v := gql.ValidateDocument(&s.schema, doc, nil); !v.IsValid {
+		return &Response{Errors: withCodes(v.Errors)}
+	}
+	// Without a single matching operation, Execute reports the error.
+	if op := operation(doc, req.OperationName); op != nil {
+		if err := s.limits.check(&s.schema, doc, op, req.Variables); err != nil {
+			return &Response{Errors: withCodes(gqlerrors.FormatErrors(err))}
+		}
+	}
+
+	ctx = context.WithValue(ctx, requestStateKey{}, &requestState{
+		actor:   actor,
+		reviews: newLoader(s.reviews.GetReviewsByBooks),
+	})
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	return &Response{Data: result.Data, Errors: withCodes(result.Errors)}
+}
+
+// operation returns the operation of doc that a request for name runs.
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil
+			}
+			found = op
+		} else if op.Name != nil && op.Name.Value == name {
+			return op
+		}
+	}
+	return found
+}
+
+// codedError is an error raised by this package with its own code.
+type codedError struct {
+	code    string
+	message string
+}
+
+func (e *codedError) Error() string { return e.message }
+
+func limitError(message string) error {
+	return &codedError{code: CodeLimitExceeded, message: message}
+}
+
+// withCodes adds a code extension to every error, classifying the error a
+// resolver returned as the REST API does. The message of an unexpected error
+// is logged instead of being returned.
+func withCodes(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i, e := range errs {
+		cause := causeOf(e)
+		var coded *codedError
+		switch {
+		case cause == nil:
+			e.Extensions = map[string]any{"code": CodeInvalidQuery}
+		case errors.As(cause, &coded):
+			e.Extensions = map[string]any{"code": coded.code}
+		default:
+			kind := problem.KindOf(cause)
+			e.Extensions = map[string]any{"code": kind.Code}
+			var invalid *domain.ValidationError
+			switch {
+			case kind == problem.Internal:
+				log.Printf("graphql: %v: %v", e.Path, cause)
+				e.Message = "an unexpected error occurred"
+			case errors.As(cause, &invalid):
+				e.Extensions["fields"] = invalid.Fields
+			}
+		}
+		errs[i] = e
+	}
+	return errs
+}
+
+// causeOf digs the error a resolver returned out of the wrappers the executor
+// puts around it. It returns nil for errors raised by the parser or validator.
+func causeOf(err error) error {
+	for err != nil {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+	return nil
+}
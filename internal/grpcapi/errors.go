@@ -0,0 +1,85 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo detail of every error
+// status; its reason is the REST API's problem code.
+const ErrorDomain = "api-quest"
+
+// statusCodes maps problem codes to gRPC status codes. Codes not listed map
+// to Unknown.
+var statusCodes = map[string]codes.Code{
+	problem.BadRequest.Code:       codes.InvalidArgument,
+	problem.InvalidData.Code:      codes.InvalidArgument,
+	problem.ValidationFailed.Code: codes.InvalidArgument,
+	problem.Unauthorized.Code:     codes.Unauthenticated,
+	problem.Forbidden.Code:        codes.PermissionDenied,
+	problem.NotFound.Code:         codes.NotFound,
+	problem.Conflict.Code:         codes.AlreadyExists,
+	problem.PayloadTooLarge.Code:  codes.ResourceExhausted,
+	problem.UnsupportedMedia.Code: codes.InvalidArgument,
+	problem.Internal.Code:         codes.Internal,
+}
+
+// toStatus converts an error returned by a handler into a status error.
+// Status errors pass through unchanged. A *domain.ValidationError also
+// carries a google.rpc.BadRequest detail listing every invalid field. The
+// message of an unexpected error is logged instead of being returned.
+func toStatus(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	kind := problem.KindOf(err)
+	code, ok := statusCodes[kind.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+	msg := err.Error()
+	if kind == problem.Internal {
+		log.Printf("grpc: %s: %v", method, err)
+		msg = "an unexpected error occurred"
+	}
+
+	st := status.New(code, msg)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: kind.Code, Domain: ErrorDomain}}
+	var invalid *domain.ValidationError
+	if errors.As(err, &invalid) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(invalid.Fields))
+		for i, f := range invalid.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message, Reason: f.Code}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+func unaryErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	return resp, toStatus(info.FullMethod, err)
+}
+
+func streamErrors(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return toStatus(info.FullMethod, handler(srv, ss))
+}
@@ -0,0 +1,202 @@
+// Package grpcapi serves the book domain over gRPC as book.v1.BookService
+// (see proto/book/v1). Calls are resolved through domain.BookUseCase, the
+// same use-case behind the REST and GraphQL APIs, and WatchBooks follows the
+// same event stream as GET /v1/books/events.
+//
+// Every call must carry a Bearer JWT in its authorization metadata. Errors
+// are returned as gRPC statuses: domain errors map to the closest status code
+// and carry the REST API's stable code as the reason of a google.rpc.ErrorInfo
+// detail.
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	bookv1 "github.com/andrimuhayat/crud-test/proto/book/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Pagination defaults and bounds of ListBooks.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// EventReset is the type of the event that starts a WatchBooks stream whose
+// requested events are no longer retained.
+const EventReset = "reset"
+
+// NewServer returns a gRPC server with BookService registered behind the
+// authentication and error-mapping interceptors.
+func NewServer(books domain.BookUseCase, stream domain.BookEventStream, authUC domain.AuthUseCase, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryErrors, unaryAuth(authUC)),
+		grpc.ChainStreamInterceptor(streamErrors, streamAuth(authUC)),
+	)
+	s := grpc.NewServer(opts...)
+	bookv1.RegisterBookServiceServer(s, NewBookService(books, stream))
+	return s
+}
+
+// BookService implements bookv1.BookServiceServer.
+type BookService struct {
+	bookv1.UnimplementedBookServiceServer
+	books  domain.BookUseCase
+	stream domain.BookEventStream
+}
+
+// NewBookService wires the service to the book use-case and event stream.
+func NewBookService(books domain.BookUseCase, stream domain.BookEventStream) *BookService {
+	return &BookService{books: books, stream: stream}
+}
+
+// GetBook implements bookv1.BookServiceServer.
+func (s *BookService) GetBook(ctx context.Context, req *bookv1.GetBookRequest) (*bookv1.Book, error) {
+	b, err := s.books.GetBook(req.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("book %s: %w", req.GetId(), err)
+	}
+	return toBook(b), nil
+}
+
+// ListBooks implements bookv1.BookServiceServer.
+func (s *BookService) ListBooks(ctx context.Context, req *bookv1.ListBooksRequest) (*bookv1.ListBooksResponse, error) {
+	filter := domain.BookFilter{
+		Author: req.GetAuthor(),
+		Sort:   req.GetSort(),
+		Page:   int(req.GetPage()),
+		Limit:  int(req.GetLimit()),
+	}
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListLimit
+	}
+	switch {
+	case !domain.ValidSort(filter.Sort):
+		return nil, fmt.Errorf("%w: sort must be one of rating, -rating, reviews, -reviews", domain.ErrInvalidData)
+	case filter.Page < 1:
+		return nil, fmt.Errorf("%w: page must be at least 1", domain.ErrInvalidData)
+	case filter.Limit < 1 || filter.Limit > MaxListLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidData, MaxListLimit)
+	}
+
+	books, total, err := s.books.GetBooks(filter)
+	if err != nil {
+		return nil, err
+	}
+	resp := &bookv1.ListBooksResponse{Books: make([]*bookv1.Book, len(books)), Total: int32(total)}
+	for i, b := range books {
+		resp.Books[i] = toBook(b)
+	}
+	return resp, nil
+}
+
+// CreateBook implements bookv1.BookServiceServer.
+func (s *BookService) CreateBook(ctx context.Context, req *bookv1.CreateBookRequest) (*bookv1.Book, error) {
+	b, err := s.books.CreateBook(toInput(req.GetBook()), Actor(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return toBook(b), nil
+}
+
+// UpdateBook implements bookv1.BookServiceServer.
+func (s *BookService) UpdateBook(ctx context.Context, req *bookv1.UpdateBookRequest) (*bookv1.Book, error) {
+	b, err := s.books.UpdateBook(req.GetId(), toInput(req.GetBook()), Actor(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("book %s: %w", req.GetId(), err)
+	}
+	return toBook(b), nil
+}
+
+// DeleteBook implements bookv1.BookServiceServer.
+func (s *BookService) DeleteBook(ctx context.Context, req *bookv1.DeleteBookRequest) (*bookv1.DeleteBookResponse, error) {
+	if err := s.books.DeleteBook(req.GetId(), Actor(ctx)); err != nil {
+		return nil, fmt.Errorf("book %s: %w", req.GetId(), err)
+	}
+	return &bookv1.DeleteBookResponse{}, nil
+}
+
+// WatchBooks implements bookv1.BookServiceServer. Response headers are sent
+// as soon as the subscription is in place, so a client that waits for them
+// sees every later change. It streams until the client cancels, or fails
+// with Unavailable when the subscription is dropped for falling behind; the
+// client resumes with after_seq set to the last sequence number it received.
+func (s *BookService) WatchBooks(req *bookv1.WatchBooksRequest, stream grpc.ServerStreamingServer[bookv1.BookEvent]) error {
+	sub := s.stream.Subscribe(req.GetAfterSeq())
+	defer sub.Close()
+
+	// Headers tell the client that the subscription is in place.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	if sub.Gap() {
+		if err := stream.Send(&bookv1.BookEvent{Type: EventReset}); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "subscriber fell too far behind; resume with after_seq")
+			}
+			if author := req.GetAuthor(); author != "" && (event.Book == nil || event.Book.Author != author) {
+				continue
+			}
+			if err := stream.Send(toEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toInput(in *bookv1.BookInput) domain.BookInput {
+	return domain.BookInput{
+		Title:  in.GetTitle(),
+		Author: in.GetAuthor(),
+		Year:   int(in.GetYear()),
+		ISBN:   in.GetIsbn(),
+	}
+}
+
+func toBook(b *domain.Book) *bookv1.Book {
+	if b == nil {
+		return nil
+	}
+	out := &bookv1.Book{
+		Id:            b.ID,
+		Title:         b.Title,
+		Author:        b.Author,
+		Year:          int32(b.Year),
+		Isbn:          b.ISBN,
+		AverageRating: b.AverageRating,
+		ReviewCount:   int32(b.ReviewCount),
+		CreatedAt:     timestamppb.New(b.CreatedAt),
+	}
+	if b.DeletedAt != nil {
+		out.DeletedAt = timestamppb.New(*b.DeletedAt)
+	}
+	return out
+}
+
+func toEvent(e domain.BookEvent) *bookv1.BookEvent {
+	return &bookv1.BookEvent{
+		Id:         e.ID,
+		Seq:        e.Seq,
+		Type:       e.Type,
+		BookId:     e.BookID,
+		Book:       toBook(e.Book),
+		Actor:      e.Actor,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+	}
+}
@@ -0,0 +1,198 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+	"github.com/andrimuhayat/crud-test/internal/grpcapi"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	bookv1 "github.com/andrimuhayat/crud-test/proto/book/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves BookService over an in-memory connection and returns a
+// client for it and a token for admin.
+func newClient(t *testing.T) (bookv1.BookServiceClient, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	bookRepo := memory.NewBookRepository()
+	broker := eventbus.NewBroker(0, 0)
+	go usecase.NewOutboxRelay(bookRepo, broker, 10*time.Millisecond).Run(ctx)
+	authUC := usecase.NewAuthUseCase()
+	books := usecase.NewBookUseCase(bookRepo, memory.NewRevisionRepository())
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpcapi.NewServer(books, broker, authUC)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	token, err := authUC.GenerateToken("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bookv1.NewBookServiceClient(conn), token
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// reason returns the ErrorInfo reason of a status error.
+func reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// TestAuthentication verifies that unary and streaming calls without a valid
+// token are rejected.
+func TestAuthentication(t *testing.T) {
+	client, _ := newClient(t)
+
+	for name, ctx := range map[string]context.Context{
+		"no metadata": context.Background(),
+		"bad scheme":  metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic YWRtaW46c2VjcmV0"),
+		"bad token":   withToken("not-a-jwt"),
+	} {
+		if _, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ListBooks with %s: got %v, want Unauthenticated", name, err)
+		}
+		stream, err := client.WatchBooks(ctx, &bookv1.WatchBooksRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("WatchBooks with %s: got %v, want Unauthenticated", name, err)
+		}
+	}
+}
+
+// TestCRUD verifies each unary call and the status codes of domain errors.
+func TestCRUD(t *testing.T) {
+	client, token := newClient(t)
+	ctx := withToken(token)
+
+	created, err := client.CreateBook(ctx, &bookv1.CreateBookRequest{Book: &bookv1.BookInput{
+		Title: " Dune ", Author: "Frank Herbert", Year: 1965, Isbn: "978-0-441-17271-9",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetTitle() != "Dune" || created.GetIsbn() != "9780441172719" || created.GetCreatedAt() == nil {
+		t.Errorf("created: %v", created)
+	}
+
+	got, err := client.GetBook(ctx, &bookv1.GetBookRequest{Id: created.GetId()})
+	if err != nil || got.GetTitle() != "Dune" {
+		t.Errorf("GetBook: got %v, %v", got, err)
+	}
+
+	updated, err := client.UpdateBook(ctx, &bookv1.UpdateBookRequest{Id: created.GetId(), Book: &bookv1.BookInput{Title: "Dune Messiah", Author: "Frank Herbert"}})
+	if err != nil || updated.GetTitle() != "Dune Messiah" || updated.GetYear() != 0 {
+		t.Errorf("UpdateBook: got %v, %v", updated, err)
+	}
+
+	list, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{Author: "Frank Herbert"})
+	if err != nil || list.GetTotal() != 1 || len(list.GetBooks()) != 1 {
+		t.Errorf("ListBooks: got %v, %v", list, err)
+	}
+	if _, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{Limit: 1000}); status.Code(err) != codes.InvalidArgument || reason(err) != "invalid_data" {
+		t.Errorf("ListBooks limit 1000: got %v, want InvalidArgument invalid_data", err)
+	}
+
+	if _, err := client.DeleteBook(ctx, &bookv1.DeleteBookRequest{Id: created.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetBook(ctx, &bookv1.GetBookRequest{Id: created.GetId()})
+	if status.Code(err) != codes.NotFound || reason(err) != "not_found" {
+		t.Errorf("GetBook of a trashed book: got %v, want NotFound", err)
+	}
+	if _, err := client.DeleteBook(ctx, &bookv1.DeleteBookRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteBook missing: got %v, want NotFound", err)
+	}
+}
+
+// TestValidationDetails verifies that a validation failure lists every
+// invalid field in a BadRequest detail.
+func TestValidationDetails(t *testing.T) {
+	client, token := newClient(t)
+
+	_, err := client.CreateBook(withToken(token), &bookv1.CreateBookRequest{Book: &bookv1.BookInput{Author: "x", Year: -5}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || reason(err) != "validation_failed" {
+		t.Fatalf("got %v, want InvalidArgument validation_failed", err)
+	}
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	if len(fields) != 2 || fields[0] != "title" || fields[1] != "year" {
+		t.Errorf("field violations: got %v, want [title year]", fields)
+	}
+}
+
+// TestWatchBooks verifies that changes are streamed with their actor, that the
+// author filter applies and that after_seq replays missed events.
+func TestWatchBooks(t *testing.T) {
+	client, token := newClient(t)
+	ctx, cancel := context.WithTimeout(withToken(token), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchBooks(ctx, &bookv1.WatchBooksRequest{Author: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The subscription starts once the stream's headers arrive.
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, author := range []string{"Bob", "Ann"} {
+		if _, err := client.CreateBook(ctx, &bookv1.CreateBookRequest{Book: &bookv1.BookInput{Title: "T", Author: author}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetType() != "book.created" || event.GetBook().GetAuthor() != "Ann" || event.GetActor() != "admin" || event.GetSeq() != 2 {
+		t.Errorf("event: got %v, want Ann's book.created with seq 2", event)
+	}
+
+	replay, err := client.WatchBooks(ctx, &bookv1.WatchBooksRequest{AfterSeq: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, err := replay.Recv(); err != nil || event.GetSeq() != 2 {
+		t.Errorf("replay after seq 1: got %v, %v", event, err)
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditHandler exposes the audit log to administrators.
+type AuditHandler struct {
+	auditUC domain.AuditUseCase
+}
+
+// NewAuditHandler wires the handler to the audit use-case.
+func NewAuditHandler(auditUC domain.AuditUseCase) *AuditHandler {
+	return &AuditHandler{auditUC: auditUC}
+}
+
+// GetAudit handles GET /admin/audit with optional ?user=, ?action=, ?since=,
+// ?until= (RFC 3339) and ?limit= query params. Entries are returned newest first.
+func (h *AuditHandler) GetAudit(c *fiber.Ctx) error {
+	filter := domain.AuditFilter{
+		Subject: c.Query("user"),
+		Action:  c.Query("action"),
+		Limit:   c.QueryInt("limit", 0),
+	}
+
+	var err error
+	if filter.Since, err = parseOptionalTime(c.Query("since")); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "since must be an RFC 3339 timestamp")
+	}
+	if filter.Until, err = parseOptionalTime(c.Query("until")); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "until must be an RFC 3339 timestamp")
+	}
+
+	entries, err := h.auditUC.Query(filter)
+	if err != nil {
+		return problem.Write(c, err, "")
+	}
+	return c.JSON(entries)
+}
+
+// VerifyAudit handles GET /admin/audit/verify. It responds 200 when the hash
+// chain is intact and 409 when tampering was detected.
+func (h *AuditHandler) VerifyAudit(c *fiber.Ctx) error {
+	v, err := h.auditUC.Verify()
+	if err != nil {
+		return problem.Write(c, err, "")
+	}
+	if !v.Valid {
+		return c.Status(http.StatusConflict).JSON(v)
+	}
+	return c.JSON(v)
+}
+
+func parseOptionalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -1,9 +1,8 @@
 package handler
 
 import (
-	"net/http"
-
 	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -27,12 +26,12 @@ type tokenRequest struct {
 func (h *AuthHandler) GenerateToken(c *fiber.Ctx) error {
 	var req tokenRequest
 	if err := c.BodyParser(&req); err != nil || req.Username == "" || req.Password == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "username and password are required"})
+		return problem.Write(c, problem.ErrBadRequest, "username and password are required")
 	}
 
 	token, err := h.authUC.GenerateToken(req.Username, req.Password)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+		return problem.Write(c, err, "invalid credentials")
 	}
 
 	return c.JSON(fiber.Map{"token": token})
@@ -1,110 +1,363 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
 	"github.com/gofiber/fiber/v2"
 )
 
+// BookAdapter converts between one API version's book DTOs and the domain,
+// so that the handlers serve every version and only the wire format differs.
+type BookAdapter interface {
+	// DecodeInput parses the body of a create or full update in format and
+	// validates it. It returns problem.ErrBadRequest for a malformed body
+	// and a *domain.ValidationError for invalid fields.
+	DecodeInput(body []byte, format serializer.Serializer) (domain.BookInput, error)
+	// DecodePatch does the same for the body of a partial update.
+	DecodePatch(body []byte, format serializer.Serializer) (domain.BookPatch, error)
+	// Book, Review and Revision return the version's response DTOs. Book
+	// must return a pointer to a struct, whose fields ?fields= selects from
+	// by their JSON names.
+	Book(b *domain.Book) any
+	Review(r *domain.Review) any
+	Revision(rev *domain.Revision) any
+	// BookList returns the DTO of a listing with the facets in names; books
+	// are already converted.
+	BookList(books []any, total int, facets domain.BookFacets, names []string) any
+	// Stats returns the DTO of an aggregate grouped by groupBy.
+	Stats(groupBy string, groups []domain.BookGroup) any
+}
+
 // BookHandler handles CRUD and search endpoints for books.
+//
+// Responses are encoded in the format the Accept header asks for and request
+// bodies are decoded by their Content-Type, both from the formats in the
+// handler's serializer registry. Errors are always problem+json.
+//
+// GET /books, GET /books/search and GET /books/:id take ?fields= and
+// ?include= to shape their responses; see bookView.
 type BookHandler struct {
-	bookUC domain.BookUseCase
-}
+	bookUC     domain.BookUseCase
+	reviewUC   domain.ReviewUseCase
+	revisionUC domain.RevisionUseCase
+	adapter    BookAdapter
+	formats    *serializer.Registry
 
-// NewBookHandler wires the handler to the book use-case.
-func NewBookHandler(bookUC domain.BookUseCase) *BookHandler {
-	return &BookHandler{bookUC: bookUC}
+	// dto is the struct type of the adapter's books and fields the JSON
+	// names of its fields, in order.
+	dto       reflect.Type
+	fields    []string
+	relations map[string]relation
 }
 
-type createBookRequest struct {
-	Title  string `json:"title"`
-	Author string `json:"author"`
-	Year   int    `json:"year"`
+// NewBookHandler wires the handler to the book use-case, speaking the API
+// version of adapter in the given formats. The review and revision use-cases
+// supply the relations ?include= embeds.
+func NewBookHandler(bookUC domain.BookUseCase, reviewUC domain.ReviewUseCase, revisionUC domain.RevisionUseCase,
+	adapter BookAdapter, formats *serializer.Registry) *BookHandler {
+	h := &BookHandler{bookUC: bookUC, reviewUC: reviewUC, revisionUC: revisionUC, adapter: adapter, formats: formats}
+	h.dto = reflect.TypeOf(adapter.Book(&domain.Book{})).Elem()
+	h.fields = jsonNames(h.dto)
+	h.relations = map[string]relation{
+		IncludeReviews:   {element: "review", load: h.loadReviews},
+		IncludeRevisions: {element: "revision", load: h.loadRevisions},
+	}
+	return h
 }
 
 // CreateBook handles POST /books.
 func (h *BookHandler) CreateBook(c *fiber.Ctx) error {
-	var req createBookRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	in, err := h.bodyFormat(c)
+	if err != nil {
+		return bookError(c, err)
 	}
-	if req.Title == "" || req.Author == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "title and author are required"})
+	input, err := h.adapter.DecodeInput(c.Body(), in)
+	if err != nil {
+		return bookError(c, err)
 	}
 
-	book, err := h.bookUC.CreateBook(req.Title, req.Author, req.Year)
+	book, err := h.bookUC.CreateBook(input, middleware.Username(c))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return bookError(c, err)
 	}
-	return c.Status(http.StatusCreated).JSON(book)
+	return out.send(c, http.StatusCreated, h.adapter.Book(book))
 }
 
 // GetBook handles GET /books/:id.
 func (h *BookHandler) GetBook(c *fiber.Ctx) error {
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	view, err := h.parseView(c)
+	if err != nil {
+		return problem.Write(c, problem.ErrBadRequest, err.Error())
+	}
 	id := c.Params("id")
 	book, err := h.bookUC.GetBook(id)
-	if err == domain.ErrNotFound {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "book not found"})
+	if err != nil {
+		return bookError(c, err)
 	}
+	resp, err := h.render([]*domain.Book{book}, view)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Write(c, err, "")
 	}
-	return c.JSON(book)
+	return out.send(c, http.StatusOK, resp[0])
 }
 
-// GetBooks handles GET /books with optional ?author= query param.
-// Returns a bare JSON array of book objects (Level 3 requirement).
+// GetBooks handles GET /books with optional ?author=, ?year=, ?tag= and
+// ?sort= query params. Returns a bare array of book objects (Level 3
+// requirement), or with ?facets=author,decade,tag an object holding the books
+// and the facet counts of every matching book.
 func (h *BookHandler) GetBooks(c *fiber.Ctx) error {
+	return h.listBooks(c, "")
+}
+
+// SearchBooks handles GET /books/search with a required ?q=, matching the
+// books whose title, author or tags contain each word of q, ignoring case.
+// It takes the query params of GET /books and answers like it, facets
+// included.
+func (h *BookHandler) SearchBooks(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return problem.Write(c, problem.ErrBadRequest, "q is required")
+	}
+	return h.listBooks(c, query)
+}
+
+// listBooks answers GET /books, or GET /books/search for a non-empty query.
+func (h *BookHandler) listBooks(c *fiber.Ctx, query string) error {
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
 	filter := domain.BookFilter{
 		Author: c.Query("author"),
-		Page:   1,
-		Limit:  1000,
+		// Tags are stored normalised; see package validation.
+		Tag:   strings.ToLower(strings.Join(strings.Fields(c.Query("tag")), " ")),
+		Query: query,
+		Sort:  c.Query("sort"),
+		Page:  1,
+		Limit: 1000,
+	}
+	if q := c.Query("year"); q != "" {
+		year, err := strconv.Atoi(q)
+		if err != nil || year == 0 {
+			return problem.Write(c, problem.ErrBadRequest, "year must be a non-zero number")
+		}
+		filter.Year = year
 	}
+	if !domain.ValidSort(filter.Sort) {
+		return problem.Write(c, problem.ErrBadRequest, "sort must be one of rating, -rating, reviews, -reviews")
+	}
+	view, err := h.parseView(c)
+	if err != nil {
+		return problem.Write(c, problem.ErrBadRequest, err.Error())
+	}
+	var facetNames []string
+	if q := c.Query("facets"); q != "" {
+		if facetNames, err = parseNames("facets", q, []string{domain.FacetAuthor, domain.FacetDecade, domain.FacetTag}); err != nil {
+			return problem.Write(c, problem.ErrBadRequest, err.Error())
+		}
+	}
+
+	var books []*domain.Book
+	var total int
+	var facets domain.BookFacets
+	if facetNames != nil {
+		books, total, facets, err = h.bookUC.GetBooksFaceted(filter)
+	} else {
+		books, total, err = h.bookUC.GetBooks(filter)
+	}
+	if err != nil {
+		return problem.Write(c, err, "")
+	}
+	resp, err := h.render(books, view)
+	if err != nil {
+		return problem.Write(c, err, "")
+	}
+	if facetNames != nil {
+		return out.send(c, http.StatusOK, h.adapter.BookList(resp, total, facets, facetNames))
+	}
+	return out.send(c, http.StatusOK, resp)
+}
 
-	books, _, err := h.bookUC.GetBooks(filter)
+// GetBookStats handles GET /books/stats with a required ?group_by= and an
+// optional ?author= filter.
+func (h *BookHandler) GetBookStats(c *fiber.Ctx) error {
+	out, err := h.negotiate(c)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return bookError(c, err)
+	}
+	query := domain.BookStatsQuery{GroupBy: c.Query("group_by"), Author: c.Query("author")}
+	if !domain.ValidGroupBy(query.GroupBy) {
+		return problem.Write(c, problem.ErrBadRequest, "group_by must be one of author, year, decade, created_month")
 	}
-	if books == nil {
-		books = []*domain.Book{}
+
+	groups, err := h.bookUC.GetBookStats(query)
+	if err != nil {
+		return problem.Write(c, err, "")
 	}
-	return c.JSON(books)
+	return out.send(c, http.StatusOK, h.adapter.Stats(query.GroupBy, groups))
 }
 
 // UpdateBook handles PUT /books/:id.
 func (h *BookHandler) UpdateBook(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var req createBookRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
 	}
-	if req.Title == "" || req.Author == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "title and author are required"})
+	in, err := h.bodyFormat(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	input, err := h.adapter.DecodeInput(c.Body(), in)
+	if err != nil {
+		return bookError(c, err)
 	}
 
-	book, err := h.bookUC.UpdateBook(id, req.Title, req.Author, req.Year)
-	if err == domain.ErrNotFound {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "book not found"})
+	book, err := h.bookUC.UpdateBook(c.Params("id"), input, middleware.Username(c))
+	if err != nil {
+		return bookError(c, err)
 	}
-	if err == domain.ErrInvalidData {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid data"})
+	return out.send(c, http.StatusOK, h.adapter.Book(book))
+}
+
+// PatchBook handles PATCH /books/:id.
+func (h *BookHandler) PatchBook(c *fiber.Ctx) error {
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	in, err := h.bodyFormat(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	patch, err := h.adapter.DecodePatch(c.Body(), in)
+	if err != nil {
+		return bookError(c, err)
 	}
+	book, err := h.bookUC.PatchBook(c.Params("id"), patch, middleware.Username(c))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return bookError(c, err)
 	}
-	return c.JSON(book)
+	return out.send(c, http.StatusOK, h.adapter.Book(book))
 }
 
-// DeleteBook handles DELETE /books/:id.
+// DeleteBook handles DELETE /books/:id. The book is moved to the trash and can
+// be restored until the purger removes it.
 func (h *BookHandler) DeleteBook(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.bookUC.DeleteBook(id)
-	if err == domain.ErrNotFound {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "book not found"})
-	}
+	err := h.bookUC.DeleteBook(id, middleware.Username(c))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return bookError(c, err)
 	}
 	return c.SendStatus(http.StatusNoContent)
 }
+
+// GetTrash handles GET /books/trash with optional ?author= query param.
+// Returns a bare array of trashed books, like GET /books.
+func (h *BookHandler) GetTrash(c *fiber.Ctx) error {
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	filter := domain.BookFilter{
+		Author: c.Query("author"),
+		Page:   1,
+		Limit:  1000,
+	}
+
+	books, _, err := h.bookUC.GetTrash(filter)
+	if err != nil {
+		return problem.Write(c, err, "")
+	}
+	return out.send(c, http.StatusOK, h.books(books))
+}
+
+// RestoreBook handles POST /books/:id/restore.
+func (h *BookHandler) RestoreBook(c *fiber.Ctx) error {
+	out, err := h.negotiate(c)
+	if err != nil {
+		return bookError(c, err)
+	}
+	book, err := h.bookUC.RestoreBook(c.Params("id"), middleware.Username(c))
+	if errors.Is(err, domain.ErrNotFound) {
+		return problem.Write(c, err, "book not found in trash")
+	}
+	if err != nil {
+		return bookError(c, err)
+	}
+	return out.send(c, http.StatusOK, h.adapter.Book(book))
+}
+
+// responseFormat is the negotiated format of a response.
+type responseFormat struct {
+	serializer.Serializer
+	mediaType string
+}
+
+// negotiate picks the response format from the Accept header. It runs before
+// any change is made, so that a request is not carried out only to be
+// answered with 406.
+func (h *BookHandler) negotiate(c *fiber.Ctx) (responseFormat, error) {
+	c.Vary(fiber.HeaderAccept)
+	s, mediaType, ok := h.formats.Negotiate(c.Get(fiber.HeaderAccept))
+	if !ok {
+		return responseFormat{}, fmt.Errorf("%w: Accept must allow one of %s",
+			problem.ErrNotAcceptable, strings.Join(h.formats.MediaTypes(), ", "))
+	}
+	return responseFormat{Serializer: s, mediaType: mediaType}, nil
+}
+
+// bodyFormat returns the format of the request body named by Content-Type.
+func (h *BookHandler) bodyFormat(c *fiber.Ctx) (serializer.Serializer, error) {
+	s, ok := h.formats.ForContentType(c.Get(fiber.HeaderContentType))
+	if !ok {
+		return nil, fmt.Errorf("%w: Content-Type must be one of %s",
+			domain.ErrUnsupportedMedia, strings.Join(h.formats.MediaTypes(), ", "))
+	}
+	return s, nil
+}
+
+// send writes v in format f.
+func (f responseFormat) send(c *fiber.Ctx, status int, v any) error {
+	body, err := f.Marshal(v)
+	if err != nil {
+		return problem.Write(c, fmt.Errorf("encode %s response: %w", f.mediaType, err), "")
+	}
+	c.Set(fiber.HeaderContentType, f.mediaType)
+	return c.Status(status).Send(body)
+}
+
+// books converts a listing to DTOs; an empty listing is an empty array.
+func (h *BookHandler) books(books []*domain.Book) []any {
+	out := make([]any, len(books))
+	for i, b := range books {
+		out[i] = h.adapter.Book(b)
+	}
+	return out
+}
+
+func bookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, problem.ErrBadRequest):
+		return problem.Write(c, err, "invalid request body")
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "book not found")
+	}
+	return problem.Write(c, err, "")
+}
@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/handler"
+	v1 "github.com/andrimuhayat/crud-test/internal/handler/v1"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestConcurrentReadsDuringUpdates reads a book through GET /books/:id and
+// GET /books while other requests update, patch and roll it back, and checks
+// that every response decodes to a complete book from one written state. Run
+// it with -race, which also reports a book shared between a writer and a
+// reader. The app leaves out middleware that takes a lock, such as the audit
+// log, so that it does not order the requests.
+func TestConcurrentReadsDuringUpdates(t *testing.T) {
+	const writers, readers, rounds = 4, 4, 25
+	bookRepo := memory.NewBookRepository()
+	bookUC := usecase.NewBookUseCase(bookRepo, memory.NewRevisionRepository())
+	authUC := usecase.NewAuthUseCase()
+	reviewUC := usecase.NewReviewUseCase(memory.NewReviewRepository(), bookRepo)
+	bookH := handler.NewBookHandler(bookUC, reviewUC, bookUC, v1.BookAdapter{}, serializer.Default())
+	revisionH := handler.NewRevisionHandler(bookUC, v1.BookAdapter{})
+
+	app := fiber.New(fiber.Config{Immutable: true, DisableStartupMessage: true, ErrorHandler: problem.ErrorHandler})
+	app.Post("/v1/auth/token", handler.NewAuthHandler(authUC).GenerateToken)
+	books := app.Group("/v1/books", middleware.Auth(authUC))
+	books.Post("/", bookH.CreateBook)
+	books.Get("/", bookH.GetBooks)
+	books.Get("/:id", bookH.GetBook)
+	books.Put("/:id", bookH.UpdateBook)
+	books.Patch("/:id", bookH.PatchBook)
+	books.Post("/:id/revisions/:rev/restore", revisionH.RollbackBook)
+
+	var token string
+	send := func(method, path, body string) (int, []byte) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Errorf("%s %s: %v", method, path, err)
+			return 0, nil
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+	_, body := send("POST", "/v1/auth/token", `{"username":"admin","password":"secret"}`)
+	token = jsonField(t, body, "token")
+	_, body = send("POST", "/v1/books", `{"title":"Title 0","author":"Author 0","year":1900,"tags":["tag 0"]}`)
+	id := jsonField(t, body, "id")
+
+	type state struct {
+		ID        string    `json:"id"`
+		Title     string    `json:"title"`
+		Author    string    `json:"author"`
+		Year      int       `json:"year"`
+		Tags      []string  `json:"tags"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	// Every write keeps the title, author, year and tag of the book in step.
+	check := func(s state) {
+		var n int
+		if _, err := fmt.Sscanf(s.Title, "Title %d", &n); err != nil || s.ID != id || s.CreatedAt.IsZero() ||
+			s.Author != fmt.Sprintf("Author %d", n) || s.Year != 1900+n || fmt.Sprint(s.Tags) != fmt.Sprintf("[tag %d]", n) {
+			t.Errorf("torn book: %+v", s)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 1; w <= writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				n := w*rounds + i
+				fields := fmt.Sprintf(`{"title":"Title %d","author":"Author %d","year":%d,"tags":["tag %d"]}`, n, n, 1900+n, n)
+				var status int
+				var body []byte
+				switch i % 3 {
+				case 0:
+					status, body = send("PUT", "/v1/books/"+id, fields)
+				case 1:
+					status, body = send("PATCH", "/v1/books/"+id, fields)
+				default:
+					status, body = send("POST", "/v1/books/"+id+"/revisions/1/restore", "")
+				}
+				if status != http.StatusOK {
+					t.Errorf("write %d: %d %s", n, status, body)
+				}
+			}
+		}()
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				var one state
+				status, body := send("GET", "/v1/books/"+id, "")
+				if status != http.StatusOK || json.Unmarshal(body, &one) != nil {
+					t.Errorf("GET book: %d %s", status, body)
+					continue
+				}
+				check(one)
+
+				var list []state
+				status, body = send("GET", "/v1/books", "")
+				if status != http.StatusOK || json.Unmarshal(body, &list) != nil || len(list) != 1 {
+					t.Errorf("GET books: %d %s", status, body)
+				}
+				for _, s := range list {
+					check(s)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
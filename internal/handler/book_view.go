@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Relations ?include= can embed in a book response.
+const (
+	IncludeReviews   = "reviews"
+	IncludeRevisions = "revisions"
+)
+
+// bookView is the shape a client asked book responses to take:
+//
+//   - ?fields=id,title keeps only the named fields of the version's book DTO,
+//     in the DTO's order. Without it every field is sent.
+//   - ?include=reviews,revisions adds each named relation as an array member
+//     of that name, in the order requested. A book without related
+//     resources gets an empty array.
+//
+// Both take comma-separated names; blanks and repeats are ignored and an
+// unknown name is an error.
+// The zero value is the full, unchanged DTO.
+type bookView struct {
+	fields  []string
+	include []string
+}
+
+// relation loads one kind of related resource for a page of books.
+type relation struct {
+	// element names each resource in XML, inside an element named after
+	// the relation.
+	element string
+	// load returns the resources as DTOs, keyed by book ID.
+	load func(books []*domain.Book) (map[string][]any, error)
+}
+
+// parseView reads ?fields= and ?include=. Its errors are meant for the
+// detail of a bad_request problem.
+func (h *BookHandler) parseView(c *fiber.Ctx) (bookView, error) {
+	var v bookView
+	var err error
+	if q := c.Query("fields"); q != "" {
+		if v.fields, err = parseNames("fields", q, h.fields); err != nil {
+			return bookView{}, err
+		}
+	}
+	if q := c.Query("include"); q != "" {
+		if v.include, err = parseNames("include", q, slices.Sorted(maps.Keys(h.relations))); err != nil {
+			return bookView{}, err
+		}
+	}
+	return v, nil
+}
+
+// parseNames splits a comma-separated list of param, dropping blanks and
+// duplicates, and checks every name against valid.
+func parseNames(param, list string, valid []string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "" || slices.Contains(names, name):
+			continue
+		case !slices.Contains(valid, name):
+			return nil, fmt.Errorf("unknown name %q in %s; valid names are %s", name, param, strings.Join(valid, ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New(param + " must name at least one of " + strings.Join(valid, ", "))
+	}
+	return names, nil
+}
+
+// render converts books to the DTOs of view, loading each included relation
+// for all of them at once.
+func (h *BookHandler) render(books []*domain.Book, view bookView) ([]any, error) {
+	out := make([]any, len(books))
+	if view.fields == nil && view.include == nil {
+		for i, b := range books {
+			out[i] = h.adapter.Book(b)
+		}
+		return out, nil
+	}
+
+	related := make([]map[string][]any, len(view.include))
+	for i, name := range view.include {
+		m, err := h.relations[name].load(books)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", name, err)
+		}
+		related[i] = m
+	}
+
+	typ, source := h.viewType(view)
+	for i, b := range books {
+		dto := reflect.ValueOf(h.adapter.Book(b)).Elem()
+		v := reflect.New(typ).Elem()
+		for j, k := range source {
+			v.Field(j).Set(dto.Field(k))
+		}
+		for j, m := range related {
+			items := m[b.ID]
+			if items == nil {
+				items = []any{}
+			}
+			v.Field(len(source) + j).Set(reflect.ValueOf(items))
+		}
+		out[i] = v.Addr().Interface()
+	}
+	return out, nil
+}
+
+// viewType builds the struct type of view's responses: the selected fields of
+// the DTO, its XMLName included, followed by one []any field per relation.
+// The tags are those of the DTO, so every serializer encodes the fields it
+// keeps as it encodes the DTO. source holds the DTO index of each kept field.
+func (h *BookHandler) viewType(view bookView) (reflect.Type, []int) {
+	var fields []reflect.StructField
+	var source []int
+	for i := 0; i < h.dto.NumField(); i++ {
+		f := h.dto.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Name == "XMLName" || view.fields == nil || slices.Contains(view.fields, name) {
+			fields = append(fields, f)
+			source = append(source, i)
+		}
+	}
+	for _, name := range view.include {
+		fields = append(fields, reflect.StructField{
+			Name: "Include" + strings.ToUpper(name[:1]) + name[1:],
+			Type: reflect.TypeFor[[]any](),
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" xml:"%s>%s"`, name, name, h.relations[name].element)),
+		})
+	}
+	return reflect.StructOf(fields), source
+}
+
+// jsonNames lists the JSON names of the exported fields of struct type t.
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (h *BookHandler) loadReviews(books []*domain.Book) (map[string][]any, error) {
+	ids := make([]string, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+	byBook, err := h.reviewUC.GetReviewsByBooks(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]any, len(byBook))
+	for id, reviews := range byBook {
+		items := make([]any, len(reviews))
+		for i, r := range reviews {
+			items[i] = h.adapter.Review(r)
+		}
+		out[id] = items
+	}
+	return out, nil
+}
+
+// loadRevisions lists each book's history; there is no batch lookup, so it
+// costs one use-case call per book.
+func (h *BookHandler) loadRevisions(books []*domain.Book) (map[string][]any, error) {
+	out := make(map[string][]any, len(books))
+	for _, b := range books {
+		revs, err := h.revisionUC.GetRevisions(b.ID)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]any, len(revs))
+		for i, rev := range revs {
+			items[i] = h.adapter.Revision(rev)
+		}
+		out[b.ID] = items
+	}
+	return out, nil
+}
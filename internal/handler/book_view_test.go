@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestBookViews verifies that ?fields= projects book responses in the DTO's
+// field order, that ?include= embeds reviews and revisions on the list and
+// the single book alike, in JSON and XML, and that unknown names are
+// rejected.
+func TestBookViews(t *testing.T) {
+	app := newApp(t)
+	var token string
+	send := func(method, path, accept, body string) (int, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+	_, body := send("POST", "/v1/auth/token", "", `{"username":"admin","password":"secret"}`)
+	token = jsonField(t, body, "token")
+	_, body = send("POST", "/v1/books", "", `{"title":"Dune","author":"Frank Herbert","year":1965}`)
+	id := jsonField(t, body, "id")
+	send("POST", "/v1/books", "", `{"title":"Emma","author":"Jane Austen"}`)
+	send("POST", "/v1/books/"+id+"/reviews", "", `{"rating":5,"body":"Spice."}`)
+
+	if status, body := send("GET", "/v1/books?fields=title,+id,title", "", ""); status != http.StatusOK ||
+		!strings.HasPrefix(string(body), `[{"id":"`+id+`","title":"Dune"},{"id":`) {
+		t.Errorf("fields=title,id: %d %s", status, body)
+	}
+
+	var list []struct {
+		Title     string            `json:"title"`
+		Author    *string           `json:"author"`
+		Reviews   []json.RawMessage `json:"reviews"`
+		Revisions []json.RawMessage `json:"revisions"`
+	}
+	_, body = send("GET", "/v1/books?fields=title&include=reviews,revisions", "", "")
+	if err := json.Unmarshal(body, &list); err != nil || len(list) != 2 {
+		t.Fatalf("include: %v %s", err, body)
+	}
+	for i, want := range []int{1, 0} {
+		b := list[i]
+		if b.Author != nil || len(b.Reviews) != want || b.Reviews == nil || len(b.Revisions) != 1 {
+			t.Errorf("%s: author %v, %d reviews, %d revisions; want no author, %d reviews, 1 revision",
+				b.Title, b.Author, len(b.Reviews), len(b.Revisions), want)
+		}
+	}
+
+	var one struct {
+		XMLName xml.Name `xml:"book"`
+		ID      string   `xml:"id"`
+		Title   string   `xml:"title"`
+		Ratings []int    `xml:"reviews>review>rating"`
+	}
+	_, body = send("GET", "/v1/books/"+id+"?fields=id&include=reviews", fiber.MIMEApplicationXML, "")
+	if err := xml.Unmarshal(body, &one); err != nil || one.ID != id || one.Title != "" || len(one.Ratings) != 1 || one.Ratings[0] != 5 {
+		t.Errorf("XML: %+v, %v: %s", one, err, body)
+	}
+
+	for _, path := range []string{"/v1/books?fields=price", "/v1/books?fields=,", "/v1/books/" + id + "?include=author"} {
+		if status, body := send("GET", path, "", ""); status != http.StatusBadRequest || jsonField(t, body, "code") != "bad_request" {
+			t.Errorf("%s: %d %s", path, status, body)
+		}
+	}
+}
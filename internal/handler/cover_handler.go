@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// coverCacheControl lets clients keep covers but revalidate on every use: the
+// URL of a book's cover stays the same when a new cover is uploaded, and the
+// ETag makes revalidation a cheap 304.
+const coverCacheControl = "private, max-age=0, must-revalidate"
+
+// CoverHandler handles cover image upload and download for books.
+type CoverHandler struct {
+	coverUC domain.CoverUseCase
+}
+
+// NewCoverHandler wires the handler to the cover use-case.
+func NewCoverHandler(coverUC domain.CoverUseCase) *CoverHandler {
+	return &CoverHandler{coverUC: coverUC}
+}
+
+// UploadCover handles PUT /books/:id/cover.
+// The image is read either from the raw request body or, for
+// multipart/form-data requests, from the "cover" form field. The format is
+// sniffed from the bytes; the declared Content-Type is not trusted.
+func (h *CoverHandler) UploadCover(c *fiber.Ctx) error {
+	data, err := coverUpload(c)
+	if err != nil {
+		return coverError(c, err)
+	}
+
+	cover, err := h.coverUC.UploadCover(c.Params("id"), data)
+	if err != nil {
+		return coverError(c, err)
+	}
+	return c.JSON(cover)
+}
+
+// GetCover handles GET /books/:id/cover?size=original|small|medium|large.
+func (h *CoverHandler) GetCover(c *fiber.Ctx) error {
+	img, data, updatedAt, err := h.coverUC.GetCover(c.Params("id"), c.Query("size"))
+	if err != nil {
+		return coverError(c, err)
+	}
+
+	etag := `"` + img.Key + `"`
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, coverCacheControl)
+	c.Set(fiber.HeaderLastModified, updatedAt.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderVary, fiber.HeaderAuthorization)
+
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		if etagMatches(match, etag) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+	} else if since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince)); err == nil && !updatedAt.Truncate(1e9).After(since) {
+		return c.SendStatus(http.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, img.ContentType)
+	return c.Send(data)
+}
+
+// coverUpload extracts the uploaded bytes, refusing anything larger than
+// domain.MaxCoverSize before reading it fully.
+func coverUpload(c *fiber.Ctx) ([]byte, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return c.Body(), nil
+	}
+
+	fh, err := c.FormFile("cover")
+	if err != nil {
+		return nil, domain.ErrInvalidData
+	}
+	if fh.Size > domain.MaxCoverSize {
+		return nil, domain.ErrTooLarge
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, domain.MaxCoverSize+1))
+}
+
+// etagMatches implements the weak comparison of an If-None-Match header value.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func coverError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "book or cover not found")
+	case errors.Is(err, domain.ErrInvalidData):
+		return problem.Write(c, err, "invalid image or size; size must be original, small, medium or large")
+	case errors.Is(err, domain.ErrTooLarge):
+		return problem.Write(c, err, "cover must be at most 5 MiB and 8000x8000 pixels")
+	case errors.Is(err, domain.ErrUnsupportedMedia):
+		return problem.Write(c, err, "cover must be a JPEG, PNG or WebP image")
+	}
+	return problem.Write(c, err, "")
+}
@@ -0,0 +1,92 @@
+package handler_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCoverCaching uploads a cover with a misleading Content-Type and checks
+// the caching headers of GET /books/:id/cover and its conditional requests.
+func TestCoverCaching(t *testing.T) {
+	app := newApp(t)
+	var token string
+	send := func(method, path string, body []byte, header map[string]string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	read := func(resp *http.Response) []byte {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return b
+	}
+
+	token = jsonField(t, read(send("POST", "/v1/auth/token", []byte(`{"username":"admin","password":"secret"}`), nil)), "token")
+	id := jsonField(t, read(send("POST", "/v1/books", []byte(`{"title":"Dune","author":"Frank Herbert"}`), nil)), "id")
+	cover := "/v1/books/" + id + "/cover"
+
+	// The bytes are sniffed, so a wrong Content-Type does not matter.
+	if resp := send("PUT", cover, pngImage(t), map[string]string{fiber.HeaderContentType: "text/plain"}); resp.StatusCode != 200 {
+		t.Fatalf("upload: got %d %s", resp.StatusCode, read(resp))
+	}
+	if resp := send("PUT", cover, []byte("GIF89a not really"), map[string]string{fiber.HeaderContentType: "image/png"}); resp.StatusCode != 415 {
+		t.Errorf("upload of non-image: got %d, want 415", resp.StatusCode)
+	}
+
+	resp := send("GET", cover+"?size=small", nil, nil)
+	body := read(resp)
+	etag := resp.Header.Get(fiber.HeaderETag)
+	if resp.StatusCode != 200 || len(body) == 0 || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("GET: got %d, %d bytes, ETag %q", resp.StatusCode, len(body), etag)
+	}
+	for header, want := range map[string]string{
+		fiber.HeaderContentType:  "image/png",
+		fiber.HeaderCacheControl: "private, max-age=0, must-revalidate",
+		fiber.HeaderVary:         fiber.HeaderAuthorization,
+	} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("%s: got %q, want %q", header, got, want)
+		}
+	}
+	if resp.Header.Get(fiber.HeaderLastModified) == "" {
+		t.Error("Last-Modified is missing")
+	}
+
+	for match, want := range map[string]int{
+		etag:                 http.StatusNotModified,
+		`"other", W/` + etag: http.StatusNotModified,
+		"*":                  http.StatusNotModified,
+		`"other"`:            http.StatusOK,
+	} {
+		resp := send("GET", cover+"?size=small", nil, map[string]string{fiber.HeaderIfNoneMatch: match})
+		if body := read(resp); resp.StatusCode != want || (want == http.StatusNotModified && len(body) != 0) {
+			t.Errorf("If-None-Match %s: got %d with %d bytes, want %d", match, resp.StatusCode, len(body), want)
+		}
+	}
+	if resp := send("GET", cover+"?size=original", nil, map[string]string{fiber.HeaderIfNoneMatch: etag}); resp.StatusCode != http.StatusOK {
+		t.Errorf("If-None-Match of another size: got %d, want 200", resp.StatusCode)
+	}
+	if resp := send("GET", cover+"?size=huge", nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown size: got %d, want 400", resp.StatusCode)
+	}
+
+	send("DELETE", "/v1/books/"+id, nil, nil)
+	if resp := send("GET", cover, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("cover of trashed book: got %d, want 404", resp.StatusCode)
+	}
+}
@@ -1,6 +1,9 @@
 package handler
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
 
 // EchoHandler echoes the request JSON body back to the caller.
 type EchoHandler struct{}
@@ -19,7 +22,7 @@ func NewEchoHandler() *EchoHandler { return &EchoHandler{} }
 func (h *EchoHandler) Echo(c *fiber.Ctx) error {
 	body := c.Body()
 	if len(body) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "empty body"})
+		return problem.Write(c, problem.ErrBadRequest, "empty body")
 	}
 	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
 	return c.Send(body)
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseHeartbeat is how often an idle stream sends a comment line, keeping
+// proxies from closing the connection and detecting clients that went away.
+const sseHeartbeat = 15 * time.Second
+
+// EventHandler streams book change events to clients.
+type EventHandler struct {
+	stream domain.BookEventStream
+}
+
+// NewEventHandler wires the handler to the event stream.
+func NewEventHandler(stream domain.BookEventStream) *EventHandler {
+	return &EventHandler{stream: stream}
+}
+
+// StreamBooks handles GET /books/events as a Server-Sent Events stream.
+// Each event's SSE id is its sequence number, so a reconnecting client sends
+// it back in Last-Event-ID (or ?last_event_id=) and receives whatever it
+// missed that is still in the replay buffer. If the buffer no longer reaches
+// back that far, a "reset" event tells the client to refetch GET /books.
+// ?author= limits the stream to books by that author.
+func (h *EventHandler) StreamBooks(c *fiber.Ctx) error {
+	lastID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			return problem.Write(c, problem.ErrBadRequest, "Last-Event-ID must be a sequence number")
+		}
+	}
+	author := c.Query("author")
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub := h.stream.Subscribe(after)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		if sub.Gap() {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		} else {
+			fmt.Fprint(w, ": connected\n\n")
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					// Dropped as a slow consumer; the client reconnects
+					// with Last-Event-ID and resumes from the buffer.
+					return
+				}
+				if author != "" && (event.Book == nil || event.Book.Author != author) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/andrimuhayat/crud-test/internal/graphql"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GraphQLHandler serves the GraphQL endpoint.
+type GraphQLHandler struct {
+	server *graphql.Server
+}
+
+// NewGraphQLHandler wires the handler to a GraphQL server.
+func NewGraphQLHandler(server *graphql.Server) *GraphQLHandler {
+	return &GraphQLHandler{server: server}
+}
+
+// Query handles POST /graphql. A request the server could read is answered
+// with 200 and a GraphQL response, errors included; only a body that is not a
+// GraphQL request at all gets a problem.
+func (h *GraphQLHandler) Query(c *fiber.Ctx) error {
+	var req graphql.Request
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+	if req.Query == "" {
+		return problem.Write(c, problem.ErrBadRequest, "query is required")
+	}
+	return c.JSON(h.server.Execute(c.UserContext(), middleware.Username(c), req))
+}
@@ -0,0 +1,128 @@
+package handler_test
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/andrimuhayat/crud-test/internal/handler/v1"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestBookFormatsRoundTrip creates, patches, fetches and lists a book in each
+// supported format, decoding every response with the serializer of its
+// Content-Type, and checks the 406 and 415 responses.
+func TestBookFormatsRoundTrip(t *testing.T) {
+	app := newApp(t)
+	send := func(method, path string, header map[string]string, body []byte) (*http.Response, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+	_, body := send("POST", "/v1/auth/token", map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationJSON},
+		[]byte(`{"username":"admin","password":"secret"}`))
+	auth := "Bearer " + jsonField(t, body, "token")
+
+	formats := serializer.Default()
+	for _, mediaType := range formats.MediaTypes() {
+		t.Run(mediaType, func(t *testing.T) {
+			s, _ := formats.ForContentType(mediaType)
+			header := map[string]string{
+				fiber.HeaderAuthorization: auth,
+				fiber.HeaderContentType:   mediaType,
+				fiber.HeaderAccept:        mediaType,
+			}
+			decode := func(resp *http.Response, body []byte, wantStatus int, v any) {
+				t.Helper()
+				if resp.StatusCode != wantStatus {
+					t.Fatalf("status %d, want %d: %s", resp.StatusCode, wantStatus, body)
+				}
+				if got, _, _ := mime.ParseMediaType(resp.Header.Get(fiber.HeaderContentType)); got != mediaType {
+					t.Fatalf("Content-Type %q, want %q", got, mediaType)
+				}
+				if resp.Header.Get(fiber.HeaderVary) != fiber.HeaderAccept {
+					t.Errorf("Vary: got %q", resp.Header.Get(fiber.HeaderVary))
+				}
+				if err := s.Unmarshal(body, v); err != nil {
+					t.Fatalf("decode %q: %v", body, err)
+				}
+			}
+
+			in, err := s.Marshal(v1.BookRequest{Title: " Dune ", Author: "Frank Herbert", Year: 1965, ISBN: "978-0-441-17271-9"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var created v1.Book
+			resp, body := send("POST", "/v1/books", header, in)
+			decode(resp, body, http.StatusCreated, &created)
+			if created.ID == "" || created.Title != "Dune" || created.Year != 1965 || created.ISBN != "9780441172719" || created.CreatedAt.IsZero() {
+				t.Errorf("created: %+v", created)
+			}
+
+			title := "Dune Messiah " + mediaType
+			patch, _ := s.Marshal(v1.BookPatchRequest{Title: &title})
+			var patched v1.Book
+			resp, body = send("PATCH", "/v1/books/"+created.ID, header, patch)
+			decode(resp, body, http.StatusOK, &patched)
+			if patched.Title != title || patched.Author != "Frank Herbert" || patched.Year != 1965 {
+				t.Errorf("patched: %+v", patched)
+			}
+
+			var got v1.Book
+			resp, body = send("GET", "/v1/books/"+created.ID, header, nil)
+			decode(resp, body, http.StatusOK, &got)
+			if got.ID != created.ID || got.Title != title || !got.CreatedAt.Equal(created.CreatedAt) {
+				t.Errorf("got %+v, want %+v", got, patched)
+			}
+
+			var list []v1.Book
+			resp, body = send("GET", "/v1/books?author=Frank%20Herbert", header, nil)
+			if mediaType == serializer.MediaTypeXML {
+				var items struct {
+					Books []v1.Book `xml:"book"`
+				}
+				decode(resp, body, http.StatusOK, &items)
+				list = items.Books
+			} else {
+				decode(resp, body, http.StatusOK, &list)
+			}
+			found := false
+			for _, b := range list {
+				found = found || (b.ID == created.ID && b.Title == title)
+			}
+			if !found {
+				t.Errorf("list does not contain %s: %+v", created.ID, list)
+			}
+
+			// A validation error is problem+json whatever the format.
+			invalid, _ := s.Marshal(v1.BookRequest{Author: "x"})
+			resp, body = send("POST", "/v1/books", header, invalid)
+			if got, _, _ := mime.ParseMediaType(resp.Header.Get(fiber.HeaderContentType)); resp.StatusCode != http.StatusBadRequest || got != problem.ContentType {
+				t.Errorf("invalid body: %d %q %s", resp.StatusCode, got, body)
+			}
+		})
+	}
+
+	resp, body := send("GET", "/v1/books", map[string]string{fiber.HeaderAuthorization: auth, fiber.HeaderAccept: "text/csv"}, nil)
+	if resp.StatusCode != http.StatusNotAcceptable || jsonField(t, body, "code") != "not_acceptable" {
+		t.Errorf("Accept text/csv: %d %s", resp.StatusCode, body)
+	}
+	resp, body = send("PUT", "/v1/books/x", map[string]string{fiber.HeaderAuthorization: auth, fiber.HeaderContentType: "text/plain"}, []byte("Dune"))
+	if resp.StatusCode != http.StatusUnsupportedMediaType || jsonField(t, body, "code") != "unsupported_media_type" {
+		t.Errorf("Content-Type text/plain: %d %s", resp.StatusCode, body)
+	}
+}
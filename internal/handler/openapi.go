@@ -0,0 +1,618 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strconv"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/graphql"
+	v1 "github.com/andrimuhayat/crud-test/internal/handler/v1"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/openapi"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/gofiber/fiber/v2"
+)
+
+// v1Prefix is the path prefix of API version 1.
+const v1Prefix = "/v1"
+
+// bearerAuth is the name of the JWT security scheme in the OpenAPI document.
+const bearerAuth = "bearerAuth"
+
+//go:embed openapi_docs.html
+var openAPIDocsPage []byte
+
+// Response bodies built with fiber.Map, described for the OpenAPI document.
+type (
+	// problemDetails gives problem.Details its schema name.
+	problemDetails problem.Details
+	pingResponse   struct {
+		Success bool `json:"success"`
+	}
+	tokenResponse struct {
+		Token string `json:"token"`
+	}
+	// graphQLRequest and graphQLResponse give the GraphQL types their
+	// schema names.
+	graphQLRequest  graphql.Request
+	graphQLResponse graphql.Response
+)
+
+// OpenAPIHandler serves the OpenAPI document and a browsable rendering of it.
+type OpenAPIHandler struct {
+	spec []byte
+}
+
+// NewOpenAPIHandler encodes the document returned by OpenAPI once.
+func NewOpenAPIHandler() *OpenAPIHandler {
+	spec, err := json.Marshal(OpenAPI())
+	if err != nil {
+		panic("encode OpenAPI document: " + err.Error())
+	}
+	return &OpenAPIHandler{spec: spec}
+}
+
+// Spec handles GET /openapi.json.
+func (h *OpenAPIHandler) Spec(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Send(h.spec)
+}
+
+// Docs handles GET /docs with a self-contained page that renders /openapi.json.
+func (h *OpenAPIHandler) Docs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(openAPIDocsPage)
+}
+
+// OpenAPI describes /ping, /echo, /problems, /graphql and API version 1:
+// /v1/auth/token and every /v1/books route. The deprecated unversioned aliases of the v1
+// routes are left out. Errors are RFC 9457 problem details. Schemas are
+// derived from the v1 DTOs, the domain types and the request structs the
+// handlers use, so a field added to any of them shows up in the document
+// without editing it here.
+func OpenAPI() *openapi.Document {
+	g := openapi.NewGenerator()
+	var (
+		book     = g.Schema(v1.Book{})
+		bookIn   = g.Schema(v1.BookRequest{})
+		patch    = g.Schema(v1.BookPatchRequest{})
+		books    = arrayOf(book)
+		revision = g.Schema(v1.Revision{})
+		diff     = g.Schema(domain.RevisionDiff{})
+		cover    = g.Schema(domain.Cover{})
+		review   = g.Schema(domain.Review{})
+		reviewIn = g.Schema(reviewRequest{})
+		token    = g.Schema(tokenRequest{})
+	)
+	g.Schema(problemDetails{})
+
+	// BookView is a book shaped by ?fields= and ?include=: any of Book's
+	// fields plus the included relations. v1.Review encodes like
+	// domain.Review, so the embedded reviews share its schema.
+	bookView := *g.Schemas["Book"]
+	bookView.Required = nil
+	bookView.Properties = maps.Clone(bookView.Properties)
+	bookView.Properties[IncludeReviews] = arrayOf(review)
+	bookView.Properties[IncludeRevisions] = arrayOf(revision)
+	g.Schemas["BookView"] = &bookView
+	shapedBook := &openapi.Schema{AnyOf: []*openapi.Schema{book, openapi.Ref("BookView")}}
+	// A listing with ?facets= wraps the books, which are shaped like any
+	// other listing's.
+	bookList := g.Schema(v1.BookList{})
+	g.Schemas["BookList"].Properties["books"] = arrayOf(shapedBook)
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   "API Quest",
+			Version: "1.0.0",
+			Description: "Book catalogue with reviews, revision history, covers and a change feed. " +
+				"Every /v1 route is also served without the prefix; those aliases are deprecated " +
+				"and answer with Deprecation, Sunset and Link headers.",
+		},
+		Components: openapi.Components{
+			Schemas: g.Schemas,
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				bearerAuth: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Token from POST /v1/auth/token.",
+				},
+			},
+		},
+	}
+
+	// --- Public ---
+	doc.Add(http.MethodGet, "/ping", &openapi.Operation{
+		OperationID: "ping",
+		Summary:     "Health check",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, jsonResponse("Service is up.", g.Schema(pingResponse{}))),
+	})
+	doc.Add(http.MethodPost, "/echo", &openapi.Operation{
+		OperationID: "echo",
+		Summary:     "Echo the request body byte for byte",
+		Tags:        []string{"system"},
+		RequestBody: jsonBody(&openapi.Schema{}),
+		Responses: responses(
+			http.StatusOK, jsonResponse("The request body, unchanged.", &openapi.Schema{}),
+			http.StatusBadRequest, errorResponseFor("The body is empty."),
+		),
+	})
+	doc.Add(http.MethodPost, v1Prefix+"/auth/token", &openapi.Operation{
+		OperationID: "createToken",
+		Summary:     "Exchange credentials for a JWT valid for 24 hours",
+		Tags:        []string{"auth"},
+		RequestBody: jsonBody(token),
+		Responses: responses(
+			http.StatusOK, jsonResponse("A signed token.", g.Schema(tokenResponse{})),
+			http.StatusBadRequest, errorResponseFor("Username or password is missing."),
+			http.StatusUnauthorized, errorResponseFor("Invalid credentials."),
+		),
+	})
+
+	problemTypeSchema := g.Schema(problemType{})
+	doc.Add(http.MethodGet, "/problems", &openapi.Operation{
+		OperationID: "listProblemTypes",
+		Summary:     "List the problem types error responses refer to",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, jsonResponse("Every problem type.", arrayOf(problemTypeSchema))),
+	})
+	doc.Add(http.MethodGet, "/problems/{code}", &openapi.Operation{
+		OperationID: "getProblemType",
+		Summary:     "Describe the problem type of an error response's type URI",
+		Tags:        []string{"system"},
+		Parameters:  []*openapi.Parameter{pathParam("code", "Problem code, e.g. not_found.")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("The problem type.", problemTypeSchema),
+			http.StatusNotFound, errorResponseFor("Unknown code."),
+		),
+	})
+
+	// --- Books ---
+	authorQuery := queryParam("author", "Only books by this author.", false, str())
+	bookID := pathParam("id", "Book ID.")
+	fieldsQuery := queryParam("fields", "Comma-separated book fields to return, e.g. id,title. Defaults to all.", false, str())
+	includeQuery := queryParam("include", "Comma-separated relations to embed: "+IncludeReviews+", "+IncludeRevisions+".", false, str())
+	revNumber := &openapi.Parameter{Name: "rev", In: "path", Required: true, Description: "Revision number.", Schema: integer()}
+	reviewID := pathParam("reviewID", "Review ID.")
+
+	secure := func(op *openapi.Operation) *openapi.Operation {
+		op.Security = []openapi.SecurityRequirement{{bearerAuth: {}}}
+		op.Responses[strconv.Itoa(http.StatusUnauthorized)] = errorResponseFor("Missing, malformed or expired token.")
+		return op
+	}
+	add := func(method, path string, op *openapi.Operation) {
+		if method == http.MethodPost || method == http.MethodPatch {
+			idempotent(op)
+		}
+		doc.Add(method, v1Prefix+path, secure(op))
+	}
+	// The book endpoints read and write every format of the serializer
+	// registry; their errors are problem+json regardless.
+	formats := serializer.Default().MediaTypes()
+	negotiated := func(op *openapi.Operation) *openapi.Operation {
+		op.Responses[strconv.Itoa(http.StatusNotAcceptable)] = errorResponseFor("Accept allows none of the supported formats.")
+		if op.RequestBody != nil {
+			op.Responses[strconv.Itoa(http.StatusUnsupportedMediaType)] = errorResponseFor("Content-Type is not a supported format.")
+		}
+		return op
+	}
+
+	add(http.MethodPost, "/books", negotiated(&openapi.Operation{
+		OperationID: "createBook",
+		Summary:     "Create a book",
+		Tags:        []string{"books"},
+		RequestBody: formatBody(formats, bookIn),
+		Responses: responses(
+			http.StatusCreated, formatResponse(formats, "The created book.", book),
+			http.StatusBadRequest, errorResponseFor("Invalid body or fields; errors lists every invalid field."),
+		),
+	}))
+	// GET /books/search takes the query params of GET /books and answers
+	// like it.
+	listParams := []*openapi.Parameter{
+		authorQuery,
+		queryParam("year", "Only books published this year.", false, integer()),
+		queryParam("tag", "Only books with this tag, matched as stored: lower case, inner whitespace collapsed.", false, str()),
+		queryParam("sort", "Sort by average rating or review count; prefix with - for descending.", false,
+			enum(domain.SortByRating, "-"+domain.SortByRating, domain.SortByReviews, "-"+domain.SortByReviews)),
+		fieldsQuery,
+		includeQuery,
+		queryParam("facets", "Comma-separated facets to count the matching books by: "+domain.FacetAuthor+", "+domain.FacetDecade+", "+domain.FacetTag+
+			". The response becomes an object holding the books, their total and the counts.", false, str()),
+	}
+	listResponse := formatResponse(formats, "Books in insertion order unless sorted, or with facets a BookList.",
+		&openapi.Schema{AnyOf: []*openapi.Schema{arrayOf(shapedBook), bookList}})
+	add(http.MethodGet, "/books", negotiated(&openapi.Operation{
+		OperationID: "listBooks",
+		Summary:     "List live books",
+		Tags:        []string{"books"},
+		Parameters:  listParams,
+		Responses: responses(
+			http.StatusOK, listResponse,
+			http.StatusBadRequest, errorResponseFor("Invalid year, or unknown sort key, field, relation or facet."),
+		),
+	}))
+	add(http.MethodGet, "/books/search", negotiated(&openapi.Operation{
+		OperationID: "searchBooks",
+		Summary:     "Search live books",
+		Description: "Matches the books whose title, author or tags contain each word of q, ignoring case.",
+		Tags:        []string{"books"},
+		Parameters: append([]*openapi.Parameter{
+			queryParam("q", "Words to search for.", true, str()),
+		}, listParams...),
+		Responses: responses(
+			http.StatusOK, listResponse,
+			http.StatusBadRequest, errorResponseFor("Missing q, invalid year, or unknown sort key, field, relation or facet."),
+		),
+	}))
+	add(http.MethodGet, "/books/trash", negotiated(&openapi.Operation{
+		OperationID: "listTrash",
+		Summary:     "List trashed books",
+		Tags:        []string{"trash"},
+		Parameters:  []*openapi.Parameter{authorQuery},
+		Responses:   responses(http.StatusOK, formatResponse(formats, "Trashed books.", books)),
+	}))
+	add(http.MethodGet, "/books/stats", negotiated(&openapi.Operation{
+		OperationID: "getBookStats",
+		Summary:     "Count live books per author, year, decade or month of creation",
+		Description: "Groups are ordered by key. Books without a year are counted in a group with an empty key when grouping by year or decade.",
+		Tags:        []string{"books"},
+		Parameters: []*openapi.Parameter{
+			queryParam("group_by", "What to group the books by.", true,
+				enum(domain.GroupByAuthor, domain.GroupByYear, domain.GroupByDecade, domain.GroupByCreatedMonth)),
+			authorQuery,
+		},
+		Responses: responses(
+			http.StatusOK, formatResponse(formats, "Book count and year range of each group.", g.Schema(v1.BookStats{})),
+			http.StatusBadRequest, errorResponseFor("Missing or unknown group_by."),
+		),
+	}))
+	add(http.MethodGet, "/books/events", &openapi.Operation{
+		OperationID: "streamBookEvents",
+		Summary:     "Stream book changes as Server-Sent Events",
+		Description: "Each event's id is its sequence number; send it back in Last-Event-ID to resume.",
+		Tags:        []string{"events"},
+		Parameters: []*openapi.Parameter{
+			authorQuery,
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this sequence number.", Schema: integer()},
+			queryParam("last_event_id", "Same as Last-Event-ID, for clients that cannot set headers.", false, integer()),
+		},
+		Responses: responses(
+			http.StatusOK, &openapi.Response{
+				Description: "An endless event stream.",
+				Content:     map[string]openapi.MediaType{"text/event-stream": {Schema: str()}},
+			},
+			http.StatusBadRequest, errorResponseFor("Last-Event-ID is not a sequence number."),
+		),
+	})
+	add(http.MethodGet, "/books/{id}", negotiated(&openapi.Operation{
+		OperationID: "getBook",
+		Summary:     "Get a live book",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID, fieldsQuery, includeQuery},
+		Responses: responses(
+			http.StatusOK, formatResponse(formats, "The book.", shapedBook),
+			http.StatusBadRequest, errorResponseFor("Unknown field or relation."),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+		),
+	}))
+	add(http.MethodPut, "/books/{id}", negotiated(&openapi.Operation{
+		OperationID: "updateBook",
+		Summary:     "Replace a book's title, author, year and ISBN",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: formatBody(formats, bookIn),
+		Responses: responses(
+			http.StatusOK, formatResponse(formats, "The updated book.", book),
+			http.StatusBadRequest, errorResponseFor("Invalid body or fields; errors lists every invalid field."),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+		),
+	}))
+	add(http.MethodPatch, "/books/{id}", negotiated(&openapi.Operation{
+		OperationID: "patchBook",
+		Summary:     "Change some of a book's fields",
+		Description: "Absent or null fields are left unchanged.",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: formatBody(formats, patch),
+		Responses: responses(
+			http.StatusOK, formatResponse(formats, "The updated book.", book),
+			http.StatusBadRequest, errorResponseFor("Invalid body or fields; errors lists every invalid field."),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+		),
+	}))
+	add(http.MethodDelete, "/books/{id}", &openapi.Operation{
+		OperationID: "deleteBook",
+		Summary:     "Move a book to the trash",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: responses(
+			http.StatusNoContent, &openapi.Response{Description: "Trashed."},
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+		),
+	})
+	add(http.MethodPost, "/books/{id}/restore", negotiated(&openapi.Operation{
+		OperationID: "restoreBook",
+		Summary:     "Take a book out of the trash",
+		Tags:        []string{"trash"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: responses(
+			http.StatusOK, formatResponse(formats, "The restored book.", book),
+			http.StatusNotFound, errorResponseFor("No trashed book with this ID."),
+		),
+	}))
+
+	add(http.MethodGet, "/books/{id}/revisions", &openapi.Operation{
+		OperationID: "listRevisions",
+		Summary:     "List a book's revisions, oldest first",
+		Tags:        []string{"revisions"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Revisions.", arrayOf(revision)),
+			http.StatusNotFound, errorResponseFor("The book has no history."),
+		),
+	})
+	add(http.MethodGet, "/books/{id}/revisions/diff", &openapi.Operation{
+		OperationID: "diffRevisions",
+		Summary:     "Compare the book state after two revisions",
+		Tags:        []string{"revisions"},
+		Parameters: []*openapi.Parameter{
+			bookID,
+			queryParam("from", "Older revision number.", true, integer()),
+			queryParam("to", "Newer revision number.", true, integer()),
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Field-level changes.", diff),
+			http.StatusBadRequest, errorResponseFor("from or to is missing or not a number."),
+			http.StatusNotFound, errorResponseFor("Book or revision not found."),
+		),
+	})
+	add(http.MethodGet, "/books/{id}/revisions/{rev}", &openapi.Operation{
+		OperationID: "getRevision",
+		Summary:     "Get one revision",
+		Tags:        []string{"revisions"},
+		Parameters:  []*openapi.Parameter{bookID, revNumber},
+		Responses: responses(
+			http.StatusOK, jsonResponse("The revision.", revision),
+			http.StatusBadRequest, errorResponseFor("rev is not a number."),
+			http.StatusNotFound, errorResponseFor("Book or revision not found."),
+		),
+	})
+	add(http.MethodPost, "/books/{id}/revisions/{rev}/restore", &openapi.Operation{
+		OperationID: "rollbackBook",
+		Summary:     "Restore a book to its state after a revision",
+		Tags:        []string{"revisions"},
+		Parameters:  []*openapi.Parameter{bookID, revNumber},
+		Responses: responses(
+			http.StatusOK, jsonResponse("The book after the rollback.", book),
+			http.StatusBadRequest, errorResponseFor("rev is not a number, or the revision has no book state."),
+			http.StatusNotFound, errorResponseFor("Book or revision not found."),
+		),
+	})
+
+	image := &openapi.Schema{Type: openapi.Types{"string"}, Format: "binary"}
+	add(http.MethodPut, "/books/{id}/cover", &openapi.Operation{
+		OperationID: "uploadCover",
+		Summary:     "Upload a cover image and generate thumbnails",
+		Description: "The format is sniffed from the bytes; the declared content type is not trusted.",
+		Tags:        []string{"covers"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"image/jpeg": {Schema: image},
+				"image/png":  {Schema: image},
+				"image/webp": {Schema: image},
+				fiber.MIMEMultipartForm: {Schema: &openapi.Schema{
+					Type:       openapi.Types{"object"},
+					Properties: map[string]*openapi.Schema{"cover": image},
+					Required:   []string{"cover"},
+				}},
+			},
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("The stored renditions.", cover),
+			http.StatusBadRequest, errorResponseFor("The upload is not a decodable image."),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+			http.StatusRequestEntityTooLarge, errorResponseFor("Larger than 5 MiB or 8000x8000 pixels."),
+			http.StatusUnsupportedMediaType, errorResponseFor("Not a JPEG, PNG or WebP image."),
+		),
+	})
+	add(http.MethodGet, "/books/{id}/cover", &openapi.Operation{
+		OperationID: "getCover",
+		Summary:     "Download a cover rendition",
+		Tags:        []string{"covers"},
+		Parameters: []*openapi.Parameter{
+			bookID,
+			queryParam("size", "Rendition; defaults to original.", false,
+				enum(domain.CoverSizeOriginal, domain.CoverSizeSmall, domain.CoverSizeMedium, domain.CoverSizeLarge)),
+			{Name: fiber.HeaderIfNoneMatch, In: "header", Schema: str()},
+			{Name: fiber.HeaderIfModifiedSince, In: "header", Schema: str()},
+		},
+		Responses: responses(
+			http.StatusOK, &openapi.Response{
+				Description: "The image.",
+				Headers: map[string]*openapi.Header{
+					fiber.HeaderETag:         {Schema: str()},
+					fiber.HeaderLastModified: {Schema: str()},
+				},
+				Content: map[string]openapi.MediaType{
+					"image/jpeg": {Schema: image},
+					"image/png":  {Schema: image},
+					"image/webp": {Schema: image},
+				},
+			},
+			http.StatusNotModified, &openapi.Response{Description: "The cached copy is current."},
+			http.StatusBadRequest, errorResponseFor("Unknown size."),
+			http.StatusNotFound, errorResponseFor("Book or cover not found."),
+		),
+	})
+
+	add(http.MethodGet, "/books/{id}/reviews", &openapi.Operation{
+		OperationID: "listReviews",
+		Summary:     "List a book's reviews",
+		Tags:        []string{"reviews"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Reviews.", arrayOf(review)),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+		),
+	})
+	add(http.MethodPost, "/books/{id}/reviews", &openapi.Operation{
+		OperationID: "createReview",
+		Summary:     "Review a book",
+		Tags:        []string{"reviews"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: jsonBody(reviewIn),
+		Responses: responses(
+			http.StatusCreated, jsonResponse("The created review.", review),
+			http.StatusBadRequest, errorResponseFor("Rating out of range or body too long."),
+			http.StatusNotFound, errorResponseFor("No live book with this ID."),
+			http.StatusConflict, errorResponseFor("The caller already reviewed this book."),
+		),
+	})
+	add(http.MethodPut, "/books/{id}/reviews/{reviewID}", &openapi.Operation{
+		OperationID: "updateReview",
+		Summary:     "Update the caller's review",
+		Tags:        []string{"reviews"},
+		Parameters:  []*openapi.Parameter{bookID, reviewID},
+		RequestBody: jsonBody(reviewIn),
+		Responses: responses(
+			http.StatusOK, jsonResponse("The updated review.", review),
+			http.StatusBadRequest, errorResponseFor("Rating out of range or body too long."),
+			http.StatusForbidden, errorResponseFor("The review belongs to someone else."),
+			http.StatusNotFound, errorResponseFor("Book or review not found."),
+		),
+	})
+	add(http.MethodDelete, "/books/{id}/reviews/{reviewID}", &openapi.Operation{
+		OperationID: "deleteReview",
+		Summary:     "Delete the caller's review",
+		Tags:        []string{"reviews"},
+		Parameters:  []*openapi.Parameter{bookID, reviewID},
+		Responses: responses(
+			http.StatusNoContent, &openapi.Response{Description: "Deleted."},
+			http.StatusForbidden, errorResponseFor("The review belongs to someone else."),
+			http.StatusNotFound, errorResponseFor("Book or review not found."),
+		),
+	})
+
+	// --- GraphQL ---
+	doc.Add(http.MethodPost, "/graphql", secure(&openapi.Operation{
+		OperationID: "graphql",
+		Summary:     "Run a GraphQL query or mutation over books and reviews",
+		Description: "Errors in the query or raised by resolvers are reported in the response's errors array " +
+			"with status 200, each with a code extension. Introspect the endpoint for the schema.",
+		Tags:        []string{"graphql"},
+		RequestBody: jsonBody(g.Schema(graphQLRequest{})),
+		Responses: responses(
+			http.StatusOK, jsonResponse("The GraphQL response.", g.Schema(graphQLResponse{})),
+			http.StatusBadRequest, errorResponseFor("The body is not a GraphQL request."),
+		),
+	}))
+
+	return doc
+}
+
+// idempotent documents the Idempotency-Key header of a POST or PATCH
+// operation and the errors it can cause.
+func idempotent(op *openapi.Operation) {
+	maxLength := middleware.MaxIdempotencyKeyLength
+	op.Parameters = append(op.Parameters, &openapi.Parameter{
+		Name: middleware.HeaderIdempotencyKey, In: "header",
+		Description: "Makes the request safe to retry: a retry with the same key and request gets the first response again, " +
+			"with Idempotent-Replayed: true. Keys are scoped to the user and expire after a day.",
+		Schema: &openapi.Schema{Type: openapi.Types{"string"}, MaxLength: &maxLength},
+	})
+	inUse := "A request with the same Idempotency-Key is still being processed; retry after Retry-After seconds."
+	if r, ok := op.Responses[strconv.Itoa(http.StatusConflict)]; ok {
+		r.Description += " Or: " + inUse
+	} else {
+		op.Responses[strconv.Itoa(http.StatusConflict)] = errorResponseFor(inUse)
+	}
+	op.Responses[strconv.Itoa(http.StatusUnprocessableEntity)] = errorResponseFor("The Idempotency-Key was used for a different request.")
+}
+
+// responses pairs status codes with responses: responses(200, r1, 404, r2).
+func responses(pairs ...any) map[string]*openapi.Response {
+	m := make(map[string]*openapi.Response, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		m[strconv.Itoa(pairs[i].(int))] = pairs[i+1].(*openapi.Response)
+	}
+	return m
+}
+
+func jsonResponse(description string, s *openapi.Schema) *openapi.Response {
+	return &openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: s}},
+	}
+}
+
+// errorResponseFor describes a problem+json error response.
+func errorResponseFor(description string) *openapi.Response {
+	return &openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{problem.ContentType: {Schema: openapi.Ref("ProblemDetails")}},
+	}
+}
+
+// formatResponse describes a response available in each of the media types.
+// In XML, an array is an <items> element.
+func formatResponse(mediaTypes []string, description string, s *openapi.Schema) *openapi.Response {
+	r := &openapi.Response{Description: description, Content: make(map[string]openapi.MediaType, len(mediaTypes))}
+	for _, t := range mediaTypes {
+		r.Content[t] = openapi.MediaType{Schema: s}
+	}
+	return r
+}
+
+// formatBody describes a request body accepted in each of the media types.
+func formatBody(mediaTypes []string, s *openapi.Schema) *openapi.RequestBody {
+	b := &openapi.RequestBody{Required: true, Content: make(map[string]openapi.MediaType, len(mediaTypes))}
+	for _, t := range mediaTypes {
+		b.Content[t] = openapi.MediaType{Schema: s}
+	}
+	return b
+}
+
+func jsonBody(s *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: s}},
+	}
+}
+
+func pathParam(name, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "path", Required: true, Description: description, Schema: str()}
+}
+
+func queryParam(name, description string, required bool, s *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Required: required, Description: description, Schema: s}
+}
+
+func arrayOf(items *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: openapi.Types{"array"}, Items: items}
+}
+
+func str() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.Types{"string"}}
+}
+
+func integer() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.Types{"integer"}}
+}
+
+func enum(values ...string) *openapi.Schema {
+	s := str()
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
@@ -0,0 +1,383 @@
+package handler_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+	"github.com/andrimuhayat/crud-test/internal/graphql"
+	"github.com/andrimuhayat/crud-test/internal/handler"
+	v1 "github.com/andrimuhayat/crud-test/internal/handler/v1"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/openapi"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// newApp registers the API through handler.Routes, as cmd/api does, backed
+// by in-memory repositories.
+func newApp(t *testing.T) *fiber.App {
+	t.Helper()
+	blobs, err := filesystem.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobStore: %v", err)
+	}
+	webhookRepo, err := filesystem.NewWebhookRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWebhookRepository: %v", err)
+	}
+	bookRepo := memory.NewBookRepository()
+	coverRepo := memory.NewCoverRepository()
+	shelfUC := usecase.NewShelfUseCase(memory.NewShelfRepository(), bookRepo)
+	reviewUC := usecase.NewReviewUseCase(memory.NewReviewRepository(), bookRepo)
+	bookUC := usecase.NewBookUseCase(bookRepo, memory.NewRevisionRepository(), shelfUC.Cascade(), coverRepo, reviewUC.Cascade())
+	authUC := usecase.NewAuthUseCase()
+	auditUC := usecase.NewAuditUseCase(memory.NewAuditRepository())
+	idempotencyUC := usecase.NewIdempotencyUseCase(memory.NewIdempotencyRepository(), usecase.DefaultIdempotencyTTL)
+	broker := eventbus.NewBroker(eventbus.DefaultReplaySize, eventbus.DefaultSubscriberSize)
+	formats := serializer.Default()
+
+	app := fiber.New(fiber.Config{
+		Immutable:             true,
+		DisableStartupMessage: true,
+		ErrorHandler:          problem.ErrorHandler,
+	})
+	app.Use(requestid.New())
+	handler.Routes{
+		Authenticated: []fiber.Handler{middleware.Auth(authUC), middleware.Audit(auditUC)},
+		Idempotency:   middleware.Idempotency(idempotencyUC, formats),
+		WSAuth:        []fiber.Handler{middleware.WebSocketAuth(authUC), middleware.Audit(auditUC)},
+		Auth:          handler.NewAuthHandler(authUC),
+		Book:          handler.NewBookHandler(bookUC, reviewUC, bookUC, v1.BookAdapter{}, formats),
+		Review:        handler.NewReviewHandler(reviewUC),
+		Shelf:         handler.NewShelfHandler(shelfUC),
+		Cover:         handler.NewCoverHandler(usecase.NewCoverUseCase(coverRepo, bookRepo, blobs)),
+		Revision:      handler.NewRevisionHandler(bookUC, v1.BookAdapter{}),
+		Audit:         handler.NewAuditHandler(auditUC),
+		Event:         handler.NewEventHandler(broker),
+		WS:            handler.NewWSHandler(broker, handler.DefaultWSTimeouts()),
+		Webhook:       handler.NewWebhookHandler(usecase.NewWebhookUseCase(webhookRepo, webhookRepo)),
+		GraphQL:       handler.NewGraphQLHandler(graphql.NewServer(bookUC, reviewUC, graphql.DefaultLimits())),
+	}.Register(app)
+	return app
+}
+
+// specClient sends requests to a running app and checks every response
+// against the operation it belongs to.
+type specClient struct {
+	t     *testing.T
+	doc   *openapi.Document
+	base  string
+	token string
+	hits  map[string]bool // "METHOD route"
+}
+
+type call struct {
+	method      string
+	route       string // OpenAPI path template
+	path        string // defaults to route
+	body        []byte
+	contentType string
+	header      map[string]string
+	anonymous   bool
+}
+
+// do sends c, requires the status to be documented for c.route and validates
+// the body against the documented content. It returns the body.
+func (s *specClient) do(c call, wantStatus int) []byte {
+	s.t.Helper()
+	if c.path == "" {
+		c.path = c.route
+	}
+	op := s.doc.Operation(c.method, c.route)
+	if op == nil {
+		s.t.Fatalf("%s %s is not documented", c.method, c.route)
+	}
+	s.hits[c.method+" "+c.route] = true
+
+	req, _ := http.NewRequest(c.method, s.base+c.path, bytes.NewReader(c.body))
+	if c.contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, c.contentType)
+	} else if c.body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if !c.anonymous && op.Security != nil {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+s.token)
+	}
+	for k, v := range c.header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", c.method, c.path, err)
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(fiber.HeaderContentType))
+	var body []byte
+	if mediaType == "text/event-stream" {
+		// The stream never ends; its first line is enough.
+		line, _ := bufio.NewReader(resp.Body).ReadBytes('\n')
+		body = line
+	} else if body, err = io.ReadAll(resp.Body); err != nil {
+		s.t.Fatalf("%s %s: read body: %v", c.method, c.path, err)
+	}
+
+	if resp.StatusCode != wantStatus {
+		s.t.Fatalf("%s %s: status %d, want %d; body %s", c.method, c.path, resp.StatusCode, wantStatus, body)
+	}
+	documented := op.Responses[strconv.Itoa(resp.StatusCode)]
+	if documented == nil {
+		s.t.Fatalf("%s %s: status %d is not documented", c.method, c.route, resp.StatusCode)
+	}
+	if len(documented.Content) == 0 {
+		if len(body) > 0 {
+			s.t.Errorf("%s %s: %d should have no body, got %s", c.method, c.path, resp.StatusCode, body)
+		}
+		return body
+	}
+	media, ok := documented.Content[mediaType]
+	if !ok {
+		s.t.Fatalf("%s %s: %d content type %q is not documented", c.method, c.route, resp.StatusCode, mediaType)
+	}
+	if mediaType == fiber.MIMEApplicationJSON || mediaType == problem.ContentType {
+		if err := s.doc.Validate(media.Schema, body); err != nil {
+			s.t.Errorf("%s %s: %d body does not match the spec: %v\nbody: %s", c.method, c.path, resp.StatusCode, err, body)
+		}
+	}
+	return body
+}
+
+func jsonField(t *testing.T, body []byte, field string) string {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	v, _ := m[field].(string)
+	return v
+}
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 300))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestResponsesMatchOpenAPI exercises every documented operation against the
+// real handlers, validating each response against the served document, and
+// checks that the document and the registered routes agree.
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	app := newApp(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	// The event stream stays open until its next heartbeat; don't wait for it.
+	defer app.ShutdownWithTimeout(100 * time.Millisecond)
+	base := "http://" + ln.Addr().String()
+
+	// Validate against the document as served, not the Go value.
+	resp, err := http.Get(base + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	var doc openapi.Document
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode /openapi.json: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version || doc.Components.SecuritySchemes["bearerAuth"] == nil {
+		t.Fatalf("unexpected document header: openapi %q, schemes %v", doc.OpenAPI, doc.Components.SecuritySchemes)
+	}
+	if resp, err := http.Get(base + "/docs"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /docs: %v %v", resp, err)
+	}
+
+	s := &specClient{t: t, doc: &doc, base: base, hits: make(map[string]bool)}
+
+	// --- Public ---
+	s.do(call{method: "GET", route: "/ping"}, 200)
+	if got := s.do(call{method: "POST", route: "/echo", body: []byte(`{"b":1, "a":[true]}`)}, 200); string(got) != `{"b":1, "a":[true]}` {
+		t.Errorf("echo: got %s", got)
+	}
+	s.do(call{method: "POST", route: "/echo", body: []byte{}}, 400)
+	s.do(call{method: "POST", route: "/v1/auth/token", body: []byte(`{"username":"admin"}`)}, 400)
+	s.do(call{method: "POST", route: "/v1/auth/token", body: []byte(`{"username":"admin","password":"nope"}`)}, 401)
+	s.token = jsonField(t, s.do(call{method: "POST", route: "/v1/auth/token", body: []byte(`{"username":"admin","password":"secret"}`)}, 200), "token")
+
+	s.do(call{method: "GET", route: "/problems"}, 200)
+	s.do(call{method: "GET", route: "/problems/{code}", path: "/problems/validation_failed"}, 200)
+	s.do(call{method: "GET", route: "/problems/{code}", path: "/problems/teapot"}, 404)
+
+	// --- Books ---
+	s.do(call{method: "GET", route: "/v1/books", anonymous: true}, 401)
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Dune"}`)}, 400)
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`title=Dune`), contentType: fiber.MIMEApplicationForm}, 415)
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Dune"}`), header: map[string]string{"Accept": "text/csv"}}, 406)
+	id := jsonField(t, s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Dune","author":"Frank Herbert","year":1965}`)}, 201), "id")
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Emma","author":"Jane Austen","tags":["Classic"," romance "]}`)}, 201)
+	keyed := map[string]string{middleware.HeaderIdempotencyKey: "create-persuasion"}
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Persuasion","author":"Jane Austen"}`), header: keyed}, 201)
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Persuasion","author":"Jane Austen"}`), header: keyed}, 201)
+	s.do(call{method: "POST", route: "/v1/books", body: []byte(`{"title":"Sanditon","author":"Jane Austen"}`), header: keyed}, 422)
+	s.do(call{method: "GET", route: "/v1/books"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", header: map[string]string{"Accept": "application/yaml"}}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?sort=-rating&author=Jane%20Austen"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?sort=title"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/stats", path: "/v1/books/stats?group_by=decade"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/stats", path: "/v1/books/stats?group_by=author&author=Jane%20Austen"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/stats", path: "/v1/books/stats?group_by=title"}, 400)
+
+	book := "/v1/books/" + id
+	s.do(call{method: "GET", route: "/v1/books/{id}", path: book}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}", path: "/v1/books/missing"}, 404)
+	s.do(call{method: "PUT", route: "/v1/books/{id}", path: book, body: []byte(`{"title":"Dune Messiah","author":"Frank Herbert","year":1969}`)}, 200)
+	s.do(call{method: "PUT", route: "/v1/books/{id}", path: book, body: []byte(`{"year":1969}`)}, 400)
+	s.do(call{method: "PUT", route: "/v1/books/{id}", path: "/v1/books/missing", body: []byte(`{"title":"x","author":"y"}`)}, 404)
+	s.do(call{method: "PATCH", route: "/v1/books/{id}", path: book, body: []byte(`{"isbn":"978-0-441-17271-9"}`)}, 200)
+	s.do(call{method: "PATCH", route: "/v1/books/{id}", path: book, body: []byte(`{"tags":["classic","science fiction"]}`)}, 200)
+	s.do(call{method: "PATCH", route: "/v1/books/{id}", path: book, body: []byte(`{"title":" ","year":-1}`)}, 400)
+	s.do(call{method: "PATCH", route: "/v1/books/{id}", path: book, body: []byte(`{"tags":["classic",""]}`)}, 400)
+	s.do(call{method: "PATCH", route: "/v1/books/{id}", path: "/v1/books/missing", body: []byte(`{"year":1970}`)}, 404)
+
+	// --- Reviews ---
+	reviews := book + "/reviews"
+	reviewID := jsonField(t, s.do(call{method: "POST", route: "/v1/books/{id}/reviews", path: reviews, body: []byte(`{"rating":5,"body":"Spice."}`)}, 201), "id")
+	s.do(call{method: "POST", route: "/v1/books/{id}/reviews", path: reviews, body: []byte(`{"rating":4}`)}, 409)
+	s.do(call{method: "POST", route: "/v1/books/{id}/reviews", path: reviews, body: []byte(`{"rating":9}`)}, 400)
+	s.do(call{method: "POST", route: "/v1/books/{id}/reviews", path: "/v1/books/missing/reviews", body: []byte(`{"rating":3}`)}, 404)
+	s.do(call{method: "GET", route: "/v1/books/{id}/reviews", path: reviews}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}/reviews", path: "/v1/books/missing/reviews"}, 404)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?sort=-reviews"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?fields=id,title&include=reviews,revisions"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?fields=id,price"}, 400)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?facets=author,decade&fields=title"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?facets=decade&author=Nobody"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?facets=tag&tag=Classic"}, 200)
+	s.do(call{method: "GET", route: "/v1/books", path: "/v1/books?facets=price"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/search", path: "/v1/books/search?q=austen"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/search", path: "/v1/books/search?q=classic&facets=author,decade,tag"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/search", path: "/v1/books/search?q=%20&facets=tag"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/{id}", path: book + "?fields=title&include=reviews"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}", path: book + "?include=authors"}, 400)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/reviews/{reviewID}", path: reviews + "/" + reviewID, body: []byte(`{"rating":4,"body":"Still spice."}`)}, 200)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/reviews/{reviewID}", path: reviews + "/" + reviewID, body: []byte(`{"rating":0}`)}, 400)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/reviews/{reviewID}", path: reviews + "/missing", body: []byte(`{"rating":4}`)}, 404)
+	s.do(call{method: "DELETE", route: "/v1/books/{id}/reviews/{reviewID}", path: reviews + "/" + reviewID}, 204)
+	s.do(call{method: "DELETE", route: "/v1/books/{id}/reviews/{reviewID}", path: reviews + "/" + reviewID}, 404)
+
+	// --- Revisions ---
+	revs := book + "/revisions"
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions", path: revs}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions", path: "/v1/books/missing/revisions"}, 404)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/{rev}", path: revs + "/1"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/{rev}", path: revs + "/one"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/{rev}", path: revs + "/99"}, 404)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/diff", path: revs + "/diff?from=1&to=2"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/diff", path: revs + "/diff?from=1"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/{id}/revisions/diff", path: revs + "/diff?from=1&to=99"}, 404)
+	s.do(call{method: "POST", route: "/v1/books/{id}/revisions/{rev}/restore", path: revs + "/1/restore"}, 200)
+	s.do(call{method: "POST", route: "/v1/books/{id}/revisions/{rev}/restore", path: revs + "/one/restore"}, 400)
+	s.do(call{method: "POST", route: "/v1/books/{id}/revisions/{rev}/restore", path: revs + "/99/restore"}, 404)
+
+	// --- Covers ---
+	cover := book + "/cover"
+	img := pngImage(t)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/cover", path: cover, body: img, contentType: "image/png"}, 200)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/cover", path: cover, body: []byte("plain text"), contentType: "image/png"}, 415)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/cover", path: cover, body: img[:64], contentType: "image/png"}, 400)
+	s.do(call{method: "PUT", route: "/v1/books/{id}/cover", path: "/v1/books/missing/cover", body: img, contentType: "image/png"}, 404)
+	s.do(call{method: "GET", route: "/v1/books/{id}/cover", path: cover + "?size=small"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/{id}/cover", path: cover, header: map[string]string{"If-None-Match": "*"}}, 304)
+	s.do(call{method: "GET", route: "/v1/books/{id}/cover", path: cover + "?size=huge"}, 400)
+	s.do(call{method: "GET", route: "/v1/books/{id}/cover", path: "/v1/books/missing/cover"}, 404)
+
+	// --- Events ---
+	s.do(call{method: "GET", route: "/v1/books/events"}, 200)
+	s.do(call{method: "GET", route: "/v1/books/events", header: map[string]string{"Last-Event-ID": "latest"}}, 400)
+
+	// --- GraphQL ---
+	gq := func(query string) []byte { return []byte(`{"query":` + strconv.Quote(query) + `}`) }
+	s.do(call{method: "POST", route: "/graphql", body: gq(`{ books { id } }`), anonymous: true}, 401)
+	s.do(call{method: "POST", route: "/graphql", body: []byte(`{"variables":{}}`)}, 400)
+	if got := s.do(call{method: "POST", route: "/graphql", body: gq(`{ books(limit: 5) { title reviews { rating } } bookCount }`)}, 200); !bytes.Contains(got, []byte(`"title":"Emma"`)) {
+		t.Errorf("graphql books: got %s", got)
+	}
+	if got := s.do(call{method: "POST", route: "/graphql", body: gq(`mutation { createBook(input: {title: "", author: "x"}) { id } }`)}, 200); !bytes.Contains(got, []byte(`"code":"validation_failed"`)) {
+		t.Errorf("graphql invalid mutation: got %s", got)
+	}
+
+	// --- Trash ---
+	s.do(call{method: "DELETE", route: "/v1/books/{id}", path: book}, 204)
+	s.do(call{method: "DELETE", route: "/v1/books/{id}", path: book}, 404)
+	s.do(call{method: "GET", route: "/v1/books/trash", path: "/v1/books/trash?author=Frank%20Herbert"}, 200)
+	s.do(call{method: "POST", route: "/v1/books/{id}/restore", path: book + "/restore"}, 200)
+	s.do(call{method: "POST", route: "/v1/books/{id}/restore", path: book + "/restore"}, 404)
+
+	var missed []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if key := strings.ToUpper(method) + " " + path; !s.hits[key] {
+				missed = append(missed, key)
+			}
+		}
+	}
+	sort.Strings(missed)
+	if len(missed) > 0 {
+		t.Errorf("documented operations not exercised: %v", missed)
+	}
+
+	param := regexp.MustCompile(`:(\w+)`)
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == http.MethodHead || r.Path == "/openapi.json" || r.Path == "/docs" {
+			continue
+		}
+		path := param.ReplaceAllString(strings.TrimSuffix(r.Path, "/"), "{$1}")
+		if undocumented(path) {
+			continue
+		}
+		// Unversioned aliases of documented v1 routes are deliberately left out.
+		if doc.Operation(r.Method, path) == nil && doc.Operation(r.Method, "/v1"+path) == nil {
+			t.Errorf("route %s %s is not documented", r.Method, path)
+		}
+	}
+}
+
+// undocumentedPrefixes are the route groups the OpenAPI document does not
+// cover yet, with and without /v1. Every other registered route must be
+// documented.
+var undocumentedPrefixes = []string{"/shelves", "/shared/shelves", "/webhooks", "/ws", "/admin"}
+
+func undocumented(path string) bool {
+	path = strings.TrimPrefix(path, "/v1")
+	for _, prefix := range undocumentedPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProblemHandler describes the problem types that error responses refer to
+// in their "type" member.
+type ProblemHandler struct{}
+
+// NewProblemHandler returns a ProblemHandler.
+func NewProblemHandler() *ProblemHandler { return &ProblemHandler{} }
+
+// problemType is one entry of the problem type catalogue.
+type problemType struct {
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+func newProblemType(k problem.Kind) problemType {
+	return problemType{Type: k.Type(), Code: k.Code, Status: k.Status, Title: k.Title}
+}
+
+// GetProblemTypes handles GET /problems.
+func (h *ProblemHandler) GetProblemTypes(c *fiber.Ctx) error {
+	types := make([]problemType, len(problem.Kinds))
+	for i, k := range problem.Kinds {
+		types[i] = newProblemType(k)
+	}
+	return c.JSON(types)
+}
+
+// GetProblemType handles GET /problems/:code.
+func (h *ProblemHandler) GetProblemType(c *fiber.Ctx) error {
+	kind, ok := problem.Lookup(c.Params("code"))
+	if !ok {
+		return problem.Write(c, domain.ErrNotFound, "unknown problem type")
+	}
+	return c.JSON(newProblemType(kind))
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReviewHandler handles the review endpoints nested under /books/:id.
+type ReviewHandler struct {
+	reviewUC domain.ReviewUseCase
+}
+
+// NewReviewHandler wires the handler to the review use-case.
+func NewReviewHandler(reviewUC domain.ReviewUseCase) *ReviewHandler {
+	return &ReviewHandler{reviewUC: reviewUC}
+}
+
+type reviewRequest struct {
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
+
+// CreateReview handles POST /books/:id/reviews.
+func (h *ReviewHandler) CreateReview(c *fiber.Ctx) error {
+	var req reviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	review, err := h.reviewUC.CreateReview(c.Params("id"), middleware.Username(c), req.Rating, req.Body)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(review)
+}
+
+// GetReviews handles GET /books/:id/reviews.
+func (h *ReviewHandler) GetReviews(c *fiber.Ctx) error {
+	reviews, err := h.reviewUC.GetReviews(c.Params("id"))
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(reviews)
+}
+
+// UpdateReview handles PUT /books/:id/reviews/:reviewID. Only the review's
+// author may update it.
+func (h *ReviewHandler) UpdateReview(c *fiber.Ctx) error {
+	var req reviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	review, err := h.reviewUC.UpdateReview(c.Params("id"), c.Params("reviewID"), middleware.Username(c), req.Rating, req.Body)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(review)
+}
+
+// DeleteReview handles DELETE /books/:id/reviews/:reviewID. Only the review's
+// author may delete it.
+func (h *ReviewHandler) DeleteReview(c *fiber.Ctx) error {
+	err := h.reviewUC.DeleteReview(c.Params("id"), c.Params("reviewID"), middleware.Username(c))
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func reviewError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "book or review not found")
+	case errors.Is(err, domain.ErrInvalidData):
+		return problem.Write(c, err, "rating must be between 1 and 5 and body at most 5000 characters")
+	case errors.Is(err, domain.ErrConflict):
+		return problem.Write(c, err, "you have already reviewed this book")
+	case errors.Is(err, domain.ErrForbidden):
+		return problem.Write(c, err, "only the author of a review may change it")
+	}
+	return problem.Write(c, err, "")
+}
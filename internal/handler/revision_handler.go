@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RevisionHandler handles the revision history endpoints nested under /books/:id.
+type RevisionHandler struct {
+	revisionUC domain.RevisionUseCase
+	adapter    BookAdapter
+}
+
+// NewRevisionHandler wires the handler to the revision use-case, speaking
+// the API version of adapter.
+func NewRevisionHandler(revisionUC domain.RevisionUseCase, adapter BookAdapter) *RevisionHandler {
+	return &RevisionHandler{revisionUC: revisionUC, adapter: adapter}
+}
+
+// GetRevisions handles GET /books/:id/revisions.
+func (h *RevisionHandler) GetRevisions(c *fiber.Ctx) error {
+	revs, err := h.revisionUC.GetRevisions(c.Params("id"))
+	if err != nil {
+		return revisionError(c, err)
+	}
+	out := make([]any, len(revs))
+	for i, rev := range revs {
+		out[i] = h.adapter.Revision(rev)
+	}
+	return c.JSON(out)
+}
+
+// GetRevision handles GET /books/:id/revisions/:rev.
+func (h *RevisionHandler) GetRevision(c *fiber.Ctx) error {
+	number, err := strconv.Atoi(c.Params("rev"))
+	if err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "revision must be a number")
+	}
+
+	rev, err := h.revisionUC.GetRevision(c.Params("id"), number)
+	if err != nil {
+		return revisionError(c, err)
+	}
+	return c.JSON(h.adapter.Revision(rev))
+}
+
+// DiffRevisions handles GET /books/:id/revisions/diff?from=&to=.
+func (h *RevisionHandler) DiffRevisions(c *fiber.Ctx) error {
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		return problem.Write(c, problem.ErrBadRequest, "from and to revision numbers are required")
+	}
+
+	diff, err := h.revisionUC.DiffRevisions(c.Params("id"), from, to)
+	if err != nil {
+		return revisionError(c, err)
+	}
+	return c.JSON(diff)
+}
+
+// RollbackBook handles POST /books/:id/revisions/:rev/restore.
+func (h *RevisionHandler) RollbackBook(c *fiber.Ctx) error {
+	number, err := strconv.Atoi(c.Params("rev"))
+	if err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "revision must be a number")
+	}
+
+	book, err := h.revisionUC.RollbackBook(c.Params("id"), number, middleware.Username(c))
+	if err != nil {
+		return revisionError(c, err)
+	}
+	return c.JSON(h.adapter.Book(book))
+}
+
+func revisionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "book or revision not found")
+	case errors.Is(err, domain.ErrInvalidData):
+		return problem.Write(c, err, "revision has no book state to restore")
+	}
+	return problem.Write(c, err, "")
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The unversioned API routes were deprecated when /v1 was introduced and are
+// removed six months later.
+var (
+	UnversionedDeprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	UnversionedSunset     = UnversionedDeprecated.AddDate(0, 6, 0)
+)
+
+// Routes holds the handlers of the HTTP API and the middleware guarding them.
+// cmd/api and the OpenAPI conformance test both register the API through it,
+// so the test sees exactly the routes the server serves.
+type Routes struct {
+	// Authenticated runs before every route that requires a JWT, GraphQL
+	// included; Idempotency follows it on the REST routes only.
+	Authenticated []fiber.Handler
+	Idempotency   fiber.Handler
+	// WSAuth guards the WebSocket handshake, which may carry its token as
+	// ?access_token=.
+	WSAuth []fiber.Handler
+
+	Auth     *AuthHandler
+	Book     *BookHandler
+	Review   *ReviewHandler
+	Shelf    *ShelfHandler
+	Cover    *CoverHandler
+	Revision *RevisionHandler
+	Audit    *AuditHandler
+	Event    *EventHandler
+	WS       *WSHandler
+	Webhook  *WebhookHandler
+	GraphQL  *GraphQLHandler
+}
+
+// Register mounts the unversioned service routes, API version 1 under /v1
+// and, until UnversionedSunset, as deprecated unversioned aliases, and the
+// GraphQL endpoint.
+func (h Routes) Register(app *fiber.App) {
+	openapiH := NewOpenAPIHandler()
+	problemH := NewProblemHandler()
+
+	// --- Unversioned service routes ---
+	app.Get("/ping", NewPingHandler().Ping)
+	app.Post("/echo", NewEchoHandler().Echo)
+	app.Get("/openapi.json", openapiH.Spec)
+	app.Get("/docs", openapiH.Docs)
+	app.Get("/problems", problemH.GetProblemTypes)
+	app.Get("/problems/:code", problemH.GetProblemType)
+
+	// --- API versions ---
+	// Each version is mounted under its own prefix with its own adapters.
+	// A /v2 gets a route set of its own once its DTOs diverge from v1's.
+	h.mountV1(app.Group("/v1"))
+
+	// The routes predating versioning remain as aliases of v1 until the
+	// sunset date, with headers pointing clients at the /v1 route.
+	h.mountV1(app, middleware.Deprecation(middleware.DeprecationConfig{
+		Since:  UnversionedDeprecated,
+		Sunset: UnversionedSunset,
+		Successor: func(c *fiber.Ctx) string {
+			return "/v1" + c.OriginalURL()
+		},
+	}))
+
+	// --- GraphQL ---
+	// Unversioned: the schema evolves by deprecating fields, not by prefix.
+	app.Post("/graphql", append(append([]fiber.Handler{}, h.Authenticated...), h.GraphQL.Query)...)
+}
+
+// mountV1 registers the v1 routes on r, running pre before every route's own
+// middleware.
+func (h Routes) mountV1(r fiber.Router, pre ...fiber.Handler) {
+	with := func(handlers ...fiber.Handler) []fiber.Handler {
+		return append(append([]fiber.Handler{}, pre...), handlers...)
+	}
+	// Every authenticated request is recorded in the audit log, and its
+	// POST and PATCH requests honour Idempotency-Key.
+	authenticated := with(append(append([]fiber.Handler{}, h.Authenticated...), h.Idempotency)...)
+
+	// --- Public routes ---
+	r.Post("/auth/token", with(h.Auth.GenerateToken)...)
+	r.Get("/shared/shelves/:token", with(h.Shelf.GetSharedShelf)...)
+
+	// --- Protected book routes (Level 5 — JWT required) ---
+	books := r.Group("/books", authenticated...)
+	books.Post("/", h.Book.CreateBook)
+	books.Get("/", h.Book.GetBooks)
+	books.Get("/search", h.Book.SearchBooks)
+	books.Get("/trash", h.Book.GetTrash)
+	books.Get("/stats", h.Book.GetBookStats)
+	books.Get("/events", h.Event.StreamBooks)
+	books.Get("/:id", h.Book.GetBook)
+	books.Put("/:id", h.Book.UpdateBook)
+	books.Patch("/:id", h.Book.PatchBook)
+	books.Delete("/:id", h.Book.DeleteBook)
+	books.Post("/:id/restore", h.Book.RestoreBook)
+
+	books.Get("/:id/revisions", h.Revision.GetRevisions)
+	books.Get("/:id/revisions/diff", h.Revision.DiffRevisions)
+	books.Get("/:id/revisions/:rev", h.Revision.GetRevision)
+	books.Post("/:id/revisions/:rev/restore", h.Revision.RollbackBook)
+
+	books.Put("/:id/cover", h.Cover.UploadCover)
+	books.Get("/:id/cover", h.Cover.GetCover)
+
+	books.Get("/:id/reviews", h.Review.GetReviews)
+	books.Post("/:id/reviews", h.Review.CreateReview)
+	books.Put("/:id/reviews/:reviewID", h.Review.UpdateReview)
+	books.Delete("/:id/reviews/:reviewID", h.Review.DeleteReview)
+
+	// --- Protected shelf routes, scoped to the authenticated user ---
+	shelves := r.Group("/shelves", authenticated...)
+	shelves.Get("/", h.Shelf.GetShelves)
+	shelves.Post("/", h.Shelf.CreateShelf)
+	shelves.Get("/progress", h.Shelf.GetProgress)
+	shelves.Get("/:id", h.Shelf.GetShelf)
+	shelves.Delete("/:id", h.Shelf.DeleteShelf)
+	shelves.Post("/:id/books", h.Shelf.AddBook)
+	shelves.Put("/:id/books", h.Shelf.ReorderBooks)
+	shelves.Delete("/:id/books/:bookID", h.Shelf.RemoveBook)
+	shelves.Post("/:id/share", h.Shelf.ShareShelf)
+	shelves.Delete("/:id/share", h.Shelf.UnshareShelf)
+
+	// --- Protected webhook routes, scoped to the authenticated user ---
+	webhooks := r.Group("/webhooks", authenticated...)
+	webhooks.Post("/", h.Webhook.CreateWebhook)
+	webhooks.Get("/", h.Webhook.GetWebhooks)
+	webhooks.Get("/dead-letters", h.Webhook.GetDeadLetters)
+	webhooks.Get("/:id", h.Webhook.GetWebhook)
+	webhooks.Put("/:id", h.Webhook.UpdateWebhook)
+	webhooks.Delete("/:id", h.Webhook.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", h.Webhook.GetDeliveries)
+	webhooks.Get("/:id/deliveries/:deliveryID", h.Webhook.GetDelivery)
+	webhooks.Post("/:id/deliveries/:deliveryID/redeliver", h.Webhook.Redeliver)
+
+	// --- Protected WebSocket push channel ---
+	// Browsers cannot set headers on the handshake, so the JWT may also be
+	// passed as ?access_token=.
+	r.Get("/ws", append(with(h.WSAuth...), h.WS.Connect)...)
+
+	// --- Protected admin routes ---
+	admin := r.Group("/admin", authenticated...)
+	admin.Get("/audit", h.Audit.GetAudit)
+	admin.Get("/audit/verify", h.Audit.VerifyAudit)
+}
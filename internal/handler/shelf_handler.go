@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ShelfHandler handles the per-user reading shelf endpoints.
+type ShelfHandler struct {
+	shelfUC domain.ShelfUseCase
+}
+
+// NewShelfHandler wires the handler to the shelf use-case.
+func NewShelfHandler(shelfUC domain.ShelfUseCase) *ShelfHandler {
+	return &ShelfHandler{shelfUC: shelfUC}
+}
+
+type createShelfRequest struct {
+	Name string `json:"name"`
+}
+
+type addShelfBookRequest struct {
+	BookID string `json:"book_id"`
+	// Position is the 0-based insertion index; omit it to append.
+	Position *int `json:"position"`
+}
+
+type reorderShelfRequest struct {
+	BookIDs []string `json:"book_ids"`
+}
+
+// GetShelves handles GET /shelves.
+func (h *ShelfHandler) GetShelves(c *fiber.Ctx) error {
+	shelves, err := h.shelfUC.GetShelves(middleware.Username(c))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelves)
+}
+
+// CreateShelf handles POST /shelves.
+func (h *ShelfHandler) CreateShelf(c *fiber.Ctx) error {
+	var req createShelfRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	shelf, err := h.shelfUC.CreateShelf(middleware.Username(c), req.Name)
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(shelf)
+}
+
+// GetShelf handles GET /shelves/:id.
+func (h *ShelfHandler) GetShelf(c *fiber.Ctx) error {
+	shelf, err := h.shelfUC.GetShelf(middleware.Username(c), c.Params("id"))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelf)
+}
+
+// DeleteShelf handles DELETE /shelves/:id.
+func (h *ShelfHandler) DeleteShelf(c *fiber.Ctx) error {
+	if err := h.shelfUC.DeleteShelf(middleware.Username(c), c.Params("id")); err != nil {
+		return shelfError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// AddBook handles POST /shelves/:id/books.
+func (h *ShelfHandler) AddBook(c *fiber.Ctx) error {
+	var req addShelfBookRequest
+	if err := c.BodyParser(&req); err != nil || req.BookID == "" {
+		return problem.Write(c, problem.ErrBadRequest, "book_id is required")
+	}
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+
+	shelf, err := h.shelfUC.AddBook(middleware.Username(c), c.Params("id"), req.BookID, position)
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelf)
+}
+
+// RemoveBook handles DELETE /shelves/:id/books/:bookID.
+func (h *ShelfHandler) RemoveBook(c *fiber.Ctx) error {
+	shelf, err := h.shelfUC.RemoveBook(middleware.Username(c), c.Params("id"), c.Params("bookID"))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelf)
+}
+
+// ReorderBooks handles PUT /shelves/:id/books.
+func (h *ShelfHandler) ReorderBooks(c *fiber.Ctx) error {
+	var req reorderShelfRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	shelf, err := h.shelfUC.ReorderBooks(middleware.Username(c), c.Params("id"), req.BookIDs)
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelf)
+}
+
+// GetProgress handles GET /shelves/progress.
+func (h *ShelfHandler) GetProgress(c *fiber.Ctx) error {
+	progress, err := h.shelfUC.GetProgress(middleware.Username(c))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(progress)
+}
+
+// ShareShelf handles POST /shelves/:id/share.
+func (h *ShelfHandler) ShareShelf(c *fiber.Ctx) error {
+	shelf, err := h.shelfUC.ShareShelf(middleware.Username(c), c.Params("id"))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"share_token": shelf.ShareToken,
+		"url":         c.BaseURL() + "/shared/shelves/" + shelf.ShareToken,
+	})
+}
+
+// UnshareShelf handles DELETE /shelves/:id/share.
+func (h *ShelfHandler) UnshareShelf(c *fiber.Ctx) error {
+	if _, err := h.shelfUC.UnshareShelf(middleware.Username(c), c.Params("id")); err != nil {
+		return shelfError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetSharedShelf handles GET /shared/shelves/:token (public, read-only).
+func (h *ShelfHandler) GetSharedShelf(c *fiber.Ctx) error {
+	shelf, err := h.shelfUC.GetSharedShelf(c.Params("token"))
+	if err != nil {
+		return shelfError(c, err)
+	}
+	return c.JSON(shelf)
+}
+
+func shelfError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "shelf or book not found")
+	case errors.Is(err, domain.ErrInvalidData):
+		return problem.Write(c, err, "invalid data")
+	case errors.Is(err, domain.ErrConflict):
+		return problem.Write(c, err, "shelf name or book already present")
+	case errors.Is(err, domain.ErrForbidden):
+		return problem.Write(c, err, "built-in shelves cannot be deleted")
+	}
+	return problem.Write(c, err, "")
+}
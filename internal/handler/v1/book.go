@@ -0,0 +1,245 @@
+// Package v1 is version 1 of the API's book wire format: the DTOs the /v1
+// book endpoints accept and return, and the adapter that converts them to
+// and from the domain.
+//
+// The DTOs are frozen copies of the domain types as they were when v1 was
+// published. A field added to domain.Book stays out of v1 responses until it
+// is added here; an incompatible change belongs in a new version package.
+package v1
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/andrimuhayat/crud-test/internal/validation"
+)
+
+// Book is the v1 representation of a book. In XML it is a <book> element
+// with one child element per field.
+type Book struct {
+	XMLName       xml.Name   `json:"-" xml:"book"`
+	ID            string     `json:"id" xml:"id"`
+	Title         string     `json:"title" xml:"title"`
+	Author        string     `json:"author" xml:"author"`
+	Year          int        `json:"year,omitempty" xml:"year,omitempty"`
+	ISBN          string     `json:"isbn,omitempty" xml:"isbn,omitempty"`
+	Tags          []string   `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	AverageRating float64    `json:"average_rating" xml:"average_rating"`
+	ReviewCount   int        `json:"review_count" xml:"review_count"`
+	CreatedAt     time.Time  `json:"created_at" xml:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+	DeletedBy     string     `json:"deleted_by,omitempty" xml:"deleted_by,omitempty"`
+}
+
+// Revision is the v1 representation of a book revision. In XML it is a
+// <revision> element; the books before and after are nested in <before> and
+// <after>.
+type Revision struct {
+	XMLName      xml.Name  `json:"-" xml:"revision"`
+	BookID       string    `json:"book_id" xml:"book_id"`
+	Number       int       `json:"number" xml:"number"`
+	Action       string    `json:"action" xml:"action"`
+	Actor        string    `json:"actor" xml:"actor"`
+	At           time.Time `json:"at" xml:"at"`
+	Before       *Book     `json:"before" xml:"before>book"`
+	After        *Book     `json:"after" xml:"after>book"`
+	RestoredFrom int       `json:"restored_from,omitempty" xml:"restored_from,omitempty"`
+}
+
+// Review is the v1 representation of a review embedded in a book response.
+// It encodes like the responses of the review endpoints.
+type Review struct {
+	XMLName   xml.Name  `json:"-" xml:"review"`
+	ID        string    `json:"id" xml:"id"`
+	BookID    string    `json:"book_id" xml:"book_id"`
+	Username  string    `json:"username" xml:"username"`
+	Rating    int       `json:"rating" xml:"rating"`
+	Body      string    `json:"body" xml:"body"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+// BookList is the v1 representation of a listing with facets: the books of
+// GET /v1/books?facets= or GET /v1/books/search?facets=, their total and the
+// facet counts asked for. In XML it is a <books> element of <book>s followed
+// by <total> and <facets>.
+type BookList struct {
+	XMLName xml.Name   `json:"-" xml:"books"`
+	Books   []any      `json:"books" xml:"book"`
+	Total   int        `json:"total" xml:"total"`
+	Facets  BookFacets `json:"facets" xml:"facets"`
+}
+
+// BookFacets holds the facets a listing asked for; the others are absent. In
+// XML each value is a <count value="…"> element.
+type BookFacets struct {
+	Author []FacetCount `json:"author,omitzero" xml:"author>count,omitempty"`
+	Decade []FacetCount `json:"decade,omitzero" xml:"decade>count,omitempty"`
+	Tag    []FacetCount `json:"tag,omitzero" xml:"tag>count,omitempty"`
+}
+
+// FacetCount is the number of matching books with a facet value.
+type FacetCount struct {
+	Value string `json:"value" xml:"value,attr"`
+	Count int    `json:"count" xml:",chardata"`
+}
+
+// BookStats is the v1 representation of the aggregate returned by GET
+// /v1/books/stats. In XML it is a <stats> element with a <group> per group.
+type BookStats struct {
+	XMLName xml.Name    `json:"-" xml:"stats"`
+	GroupBy string      `json:"group_by" xml:"group_by"`
+	Total   int         `json:"total" xml:"total"`
+	Groups  []BookGroup `json:"groups" xml:"group"`
+}
+
+// BookGroup is one group of a BookStats. The year bounds are absent when no
+// book of the group has a year.
+type BookGroup struct {
+	Key     string `json:"key" xml:"key"`
+	Count   int    `json:"count" xml:"count"`
+	MinYear int    `json:"min_year,omitempty" xml:"min_year,omitempty"`
+	MaxYear int    `json:"max_year,omitempty" xml:"max_year,omitempty"`
+}
+
+// BookRequest is the body of POST /v1/books and PUT /v1/books/:id. Its rules
+// match those of domain.Book; a PUT without tags removes the book's tags. In
+// XML it is a <book> element with a <tag> per tag in <tags>.
+type BookRequest struct {
+	Title  string   `json:"title" xml:"title" validate:"trim,required,max=300"`
+	Author string   `json:"author" xml:"author" validate:"trim,required,max=200"`
+	Year   int      `json:"year,omitempty" xml:"year,omitempty" validate:"omitempty,year"`
+	ISBN   string   `json:"isbn,omitempty" xml:"isbn,omitempty" validate:"omitempty,isbn"`
+	Tags   []string `json:"tags,omitempty" xml:"tags>tag,omitempty" validate:"omitempty,tags,max=20"`
+}
+
+// BookPatchRequest is the body of PATCH /v1/books/:id. Absent or null fields
+// are left unchanged; the rules of the present ones match BookRequest.
+type BookPatchRequest struct {
+	Title  *string   `json:"title,omitempty" xml:"title,omitempty" validate:"trim,required,max=300"`
+	Author *string   `json:"author,omitempty" xml:"author,omitempty" validate:"trim,required,max=200"`
+	Year   *int      `json:"year,omitempty" xml:"year,omitempty" validate:"omitempty,year"`
+	ISBN   *string   `json:"isbn,omitempty" xml:"isbn,omitempty" validate:"omitempty,isbn"`
+	Tags   *[]string `json:"tags,omitempty" xml:"tags>tag,omitempty" validate:"omitempty,tags,max=20"`
+}
+
+// BookAdapter implements handler.BookAdapter for v1.
+type BookAdapter struct{}
+
+// DecodeInput parses and validates a BookRequest.
+func (BookAdapter) DecodeInput(body []byte, format serializer.Serializer) (domain.BookInput, error) {
+	var req BookRequest
+	if err := format.Unmarshal(body, &req); err != nil {
+		return domain.BookInput{}, problem.ErrBadRequest
+	}
+	if err := validation.Struct(&req); err != nil {
+		return domain.BookInput{}, err
+	}
+	// v1 knows tags, so absent ones are none rather than unchanged.
+	tags := req.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return domain.BookInput{Title: req.Title, Author: req.Author, Year: req.Year, ISBN: req.ISBN, Tags: tags}, nil
+}
+
+// DecodePatch parses and validates a BookPatchRequest.
+func (BookAdapter) DecodePatch(body []byte, format serializer.Serializer) (domain.BookPatch, error) {
+	var req BookPatchRequest
+	if err := format.Unmarshal(body, &req); err != nil {
+		return domain.BookPatch{}, problem.ErrBadRequest
+	}
+	if err := validation.Struct(&req); err != nil {
+		return domain.BookPatch{}, err
+	}
+	return domain.BookPatch{Title: req.Title, Author: req.Author, Year: req.Year, ISBN: req.ISBN, Tags: req.Tags}, nil
+}
+
+// Book converts b to a *Book.
+func (BookAdapter) Book(b *domain.Book) any {
+	return newBook(b)
+}
+
+// BookList wraps a listing's books, already converted, with the facets in
+// names.
+func (BookAdapter) BookList(books []any, total int, facets domain.BookFacets, names []string) any {
+	out := &BookList{Books: books, Total: total}
+	for _, name := range names {
+		switch name {
+		case domain.FacetAuthor:
+			out.Facets.Author = facetCounts(facets.Author)
+		case domain.FacetDecade:
+			out.Facets.Decade = facetCounts(facets.Decade)
+		case domain.FacetTag:
+			out.Facets.Tag = facetCounts(facets.Tag)
+		}
+	}
+	return out
+}
+
+func facetCounts(counts []domain.FacetCount) []FacetCount {
+	out := make([]FacetCount, len(counts))
+	for i, c := range counts {
+		out[i] = FacetCount{Value: c.Value, Count: c.Count}
+	}
+	return out
+}
+
+// Stats converts the groups of an aggregate by groupBy to a *BookStats.
+func (BookAdapter) Stats(groupBy string, groups []domain.BookGroup) any {
+	out := &BookStats{GroupBy: groupBy, Groups: make([]BookGroup, len(groups))}
+	for i, g := range groups {
+		out.Groups[i] = BookGroup{Key: g.Key, Count: g.Count, MinYear: g.MinYear, MaxYear: g.MaxYear}
+		out.Total += g.Count
+	}
+	return out
+}
+
+// Review converts r to a *Review.
+func (BookAdapter) Review(r *domain.Review) any {
+	return &Review{
+		ID:        r.ID,
+		BookID:    r.BookID,
+		Username:  r.Username,
+		Rating:    r.Rating,
+		Body:      r.Body,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
+
+// Revision converts rev to a *Revision.
+func (BookAdapter) Revision(rev *domain.Revision) any {
+	return &Revision{
+		BookID:       rev.BookID,
+		Number:       rev.Number,
+		Action:       rev.Action,
+		Actor:        rev.Actor,
+		At:           rev.At,
+		Before:       newBook(rev.Before),
+		After:        newBook(rev.After),
+		RestoredFrom: rev.RestoredFrom,
+	}
+}
+
+func newBook(b *domain.Book) *Book {
+	if b == nil {
+		return nil
+	}
+	return &Book{
+		ID:            b.ID,
+		Title:         b.Title,
+		Author:        b.Author,
+		Year:          b.Year,
+		ISBN:          b.ISBN,
+		Tags:          b.Tags,
+		AverageRating: b.AverageRating,
+		ReviewCount:   b.ReviewCount,
+		CreatedAt:     b.CreatedAt,
+		DeletedAt:     b.DeletedAt,
+		DeletedBy:     b.DeletedBy,
+	}
+}
@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/handler"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestUnversionedRoutesAreDeprecatedAliases verifies that the unversioned
+// routes serve the same resources as /v1 and carry the deprecation headers,
+// error responses included, while /v1 responses do not.
+func TestUnversionedRoutesAreDeprecatedAliases(t *testing.T) {
+	app := newApp(t)
+	send := func(method, path, token, body string) (*http.Response, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, b
+	}
+	checkDeprecated := func(resp *http.Response, successor string) {
+		t.Helper()
+		if got, want := resp.Header.Get("Deprecation"), "@"+strconv.FormatInt(handler.UnversionedDeprecated.Unix(), 10); got != want {
+			t.Errorf("Deprecation: got %q, want %q", got, want)
+		}
+		if got, want := resp.Header.Get("Sunset"), handler.UnversionedSunset.Format(http.TimeFormat); got != want {
+			t.Errorf("Sunset: got %q, want %q", got, want)
+		}
+		if got, want := resp.Header.Get(fiber.HeaderLink), "<"+successor+`>; rel="successor-version"`; got != want {
+			t.Errorf("Link: got %q, want %q", got, want)
+		}
+	}
+
+	resp, body := send("POST", "/v1/auth/token", "", `{"username":"admin","password":"secret"}`)
+	if resp.StatusCode != 200 || resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("POST /v1/auth/token: %d, Deprecation %q", resp.StatusCode, resp.Header.Get("Deprecation"))
+	}
+	token := jsonField(t, body, "token")
+
+	resp, body = send("POST", "/books", token, `{"title":"Dune","author":"Frank Herbert"}`)
+	if resp.StatusCode != 201 {
+		t.Fatalf("POST /books: %d %s", resp.StatusCode, body)
+	}
+	checkDeprecated(resp, "/v1/books")
+	id := jsonField(t, body, "id")
+
+	resp, legacy := send("GET", "/books/"+id, token, "")
+	checkDeprecated(resp, "/v1/books/"+id)
+	resp, current := send("GET", "/v1/books/"+id, token, "")
+	if resp.StatusCode != 200 || resp.Header.Get("Deprecation") != "" || resp.Header.Get(fiber.HeaderLink) != "" {
+		t.Errorf("GET /v1/books/:id: %d, Deprecation %q, Link %q", resp.StatusCode, resp.Header.Get("Deprecation"), resp.Header.Get(fiber.HeaderLink))
+	}
+	if !bytes.Equal(legacy, current) {
+		t.Errorf("alias and v1 bodies differ:\n%s\n%s", legacy, current)
+	}
+
+	resp, _ = send("GET", "/books?author=Frank%20Herbert", "", "")
+	if resp.StatusCode != 401 {
+		t.Errorf("anonymous GET /books: %d", resp.StatusCode)
+	}
+	checkDeprecated(resp, "/v1/books?author=Frank%20Herbert")
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookHandler handles the per-user webhook subscription endpoints.
+type WebhookHandler struct {
+	webhookUC domain.WebhookUseCase
+}
+
+// NewWebhookHandler wires the handler to the webhook use-case.
+func NewWebhookHandler(webhookUC domain.WebhookUseCase) *WebhookHandler {
+	return &WebhookHandler{webhookUC: webhookUC}
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type updateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Active pauses (false) or resumes (true) deliveries; omit to keep it.
+	Active *bool `json:"active"`
+}
+
+// CreateWebhook handles POST /webhooks. The response is the only one that
+// includes the signing secret.
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req createWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	hook, err := h.webhookUC.CreateWebhook(middleware.Username(c), req.URL, req.Events)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(hook)
+}
+
+// GetWebhooks handles GET /webhooks.
+func (h *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	hooks, err := h.webhookUC.GetWebhooks(middleware.Username(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(hooks)
+}
+
+// GetWebhook handles GET /webhooks/:id.
+func (h *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	hook, err := h.webhookUC.GetWebhook(middleware.Username(c), c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(hook)
+}
+
+// UpdateWebhook handles PUT /webhooks/:id.
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	var req updateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, problem.ErrBadRequest, "invalid request body")
+	}
+
+	owner, id := middleware.Username(c), c.Params("id")
+	var active bool
+	if req.Active != nil {
+		active = *req.Active
+	} else {
+		current, err := h.webhookUC.GetWebhook(owner, id)
+		if err != nil {
+			return webhookError(c, err)
+		}
+		active = current.Active
+	}
+
+	hook, err := h.webhookUC.UpdateWebhook(owner, id, req.URL, req.Events, active)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(hook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/:id.
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.webhookUC.DeleteWebhook(middleware.Username(c), c.Params("id")); err != nil {
+		return webhookError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetDeliveries handles GET /webhooks/:id/deliveries?status=.
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	deliveries, err := h.webhookUC.GetDeliveries(middleware.Username(c), c.Params("id"), c.Query("status"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(deliveries)
+}
+
+// GetDelivery handles GET /webhooks/:id/deliveries/:deliveryID.
+func (h *WebhookHandler) GetDelivery(c *fiber.Ctx) error {
+	delivery, err := h.webhookUC.GetDelivery(middleware.Username(c), c.Params("id"), c.Params("deliveryID"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(delivery)
+}
+
+// GetDeadLetters handles GET /webhooks/dead-letters.
+func (h *WebhookHandler) GetDeadLetters(c *fiber.Ctx) error {
+	deliveries, err := h.webhookUC.GetDeadLetters(middleware.Username(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(deliveries)
+}
+
+// Redeliver handles POST /webhooks/:id/deliveries/:deliveryID/redeliver.
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	delivery, err := h.webhookUC.Redeliver(middleware.Username(c), c.Params("id"), c.Params("deliveryID"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(http.StatusAccepted).JSON(delivery)
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return problem.Write(c, err, "webhook or delivery not found")
+	case errors.Is(err, domain.ErrInvalidData):
+		return problem.Write(c, err, "url must be an absolute http(s) URL, events known book event types and status pending, succeeded or dead")
+	case errors.Is(err, domain.ErrConflict):
+		return problem.Write(c, err, "delivery is still pending")
+	}
+	return problem.Write(c, err, "")
+}
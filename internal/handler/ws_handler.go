@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Default WebSocket timeouts.
+const (
+	DefaultWSPingInterval = 30 * time.Second
+	DefaultWSPongWait     = 2 * DefaultWSPingInterval
+	DefaultWSWriteWait    = 10 * time.Second
+)
+
+// WSTimeouts control how a WebSocket connection is kept alive.
+type WSTimeouts struct {
+	// PingInterval is how often the server pings an idle connection.
+	PingInterval time.Duration
+	// PongWait is how long a connection may stay silent (no pong and no
+	// message) before it is considered dead. It must exceed PingInterval.
+	PongWait time.Duration
+	// WriteWait bounds every write; a client that cannot accept a frame
+	// within it is treated as a slow consumer and disconnected.
+	WriteWait time.Duration
+}
+
+// DefaultWSTimeouts returns the timeouts used by the API.
+func DefaultWSTimeouts() WSTimeouts {
+	return WSTimeouts{PingInterval: DefaultWSPingInterval, PongWait: DefaultWSPongWait, WriteWait: DefaultWSWriteWait}
+}
+
+const (
+	// wsMaxMessageSize caps client messages, which are small JSON commands.
+	wsMaxMessageSize = 4 << 10
+	// wsMaxSubscriptions caps subscriptions held by a single connection.
+	wsMaxSubscriptions = 100
+	// wsReplyQueueSize bounds command replies waiting to be written.
+	wsReplyQueueSize = 32
+	// wsExpiryLocalKey carries the token expiry from the handshake request
+	// to the connection.
+	wsExpiryLocalKey = "ws_token_expiry"
+)
+
+// Client → server message types.
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+)
+
+// Server → client message types.
+const (
+	wsSubscribed   = "subscribed"
+	wsUnsubscribed = "unsubscribed"
+	wsEvent        = "event"
+	wsError        = "error"
+)
+
+// wsFilter narrows a subscription. Every non-empty field must match.
+type wsFilter struct {
+	BookID string   `json:"book_id,omitempty"`
+	Author string   `json:"author,omitempty"`
+	Types  []string `json:"types,omitempty"`
+}
+
+func (f wsFilter) matches(event domain.BookEvent) bool {
+	if f.BookID != "" && event.BookID != f.BookID {
+		return false
+	}
+	if f.Author != "" && (event.Book == nil || event.Book.Author != f.Author) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// wsCommand is a message sent by the client. A subscription is named by the
+// client-chosen ID and selects either a single book (BookID) or a filter.
+type wsCommand struct {
+	Type   string    `json:"type"`
+	ID     string    `json:"id"`
+	BookID string    `json:"book_id,omitempty"`
+	Filter *wsFilter `json:"filter,omitempty"`
+}
+
+// wsMessage is a message sent to the client.
+type wsMessage struct {
+	Type          string            `json:"type"`
+	ID            string            `json:"id,omitempty"`
+	Subscriptions []string          `json:"subscriptions,omitempty"`
+	Event         *domain.BookEvent `json:"event,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// WSHandler pushes book change events to WebSocket clients.
+type WSHandler struct {
+	stream   domain.BookEventStream
+	timeouts WSTimeouts
+	serve    fiber.Handler
+}
+
+// NewWSHandler wires the handler to the event stream.
+func NewWSHandler(stream domain.BookEventStream, timeouts WSTimeouts) *WSHandler {
+	h := &WSHandler{stream: stream, timeouts: timeouts}
+	h.serve = websocket.New(h.handle)
+	return h
+}
+
+// Connect handles GET /ws. The request must already have passed
+// middleware.WebSocketAuth; anything other than a WebSocket handshake is
+// rejected with 426 Upgrade Required.
+//
+// After the upgrade the client sends JSON commands:
+//
+//	{"type":"subscribe","id":"a","book_id":"<id>"}
+//	{"type":"subscribe","id":"b","filter":{"author":"Ursula K. Le Guin","types":["book.created"]}}
+//	{"type":"unsubscribe","id":"a"}
+//
+// Each command is acknowledged with "subscribed"/"unsubscribed" or answered
+// with "error". Every change matching at least one subscription is delivered
+// once as {"type":"event","subscriptions":[...],"event":{...}}.
+//
+// Events are not buffered per connection beyond the broker's subscriber
+// queue. A client that reads too slowly to keep up is disconnected with
+// close code 1008 rather than slowing publishers or growing memory; it should
+// reconnect and refetch what it cares about.
+//
+// The connection is also closed with code 1008 when the token it was opened
+// with expires; the client should reconnect with a fresh token.
+func (h *WSHandler) Connect(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return problem.Write(c, fiber.ErrUpgradeRequired, "websocket upgrade required")
+	}
+	c.Locals(wsExpiryLocalKey, middleware.TokenExpiry(c))
+	return h.serve(c)
+}
+
+// wsSession is the state of one connection. subs is shared between the read
+// loop, which edits it, and the write loop, which matches events against it.
+type wsSession struct {
+	conn     *websocket.Conn
+	timeouts WSTimeouts
+	mu       sync.Mutex
+	subs     map[string]wsFilter
+	replies  chan wsMessage
+	done     chan struct{}
+}
+
+func (h *WSHandler) handle(conn *websocket.Conn) {
+	s := &wsSession{
+		conn:     conn,
+		timeouts: h.timeouts,
+		subs:     make(map[string]wsFilter),
+		replies:  make(chan wsMessage, wsReplyQueueSize),
+		done:     make(chan struct{}),
+	}
+	sub := h.stream.Subscribe(0)
+	defer sub.Close()
+
+	// A nil channel never fires, so a token without expiry keeps the
+	// connection open.
+	var expired <-chan time.Time
+	if expiry, _ := conn.Locals(wsExpiryLocalKey).(time.Time); !expiry.IsZero() {
+		timer := time.NewTimer(time.Until(expiry))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	go s.readLoop()
+	s.writeLoop(sub.Events(), expired)
+	conn.Close()
+}
+
+// readLoop parses client commands until the connection fails, then signals
+// the write loop through done.
+func (s *wsSession) readLoop() {
+	defer close(s.done)
+
+	s.conn.SetReadLimit(wsMaxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(s.timeouts.PongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(s.timeouts.PongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(s.timeouts.PongWait))
+
+		var cmd wsCommand
+		var reply wsMessage
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			reply = wsMessage{Type: wsError, Error: "invalid JSON message"}
+		} else {
+			reply = s.apply(cmd)
+		}
+
+		select {
+		case s.replies <- reply:
+		default:
+			// The client keeps sending commands without reading the
+			// replies; treat it like any other slow consumer.
+			return
+		}
+	}
+}
+
+// apply executes one command and returns the reply to send.
+func (s *wsSession) apply(cmd wsCommand) wsMessage {
+	if cmd.ID == "" {
+		return wsMessage{Type: wsError, Error: "id is required"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd.Type {
+	case wsSubscribe:
+		var filter wsFilter
+		switch {
+		case cmd.BookID != "" && cmd.Filter != nil:
+			return wsMessage{Type: wsError, ID: cmd.ID, Error: "use either book_id or filter, not both"}
+		case cmd.BookID != "":
+			filter.BookID = cmd.BookID
+		case cmd.Filter != nil:
+			filter = *cmd.Filter
+		default:
+			return wsMessage{Type: wsError, ID: cmd.ID, Error: "book_id or filter is required"}
+		}
+		if _, exists := s.subs[cmd.ID]; !exists && len(s.subs) >= wsMaxSubscriptions {
+			return wsMessage{Type: wsError, ID: cmd.ID, Error: "too many subscriptions"}
+		}
+		s.subs[cmd.ID] = filter
+		return wsMessage{Type: wsSubscribed, ID: cmd.ID}
+	case wsUnsubscribe:
+		if _, ok := s.subs[cmd.ID]; !ok {
+			return wsMessage{Type: wsError, ID: cmd.ID, Error: "unknown subscription"}
+		}
+		delete(s.subs, cmd.ID)
+		return wsMessage{Type: wsUnsubscribed, ID: cmd.ID}
+	default:
+		return wsMessage{Type: wsError, ID: cmd.ID, Error: "unknown message type"}
+	}
+}
+
+// matching returns the IDs of the subscriptions that select event.
+func (s *wsSession) matching(event domain.BookEvent) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, filter := range s.subs {
+		if filter.matches(event) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// writeLoop is the only goroutine that writes to the connection. It returns
+// when the client goes away, a write times out, the broker drops the
+// connection for falling behind, or the token expires.
+func (s *wsSession) writeLoop(events <-chan domain.BookEvent, expired <-chan time.Time) {
+	ping := time.NewTicker(s.timeouts.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case reply := <-s.replies:
+			if s.write(reply) != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				s.close(websocket.ClosePolicyViolation, "slow consumer")
+				return
+			}
+			ids := s.matching(event)
+			if len(ids) == 0 {
+				continue
+			}
+			if s.write(wsMessage{Type: wsEvent, Subscriptions: ids, Event: &event}) != nil {
+				return
+			}
+		case <-ping.C:
+			if s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.timeouts.WriteWait)) != nil {
+				return
+			}
+		case <-expired:
+			s.close(websocket.ClosePolicyViolation, "token expired")
+			return
+		}
+	}
+}
+
+func (s *wsSession) write(msg wsMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeouts.WriteWait))
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *wsSession) close(code int, reason string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(s.timeouts.WriteWait))
+}
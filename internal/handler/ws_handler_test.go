@@ -0,0 +1,266 @@
+package handler_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+	"github.com/andrimuhayat/crud-test/internal/handler"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// expiringAuth issues tokens that expire shortly after they are checked.
+type expiringAuth struct {
+	*usecase.AuthUseCase
+	ttl time.Duration
+}
+
+func (a expiringAuth) TokenExpiry(token string) (time.Time, error) {
+	if _, err := a.ValidateToken(token); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(a.ttl), nil
+}
+
+// wsFixture serves GET /ws on a real listener, backed by its own broker.
+type wsFixture struct {
+	t      *testing.T
+	broker *eventbus.Broker
+	url    string
+	token  string
+}
+
+func newWSFixture(t *testing.T, auth domain.AuthUseCase, timeouts handler.WSTimeouts, subscriberSize int) *wsFixture {
+	t.Helper()
+	broker := eventbus.NewBroker(eventbus.DefaultReplaySize, subscriberSize)
+	app := fiber.New(fiber.Config{Immutable: true, DisableStartupMessage: true})
+	app.Get("/ws", middleware.WebSocketAuth(auth), handler.NewWSHandler(broker, timeouts).Connect)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.ShutdownWithTimeout(100 * time.Millisecond) })
+
+	token, err := auth.GenerateToken("admin", "secret")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return &wsFixture{t: t, broker: broker, url: "ws://" + ln.Addr().String() + "/ws", token: token}
+}
+
+// dial opens a connection authenticated with ?access_token=.
+func (f *wsFixture) dial() *websocket.Conn {
+	f.t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(f.url+"?access_token="+url.QueryEscape(f.token), nil)
+	if err != nil {
+		f.t.Fatalf("dial: %v (%v)", err, resp)
+	}
+	f.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// send writes a command and returns the next message.
+func (f *wsFixture) send(conn *websocket.Conn, cmd string) map[string]any {
+	f.t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(cmd)); err != nil {
+		f.t.Fatalf("write %s: %v", cmd, err)
+	}
+	return f.read(conn)
+}
+
+func (f *wsFixture) read(conn *websocket.Conn) map[string]any {
+	f.t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		f.t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// readClose reads until the connection fails and returns the close error.
+func readClose(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("read: got %v, want a close frame", err)
+			}
+			return closeErr
+		}
+	}
+}
+
+// TestWebSocketAuth verifies that the handshake requires a valid token in
+// the Authorization header or ?access_token=.
+func TestWebSocketAuth(t *testing.T) {
+	f := newWSFixture(t, usecase.NewAuthUseCase(), handler.DefaultWSTimeouts(), eventbus.DefaultSubscriberSize)
+
+	for name, tc := range map[string]struct {
+		query  string
+		header http.Header
+		want   int
+	}{
+		"no token":      {"", nil, http.StatusUnauthorized},
+		"invalid query": {"?access_token=nope", nil, http.StatusUnauthorized},
+		"query token":   {"?access_token=" + url.QueryEscape(f.token), nil, http.StatusSwitchingProtocols},
+		"header token":  {"", http.Header{"Authorization": {"Bearer " + f.token}}, http.StatusSwitchingProtocols},
+		"header wins":   {"?access_token=" + url.QueryEscape(f.token), http.Header{"Authorization": {"Bearer nope"}}, http.StatusUnauthorized},
+		"basic scheme":  {"", http.Header{"Authorization": {"Basic " + f.token}}, http.StatusUnauthorized},
+	} {
+		conn, resp, err := websocket.DefaultDialer.Dial(f.url+tc.query, tc.header)
+		if resp == nil {
+			t.Fatalf("%s: dial: %v", name, err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: got %d, want %d", name, resp.StatusCode, tc.want)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+// TestWebSocketSubscriptions subscribes by book and by filter and verifies
+// that each event arrives once, naming the subscriptions it matched, and
+// stops arriving after unsubscribe.
+func TestWebSocketSubscriptions(t *testing.T) {
+	f := newWSFixture(t, usecase.NewAuthUseCase(), handler.DefaultWSTimeouts(), eventbus.DefaultSubscriberSize)
+	conn := f.dial()
+
+	for cmd, want := range map[string]string{
+		`{"type":"subscribe","id":"one","book_id":"b1"}`:                                                   "subscribed",
+		`{"type":"subscribe","id":"herbert","filter":{"author":"Frank Herbert","types":["book.updated"]}}`: "subscribed",
+		`{"type":"subscribe","id":"x"}`:                                                                    "error",
+		`{"type":"subscribe","id":"x","book_id":"b1","filter":{}}`:                                         "error",
+		`{"type":"subscribe","book_id":"b1"}`:                                                              "error",
+		`{"type":"unsubscribe","id":"unknown"}`:                                                            "error",
+		`{"type":"rename","id":"one"}`:                                                                     "error",
+		`not json`:                                                                                         "error",
+	} {
+		if got := f.send(conn, cmd); got["type"] != want {
+			t.Errorf("%s: got %v, want %s", cmd, got, want)
+		}
+	}
+
+	dune := &domain.Book{ID: "b1", Title: "Dune", Author: "Frank Herbert"}
+	f.broker.Publish(domain.BookEvent{ID: "e1", Type: domain.EventBookCreated, BookID: "b1", Book: dune})
+	f.broker.Publish(domain.BookEvent{ID: "e2", Type: domain.EventBookUpdated, BookID: "b2", Book: &domain.Book{ID: "b2", Author: "Ursula K. Le Guin"}})
+	f.broker.Publish(domain.BookEvent{ID: "e3", Type: domain.EventBookUpdated, BookID: "b1", Book: dune})
+	for _, want := range []struct{ id, subs string }{{"e1", "[one]"}, {"e3", "[herbert one]"}} {
+		msg := f.read(conn)
+		event, _ := msg["event"].(map[string]any)
+		if msg["type"] != "event" || event["id"] != want.id || fmt.Sprint(msg["subscriptions"]) != want.subs {
+			t.Errorf("got %v, want event %s for %s", msg, want.id, want.subs)
+		}
+	}
+
+	if got := f.send(conn, `{"type":"unsubscribe","id":"one"}`); got["type"] != "unsubscribed" || got["id"] != "one" {
+		t.Errorf("unsubscribe: got %v", got)
+	}
+	f.broker.Publish(domain.BookEvent{ID: "e4", Type: domain.EventBookDeleted, BookID: "b1", Book: dune})
+	f.broker.Publish(domain.BookEvent{ID: "e5", Type: domain.EventBookUpdated, BookID: "b1", Book: dune})
+	if msg := f.read(conn); msg["event"].(map[string]any)["id"] != "e5" || fmt.Sprint(msg["subscriptions"]) != "[herbert]" {
+		t.Errorf("after unsubscribe: got %v, want only e5 for herbert", msg)
+	}
+}
+
+// TestWebSocketSlowConsumer verifies that a client the broker drops for not
+// keeping up is disconnected with close code 1008.
+func TestWebSocketSlowConsumer(t *testing.T) {
+	f := newWSFixture(t, usecase.NewAuthUseCase(), handler.DefaultWSTimeouts(), 1)
+	conn := f.dial()
+	f.send(conn, `{"type":"subscribe","id":"all","filter":{}}`)
+
+	// Publishing is far faster than writing frames, so a queue of one
+	// overflows long before the loop ends.
+	for i := 0; i < 10000; i++ {
+		f.broker.Publish(domain.BookEvent{Type: domain.EventBookUpdated, BookID: "b1"})
+	}
+	if err := readClose(t, conn); err.Code != websocket.ClosePolicyViolation || err.Text != "slow consumer" {
+		t.Errorf("close: got %d %q, want 1008 slow consumer", err.Code, err.Text)
+	}
+}
+
+// TestWebSocketHeartbeat verifies that the server pings, keeps a client that
+// answers with pongs and drops one that stays silent.
+func TestWebSocketHeartbeat(t *testing.T) {
+	timeouts := handler.WSTimeouts{PingInterval: 20 * time.Millisecond, PongWait: 100 * time.Millisecond, WriteWait: time.Second}
+	f := newWSFixture(t, usecase.NewAuthUseCase(), timeouts, eventbus.DefaultSubscriberSize)
+
+	alive := f.dial()
+	var pings atomic.Int32
+	alive.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return alive.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	replies := make(chan map[string]any, 1)
+	go func() {
+		var msg map[string]any
+		if alive.ReadJSON(&msg) == nil {
+			replies <- msg
+		}
+		close(replies)
+	}()
+
+	silent := f.dial()
+	silent.SetPingHandler(func(string) error { return nil })
+	start := time.Now()
+	silent.SetReadDeadline(start.Add(5 * time.Second))
+	_, _, err := silent.ReadMessage()
+	var netErr net.Error
+	if err == nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+		t.Errorf("silent client: got %v, want the server to close the connection", err)
+	}
+	if elapsed := time.Since(start); elapsed < timeouts.PongWait {
+		t.Errorf("silent client closed after %v, before the pong wait", elapsed)
+	}
+
+	// The answering client has outlived several pong waits by now.
+	if err := alive.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","id":"a","book_id":"b1"}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := <-replies; msg["type"] != "subscribed" {
+		t.Errorf("answering client: got %v, want subscribed", msg)
+	}
+	if n := pings.Load(); n < 2 {
+		t.Errorf("pings: got %d, want at least 2", n)
+	}
+}
+
+// TestWebSocketTokenExpiry verifies that a connection is closed with code
+// 1008 when the token it was opened with expires.
+func TestWebSocketTokenExpiry(t *testing.T) {
+	auth := usecase.NewAuthUseCase()
+	token, _ := auth.GenerateToken("admin", "secret")
+	if expiry, err := auth.TokenExpiry(token); err != nil || time.Until(expiry) < 23*time.Hour || time.Until(expiry) > 24*time.Hour {
+		t.Errorf("TokenExpiry: got %v, %v, want in 24 hours", expiry, err)
+	}
+
+	f := newWSFixture(t, expiringAuth{auth, 150 * time.Millisecond}, handler.DefaultWSTimeouts(), eventbus.DefaultSubscriberSize)
+	conn := f.dial()
+	start := time.Now()
+	if got := f.send(conn, `{"type":"subscribe","id":"a","book_id":"b1"}`); got["type"] != "subscribed" {
+		t.Fatalf("subscribe: got %v", got)
+	}
+	if err := readClose(t, conn); err.Code != websocket.ClosePolicyViolation || err.Text != "token expired" {
+		t.Errorf("close: got %d %q, want 1008 token expired", err.Code, err.Text)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("closed after %v, before the token expired", elapsed)
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Audit returns a Fiber middleware that records every request passing through
+// it in the audit log. It must be mounted after Auth so that the subject is
+// known. The action is the method and route pattern without its API version
+// (e.g. "DELETE /books/:id" for both DELETE /v1/books/:id and its deprecated
+// alias) so that entries for the same endpoint can be filtered together.
+//
+// A failure to write the audit entry is logged but does not fail the request,
+// since the action itself has already taken effect.
+func Audit(auditUC domain.AuditUseCase) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			status = fe.Code
+		} else if err != nil {
+			status = http.StatusInternalServerError
+		}
+
+		outcome := domain.AuditSuccess
+		if status >= http.StatusBadRequest {
+			outcome = domain.AuditFailure
+		}
+
+		route := versionPrefix.ReplaceAllString(c.Route().Path, "/")
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		entry := domain.AuditEntry{
+			Time:     time.Now(),
+			Subject:  Username(c),
+			Action:   c.Method() + " " + route,
+			BookID:   auditBookID(c, route, status),
+			Outcome:  outcome,
+			Status:   status,
+			ClientIP: c.IP(),
+		}
+		if recErr := auditUC.Record(entry); recErr != nil {
+			log.Printf("audit: record %s by %s: %v", entry.Action, entry.Subject, recErr)
+		}
+		return err
+	}
+}
+
+// versionPrefix matches the API version prefix of a route, such as "/v1/".
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+// auditBookID extracts the book a request acted on: the :id of a /books/:id
+// route, a :bookID parameter elsewhere, or the id of a newly created book.
+// route is the route pattern without its API version.
+func auditBookID(c *fiber.Ctx, route string, status int) string {
+	if strings.HasPrefix(route, "/books/:id") {
+		return c.Params("id")
+	}
+	if id := c.Params("bookID"); id != "" {
+		return id
+	}
+	if status == http.StatusCreated && route == "/books" {
+		var created struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(c.Response().Body(), &created) == nil {
+			return created.ID
+		}
+	}
+	return ""
+}
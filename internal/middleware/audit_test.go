@@ -0,0 +1,60 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestAuditVersionedRoutes verifies that writes through /v1 and through the
+// unversioned aliases are recorded under the same action, with the book they
+// acted on.
+func TestAuditVersionedRoutes(t *testing.T) {
+	auditUC := usecase.NewAuditUseCase(memory.NewAuditRepository())
+	app := fiber.New()
+	for _, prefix := range []string{"/v1", ""} {
+		books := app.Group(prefix+"/books", middleware.Auth(tokenAuth{}), middleware.Audit(auditUC))
+		books.Post("/", func(c *fiber.Ctx) error {
+			return c.Status(http.StatusCreated).JSON(fiber.Map{"id": "b1"})
+		})
+		books.Delete("/:id", func(c *fiber.Ctx) error {
+			return c.SendStatus(http.StatusNoContent)
+		})
+	}
+
+	for _, r := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/books"},
+		{http.MethodDelete, "/v1/books/b1"},
+		{http.MethodDelete, "/books/b2"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer ann")
+		if _, err := app.Test(req, -1); err != nil {
+			t.Fatalf("%s %s: %v", r.method, r.path, err)
+		}
+	}
+
+	entries, err := auditUC.Query(domain.AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	want := []struct{ action, bookID string }{
+		{"DELETE /books/:id", "b2"},
+		{"DELETE /books/:id", "b1"},
+		{"POST /books", "b1"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries: got %d, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Action != w.action || e.BookID != w.bookID || e.Subject != "ann" {
+			t.Errorf("entry %d: got %s %q by %s, want %s %q by ann", i, e.Action, e.BookID, e.Subject, w.action, w.bookID)
+		}
+	}
+}
@@ -3,12 +3,21 @@ package middleware
 
 import (
 	"strings"
+	"time"
 
 	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
 	"github.com/gofiber/fiber/v2"
 )
 
-const usernameLocalKey = "username"
+const (
+	usernameLocalKey    = "username"
+	tokenExpiryLocalKey = "token_expiry"
+)
+
+// accessTokenQueryParam carries the JWT for clients that cannot set headers,
+// such as browser WebSocket connections.
+const accessTokenQueryParam = "access_token"
 
 // Auth returns a Fiber middleware that validates a Bearer JWT.
 // On success the parsed subject claim is stored in c.Locals("username").
@@ -16,21 +25,77 @@ func Auth(authUC domain.AuthUseCase) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing authorization header"})
+			return unauthorized(c, "missing authorization header")
 		}
-
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid authorization header format"})
+		if token, err := authenticate(c, authUC, authHeader); token == "" {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		username, err := authUC.ValidateToken(parts[1])
+// WebSocketAuth is Auth for WebSocket upgrade requests. Browsers cannot set
+// headers on a WebSocket handshake, so the token may instead be passed as
+// ?access_token=; the Authorization header takes precedence when present.
+//
+// A connection outlives the handshake, so the token's expiry is also stored
+// for TokenExpiry; the WebSocket handler closes the connection when it passes.
+func WebSocketAuth(authUC domain.AuthUseCase) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			token := c.Query(accessTokenQueryParam)
+			if token == "" {
+				return unauthorized(c, "missing authorization header or access_token")
+			}
+			authHeader = "Bearer " + token
+		}
+		token, err := authenticate(c, authUC, authHeader)
+		if token == "" {
+			return err
+		}
+		expiry, err := authUC.TokenExpiry(token)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+			return unauthorized(c, "invalid or expired token")
 		}
-
-		c.Locals(usernameLocalKey, username)
+		c.Locals(tokenExpiryLocalKey, expiry)
 		return c.Next()
 	}
 }
 
+// authenticate validates a Bearer header and stores its subject. It returns
+// the token, or "" after writing the 401 response, whose error it returns.
+func authenticate(c *fiber.Ctx, authUC domain.AuthUseCase, authHeader string) (string, error) {
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", unauthorized(c, "invalid authorization header format")
+	}
+
+	username, err := authUC.ValidateToken(parts[1])
+	if err != nil {
+		return "", unauthorized(c, "invalid or expired token")
+	}
+
+	c.Locals(usernameLocalKey, username)
+	return parts[1], nil
+}
+
+// Username returns the authenticated subject stored by Auth, or "" when the
+// request did not pass through Auth.
+func Username(c *fiber.Ctx) string {
+	username, _ := c.Locals(usernameLocalKey).(string)
+	return username
+}
+
+// TokenExpiry returns when the token accepted by WebSocketAuth expires, or
+// the zero time if it never does or the request did not pass through it.
+func TokenExpiry(c *fiber.Ctx) time.Time {
+	expiry, _ := c.Locals(tokenExpiryLocalKey).(time.Time)
+	return expiry
+}
+
+// unauthorized rejects the request with a problem and a Bearer challenge.
+func unauthorized(c *fiber.Ctx, detail string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+	return problem.Write(c, domain.ErrUnauthorized, detail)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeprecationConfig describes routes marked for removal.
+type DeprecationConfig struct {
+	// Since is when the routes were deprecated.
+	Since time.Time
+	// Sunset is when the routes are expected to stop working. The zero value
+	// omits the Sunset header.
+	Sunset time.Time
+	// Successor returns the URL that replaces the request's, or "" if there
+	// is none. A nil Successor omits the Link header.
+	Successor func(c *fiber.Ctx) string
+}
+
+// Deprecation returns a Fiber middleware that marks every response of the
+// routes it is mounted on as deprecated: a Deprecation header (RFC 9745), a
+// Sunset header (RFC 8594) and a Link to the successor version. The headers
+// are set before the handler runs so that error responses carry them too.
+func Deprecation(cfg DeprecationConfig) fiber.Handler {
+	deprecation := "@" + strconv.FormatInt(cfg.Since.Unix(), 10)
+	var sunset string
+	if !cfg.Sunset.IsZero() {
+		sunset = cfg.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", deprecation)
+		if sunset != "" {
+			c.Set("Sunset", sunset)
+		}
+		if cfg.Successor != nil {
+			if url := cfg.Successor(c); url != "" {
+				c.Append(fiber.HeaderLink, "<"+url+`>; rel="successor-version"`)
+			}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Idempotency headers.
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response replayed from the store.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// MaxIdempotencyKeyLength caps the length of an Idempotency-Key.
+const MaxIdempotencyKeyLength = 255
+
+// Idempotency returns a Fiber middleware that makes POST and PATCH requests
+// carrying an Idempotency-Key header safe to retry. It must be mounted after
+// Auth: keys are scoped to the authenticated user.
+//
+// The first request with a key runs and its response is stored; a retry with
+// the same key, method, URL, Content-Type, body and negotiated response media
+// type (from Accept, as formats negotiates it) gets that response again,
+// marked with Idempotent-Replayed: true, without running the handler. Reusing
+// a key for a different request fails with 422, and a retry arriving while
+// the first request is still running fails with 409 and Retry-After.
+//
+// Server errors are not stored, so a request that failed with a 5xx runs
+// again when retried. Requests without the header, and other methods, pass
+// through untouched.
+func Idempotency(idemUC domain.IdempotencyUseCase, formats *serializer.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" || (c.Method() != fiber.MethodPost && c.Method() != fiber.MethodPatch) {
+			return c.Next()
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			return problem.Write(c, problem.ErrBadRequest, "Idempotency-Key must be at most 255 characters")
+		}
+
+		owner := Username(c)
+		rec, err := idemUC.Begin(owner, key, fingerprint(c, formats))
+		switch {
+		case errors.Is(err, domain.ErrIdempotencyKeyInUse):
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return problem.Write(c, err, "a request with this Idempotency-Key is still being processed")
+		case errors.Is(err, domain.ErrIdempotencyKeyReused):
+			return problem.Write(c, err, "this Idempotency-Key was used for a different request")
+		case err != nil:
+			return problem.Write(c, err, "")
+		case rec.Response != nil:
+			replay := rec.Response
+			c.Set(HeaderIdempotentReplayed, "true")
+			if replay.ContentType != "" {
+				c.Set(fiber.HeaderContentType, replay.ContentType)
+			}
+			if replay.Location != "" {
+				c.Set(fiber.HeaderLocation, replay.Location)
+			}
+			return c.Status(replay.Status).Send(replay.Body)
+		}
+
+		// Until the response is stored, the reservation is released on every
+		// way out, panics included, so that a retry is not locked out.
+		stored := false
+		defer func() {
+			if !stored {
+				if err := idemUC.Abandon(rec); err != nil {
+					log.Printf("idempotency: release key of %s: %v", owner, err)
+				}
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		resp := c.Response()
+		if resp.StatusCode() >= http.StatusInternalServerError {
+			return nil
+		}
+		err = idemUC.Complete(owner, key, domain.IdempotentResponse{
+			Status:      resp.StatusCode(),
+			ContentType: string(resp.Header.ContentType()),
+			Location:    string(resp.Header.Peek(fiber.HeaderLocation)),
+			Body:        resp.Body(),
+		})
+		if err != nil {
+			log.Printf("idempotency: store response for %s: %v", owner, err)
+			return nil
+		}
+		stored = true
+		return nil
+	}
+}
+
+// fingerprint identifies a request by its method, URL, Content-Type, body and
+// the media type its response will have. Accept headers that negotiate the
+// same type count as the same request; one that negotiates none counts as
+// its own.
+func fingerprint(c *fiber.Ctx, formats *serializer.Registry) string {
+	_, mediaType, ok := formats.Negotiate(c.Get(fiber.HeaderAccept))
+	if !ok {
+		mediaType = "unacceptable:" + c.Get(fiber.HeaderAccept)
+	}
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(c.Method()), []byte(c.OriginalURL()), c.Request().Header.ContentType(), c.Body(), []byte(mediaType)} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
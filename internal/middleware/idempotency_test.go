@@ -0,0 +1,171 @@
+package middleware_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/middleware"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+)
+
+// tokenAuth accepts any token and uses it as the username.
+type tokenAuth struct{}
+
+func (tokenAuth) GenerateToken(username, _ string) (string, error) { return username, nil }
+func (tokenAuth) ValidateToken(token string) (string, error)       { return token, nil }
+func (tokenAuth) TokenExpiry(string) (time.Time, error)            { return time.Time{}, nil }
+
+type idempotencyFixture struct {
+	t     *testing.T
+	app   *fiber.App
+	calls atomic.Int32
+	// status is the status of the next response. When block is set, the
+	// handler signals entered and waits for block to be closed.
+	status  atomic.Int32
+	entered chan struct{}
+	block   chan struct{}
+	// accept, when set, is sent as the Accept header.
+	accept string
+}
+
+func newIdempotencyFixture(t *testing.T) *idempotencyFixture {
+	t.Helper()
+	f := &idempotencyFixture{t: t}
+	f.status.Store(http.StatusCreated)
+	idemUC := usecase.NewIdempotencyUseCase(memory.NewIdempotencyRepository(), 0)
+
+	f.app = fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	f.app.Post("/books", middleware.Auth(tokenAuth{}), middleware.Idempotency(idemUC, serializer.Default()), func(c *fiber.Ctx) error {
+		n := f.calls.Add(1)
+		if f.block != nil {
+			f.entered <- struct{}{}
+			<-f.block
+		}
+		c.Location(fmt.Sprintf("/books/%d", n))
+		return c.Status(int(f.status.Load())).JSON(fiber.Map{"call": n})
+	})
+	return f
+}
+
+func (f *idempotencyFixture) post(user, key, body string) (*http.Response, string) {
+	f.t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+user)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+	}
+	if f.accept != "" {
+		req.Header.Set(fiber.HeaderAccept, f.accept)
+	}
+	resp, err := f.app.Test(req, -1)
+	if err != nil {
+		f.t.Fatalf("POST /books: %v", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+// TestIdempotencyReplay verifies that a retry gets the stored response without
+// running the handler, and that keys are scoped to their user.
+func TestIdempotencyReplay(t *testing.T) {
+	f := newIdempotencyFixture(t)
+
+	first, firstBody := f.post("ann", "k1", `{"title":"Emma"}`)
+	retry, retryBody := f.post("ann", "k1", `{"title":"Emma"}`)
+	if first.StatusCode != http.StatusCreated || retry.StatusCode != http.StatusCreated {
+		t.Fatalf("statuses %d and %d, want 201", first.StatusCode, retry.StatusCode)
+	}
+	if retryBody != firstBody || retry.Header.Get(fiber.HeaderLocation) != first.Header.Get(fiber.HeaderLocation) {
+		t.Errorf("replay: got %s at %q, want %s at %q", retryBody, retry.Header.Get(fiber.HeaderLocation),
+			firstBody, first.Header.Get(fiber.HeaderLocation))
+	}
+	if first.Header.Get(middleware.HeaderIdempotentReplayed) != "" || retry.Header.Get(middleware.HeaderIdempotentReplayed) != "true" {
+		t.Errorf("%s: got %q then %q", middleware.HeaderIdempotentReplayed,
+			first.Header.Get(middleware.HeaderIdempotentReplayed), retry.Header.Get(middleware.HeaderIdempotentReplayed))
+	}
+
+	f.post("bob", "k1", `{"title":"Emma"}`)
+	f.post("ann", "", `{"title":"Emma"}`)
+	if n := f.calls.Load(); n != 3 {
+		t.Errorf("handler ran %d times, want 3", n)
+	}
+}
+
+// TestIdempotencyConflicts verifies the 422 for a key reused with another
+// body and the 409 for a retry while the first request is in flight.
+func TestIdempotencyConflicts(t *testing.T) {
+	f := newIdempotencyFixture(t)
+
+	f.post("ann", "k1", `{"title":"Emma"}`)
+	resp, body := f.post("ann", "k1", `{"title":"Persuasion"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, problem.KeyReused.Code) {
+		t.Errorf("reused key: got %d %s, want 422 %s", resp.StatusCode, body, problem.KeyReused.Code)
+	}
+
+	f.entered, f.block = make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.post("ann", "k2", `{"title":"Emma"}`)
+	}()
+	<-f.entered
+	resp, body = f.post("ann", "k2", `{"title":"Emma"}`)
+	close(f.block)
+	<-done
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get(fiber.HeaderRetryAfter) == "" || !strings.Contains(body, problem.KeyInUse.Code) {
+		t.Errorf("in-flight key: got %d %s, want 409 %s with Retry-After", resp.StatusCode, body, problem.KeyInUse.Code)
+	}
+
+	long := strings.Repeat("k", middleware.MaxIdempotencyKeyLength+1)
+	if resp, _ := f.post("ann", long, `{}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("long key: got %d, want 400", resp.StatusCode)
+	}
+}
+
+// TestIdempotencyServerErrorReleasesKey verifies that a 5xx is not stored, so
+// that a retry runs the handler again.
+func TestIdempotencyServerErrorReleasesKey(t *testing.T) {
+	f := newIdempotencyFixture(t)
+
+	f.status.Store(http.StatusServiceUnavailable)
+	if resp, _ := f.post("ann", "k1", `{}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", resp.StatusCode)
+	}
+	f.status.Store(http.StatusCreated)
+	resp, _ := f.post("ann", "k1", `{}`)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get(middleware.HeaderIdempotentReplayed) != "" || f.calls.Load() != 2 {
+		t.Errorf("retry: got %d after %d calls, want a fresh 201", resp.StatusCode, f.calls.Load())
+	}
+}
+
+// TestIdempotencyAccept verifies that a retry asking for another response
+// format is a different request, while Accept headers that negotiate the
+// same format replay the stored response.
+func TestIdempotencyAccept(t *testing.T) {
+	f := newIdempotencyFixture(t)
+
+	f.post("ann", "k1", `{"title":"Emma"}`)
+	f.accept = "application/json, */*;q=0.1"
+	if resp, _ := f.post("ann", "k1", `{"title":"Emma"}`); resp.Header.Get(middleware.HeaderIdempotentReplayed) != "true" {
+		t.Errorf("same format: got %d without replay, want the stored response", resp.StatusCode)
+	}
+	f.accept = "application/xml"
+	if resp, body := f.post("ann", "k1", `{"title":"Emma"}`); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, problem.KeyReused.Code) {
+		t.Errorf("other format: got %d %s, want 422 %s", resp.StatusCode, body, problem.KeyReused.Code)
+	}
+	if n := f.calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
@@ -0,0 +1,213 @@
+// Package openapi models the subset of an OpenAPI 3.1 document the API
+// publishes, derives JSON Schemas from Go types and validates JSON values
+// against them.
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Version is the OpenAPI version documents are written in.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps a lower-case HTTP method to its operation.
+type PathItem map[string]*Operation
+
+// Operation describes one method on one path.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the accepted request payloads by media type.
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response describes one response status.
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType holds the schema of one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds reusable schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement maps a security scheme name to its required scopes.
+type SecurityRequirement map[string][]string
+
+// Schema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1). The zero
+// value accepts any JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	// Closed forbids properties not listed in Properties. It is written as
+	// "additionalProperties": false.
+	Closed bool `json:"-"`
+}
+
+// MarshalJSON writes Closed as "additionalProperties": false.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain: plain(s)})
+}
+
+// UnmarshalJSON reads "additionalProperties" as either a schema or false.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	var raw struct {
+		plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Schema(raw.plain)
+	switch strings.TrimSpace(string(raw.AdditionalProperties)) {
+	case "", "true":
+	case "false":
+		s.Closed = true
+	default:
+		s.AdditionalProperties = new(Schema)
+		return json.Unmarshal(raw.AdditionalProperties, s.AdditionalProperties)
+	}
+	return nil
+}
+
+// Types is the JSON Schema "type" keyword. A single type is written as a
+// string and several, such as a nullable value, as an array.
+type Types []string
+
+// MarshalJSON writes one type as a string.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON accepts a string or an array of strings.
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether typ is one of the types.
+func (t Types) Has(typ string) bool {
+	for _, v := range t {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Add registers op under method and path, which uses OpenAPI {param} syntax.
+func (d *Document) Add(method, path string, op *Operation) {
+	if d.Paths == nil {
+		d.Paths = make(map[string]PathItem)
+	}
+	item := d.Paths[path]
+	if item == nil {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Operation returns the operation for method and path, or nil.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Resolve follows a local "#/components/schemas/" reference. Schemas without a
+// reference, or with a dangling one, are returned as they are.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		target, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)]
+		if !ok {
+			return s
+		}
+		s = target
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Generator derives schemas from Go types the way encoding/json encodes them.
+// Named struct types are registered once in Schemas, under their type name
+// with the first letter upper-cased, and referenced with $ref.
+type Generator struct {
+	Schemas map[string]*Schema
+}
+
+// NewGenerator returns a Generator with no registered schemas.
+func NewGenerator() *Generator {
+	return &Generator{Schemas: make(map[string]*Schema)}
+}
+
+// Schema returns the schema for the type of v.
+func (g *Generator) Schema(v any) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+// Ref returns a reference to the registered schema called name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: schemaRefPrefix + name}
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case durationType:
+		return &Schema{Type: Types{"integer"}, Description: "nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaOf(t.Elem())
+		return nullable(s)
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		// encoding/json writes a nil slice as null.
+		return &Schema{Type: Types{"array", "null"}, Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object", "null"}, AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := []rune(t.Name())
+		name[0] = unicode.ToUpper(name[0])
+		key := string(name)
+		if _, ok := g.Schemas[key]; !ok {
+			g.Schemas[key] = &Schema{} // placeholder for recursive types
+			g.Schemas[key] = g.structSchema(t)
+		}
+		return Ref(key)
+	}
+	return &Schema{}
+}
+
+// structSchema lists the exported fields by their JSON names. Fields without
+// omitempty or omitzero are required; the object is closed so that a field a
+// handler adds outside the type is caught.
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema), Closed: true}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// nullable lets s also match null. A reference is wrapped because sibling
+// keywords next to $ref would otherwise have to repeat the target.
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AnyOf: []*Schema{s, {Type: Types{"null"}}}}
+	}
+	if len(s.Type) > 0 && !s.Type.Has("null") {
+		s.Type = append(s.Type, "null")
+	}
+	return s
+}
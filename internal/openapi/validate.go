@@ -0,0 +1,156 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validate checks a JSON document against s, resolving references in d. It
+// returns every violation found, each prefixed with its JSON path.
+func (d *Document) Validate(s *Schema, raw []byte) error {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	var errs []string
+	d.validate(s, v, "$", &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (d *Document) validate(s *Schema, v any, path string, errs *[]string) {
+	s = d.Resolve(s)
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		*errs = append(*errs, fmt.Sprintf("%s: unresolved reference %s", path, s.Ref))
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, alt := range s.AnyOf {
+			var altErrs []string
+			d.validate(alt, v, path, &altErrs)
+			if len(altErrs) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*errs = append(*errs, fmt.Sprintf("%s: matches none of the anyOf schemas", path))
+			return
+		}
+	}
+
+	if len(s.Type) > 0 && !typeMatches(s.Type, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: got %s, want %s", path, jsonType(v), strings.Join(s.Type, " or ")))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: %v is not one of %v", path, v, s.Enum))
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			*errs = append(*errs, fmt.Sprintf("%s: shorter than %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			*errs = append(*errs, fmt.Sprintf("%s: longer than %d characters", path, *s.MaxLength))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: %q is not a date-time", path, v))
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is less than %v", path, v, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is greater than %v", path, v, *s.Maximum))
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch prop, ok := s.Properties[k]; {
+			case ok:
+				d.validate(prop, v[k], path+"."+k, errs)
+			case s.Closed:
+				*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, k))
+			case s.AdditionalProperties != nil:
+				d.validate(s.AdditionalProperties, v[k], path+"."+k, errs)
+			}
+		}
+	}
+}
+
+func typeMatches(types Types, v any) bool {
+	got := jsonType(v)
+	if types.Has(got) {
+		return true
+	}
+	if f, ok := v.(float64); ok && types.Has("integer") {
+		return f == math.Trunc(f)
+	}
+	return false
+}
+
+// jsonType names the JSON Schema type of a value decoded by encoding/json.
+// Numbers are reported as "number"; typeMatches accepts whole ones as integer.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		// Compare through JSON so that Go ints in the schema match decoded
+		// float64 values.
+		raw, _ := json.Marshal(e)
+		var want any
+		json.Unmarshal(raw, &want)
+		if reflect.DeepEqual(want, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Package problem writes errors as RFC 9457 problem details
+// (application/problem+json). Every error response of the API goes through
+// Write, which maps domain sentinel errors, wrapped or not, to a status, a
+// stable machine-readable code and a type URI.
+package problem
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ContentType is the media type of every error response.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes every problem type URI. Type URIs are relative references
+// to GET /problems/:code, which describes the problem type.
+const TypeBase = "/problems/"
+
+// ErrBadRequest marks a request the handler could not parse, such as a
+// malformed body or a non-numeric path parameter.
+var ErrBadRequest = errors.New("bad request")
+
+// ErrNotAcceptable marks a request whose Accept header allows none of the
+// formats the endpoint can respond in.
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Details is a problem details object with the API's extension members: a
+// stable code, the request ID and, for validation failures, every invalid
+// field.
+type Details struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []domain.FieldError `json:"errors,omitempty"`
+}
+
+// Kind is one problem type. Codes are part of the API contract: clients may
+// switch on them, so they never change once published.
+type Kind struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+// Type returns the kind's type URI.
+func (k Kind) Type() string {
+	return TypeBase + k.Code
+}
+
+// The problem types the API reports.
+var (
+	BadRequest       = Kind{"bad_request", http.StatusBadRequest, "Malformed request"}
+	InvalidData      = Kind{"invalid_data", http.StatusBadRequest, "Invalid data"}
+	ValidationFailed = Kind{"validation_failed", http.StatusBadRequest, "Validation failed"}
+	Unauthorized     = Kind{"unauthorized", http.StatusUnauthorized, "Authentication required"}
+	Forbidden        = Kind{"forbidden", http.StatusForbidden, "Forbidden"}
+	NotFound         = Kind{"not_found", http.StatusNotFound, "Not found"}
+	MethodNotAllowed = Kind{"method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed"}
+	NotAcceptable    = Kind{"not_acceptable", http.StatusNotAcceptable, "Not acceptable"}
+	Conflict         = Kind{"conflict", http.StatusConflict, "Conflict"}
+	KeyInUse         = Kind{"idempotency_key_in_use", http.StatusConflict, "Idempotency key in use"}
+	KeyReused        = Kind{"idempotency_key_reused", http.StatusUnprocessableEntity, "Idempotency key reused"}
+	PayloadTooLarge  = Kind{"payload_too_large", http.StatusRequestEntityTooLarge, "Payload too large"}
+	UnsupportedMedia = Kind{"unsupported_media_type", http.StatusUnsupportedMediaType, "Unsupported media type"}
+	UpgradeRequired  = Kind{"upgrade_required", http.StatusUpgradeRequired, "Upgrade required"}
+	Internal         = Kind{"internal_error", http.StatusInternalServerError, "Internal server error"}
+)
+
+// Kinds lists every problem type, for documentation.
+var Kinds = []Kind{
+	BadRequest, InvalidData, ValidationFailed, Unauthorized, Forbidden, NotFound,
+	MethodNotAllowed, NotAcceptable, Conflict, KeyInUse, KeyReused, PayloadTooLarge,
+	UnsupportedMedia, UpgradeRequired, Internal,
+}
+
+// sentinels maps domain errors to their kinds, checked in order with errors.Is.
+var sentinels = []struct {
+	err  error
+	kind Kind
+}{
+	{ErrBadRequest, BadRequest},
+	{ErrNotAcceptable, NotAcceptable},
+	{domain.ErrInvalidData, InvalidData},
+	{domain.ErrUnauthorized, Unauthorized},
+	{domain.ErrForbidden, Forbidden},
+	{domain.ErrNotFound, NotFound},
+	{domain.ErrConflict, Conflict},
+	{domain.ErrIdempotencyKeyInUse, KeyInUse},
+	{domain.ErrIdempotencyKeyReused, KeyReused},
+	{domain.ErrTooLarge, PayloadTooLarge},
+	{domain.ErrUnsupportedMedia, UnsupportedMedia},
+}
+
+// specific holds the kinds that only domain errors are classified as; a
+// *fiber.Error with one of their statuses gets a generic kind instead.
+var specific = map[Kind]bool{InvalidData: true, ValidationFailed: true, KeyInUse: true, KeyReused: true}
+
+// Lookup returns the kind with the given code.
+func Lookup(code string) (Kind, bool) {
+	for _, k := range Kinds {
+		if k.Code == code {
+			return k, true
+		}
+	}
+	return Kind{}, false
+}
+
+// KindOf classifies err. A *domain.ValidationError is ValidationFailed, a
+// *fiber.Error is classified by its status, and anything unrecognised is
+// Internal.
+func KindOf(err error) Kind {
+	var invalid *domain.ValidationError
+	if errors.As(err, &invalid) {
+		return ValidationFailed
+	}
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return s.kind
+		}
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		for _, k := range Kinds {
+			if k.Status == fe.Code && !specific[k] {
+				return k
+			}
+		}
+		return Kind{Code: statusCode(fe.Code), Status: fe.Code, Title: http.StatusText(fe.Code)}
+	}
+	return Internal
+}
+
+// Write responds with the problem for err. detail explains this occurrence to
+// a human; when empty, the error's own message is used. The message of an
+// internal error is logged with the request ID instead of being sent.
+func Write(c *fiber.Ctx, err error, detail string) error {
+	kind := KindOf(err)
+	p := Details{
+		Type:      kind.Type(),
+		Title:     kind.Title,
+		Status:    kind.Status,
+		Detail:    detail,
+		Instance:  c.Path(),
+		Code:      kind.Code,
+		RequestID: c.GetRespHeader(fiber.HeaderXRequestID),
+	}
+
+	var invalid *domain.ValidationError
+	var fe *fiber.Error
+	switch {
+	case kind == Internal:
+		log.Printf("request %s: %s %s: %v", p.RequestID, c.Method(), c.Path(), err)
+		p.Detail = "an unexpected error occurred"
+	case errors.As(err, &invalid):
+		p.Errors = invalid.Fields
+		if detail == "" {
+			p.Detail = "one or more fields are invalid"
+		}
+	case detail == "" && errors.As(err, &fe):
+		p.Detail = fe.Message
+	case detail == "":
+		p.Detail = err.Error()
+	}
+	return c.Status(p.Status).JSON(p, ContentType)
+}
+
+// ErrorHandler is a fiber.ErrorHandler that writes every error as a problem.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	return Write(c, err, "")
+}
+
+// statusCode derives a code from the status text, e.g. 429 "too_many_requests".
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "http_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
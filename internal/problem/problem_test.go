@@ -0,0 +1,102 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/problem"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// TestKindOf verifies sentinels are recognised through wrapping and that
+// unknown errors are internal.
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("load book: %w", domain.ErrNotFound), "not_found"},
+		{fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", domain.ErrConflict)), "conflict"},
+		{&domain.ValidationError{Fields: []domain.FieldError{{Field: "title"}}}, "validation_failed"},
+		{fmt.Errorf("create: %w", &domain.ValidationError{}), "validation_failed"},
+		{domain.ErrInvalidData, "invalid_data"},
+		{problem.ErrBadRequest, "bad_request"},
+		{fiber.ErrMethodNotAllowed, "method_not_allowed"},
+		{fiber.ErrRequestEntityTooLarge, "payload_too_large"},
+		{fiber.NewError(fiber.StatusTooManyRequests), "too_many_requests"},
+		{errors.New("disk on fire"), "internal_error"},
+	}
+	for _, tc := range cases {
+		if got := problem.KindOf(tc.err).Code; got != tc.want {
+			t.Errorf("KindOf(%v): got %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func serve(t *testing.T, err error, detail string) (problem.Details, *httptest.ResponseRecorder) {
+	t.Helper()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(requestid.New())
+	app.Get("/write", func(c *fiber.Ctx) error { return problem.Write(c, err, detail) })
+	app.Get("/return", func(c *fiber.Ctx) error { return err })
+
+	path := "/write"
+	if detail == "" {
+		path = "/return"
+	}
+	resp, testErr := app.Test(httptest.NewRequest("GET", path, nil))
+	if testErr != nil {
+		t.Fatalf("app.Test: %v", testErr)
+	}
+	defer resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	rec.Code = resp.StatusCode
+	for k, v := range resp.Header {
+		rec.Header()[k] = v
+	}
+	var p problem.Details
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return p, rec
+}
+
+// TestWrite verifies the response body, content type and request ID.
+func TestWrite(t *testing.T) {
+	invalid := &domain.ValidationError{Fields: []domain.FieldError{{Field: "year", Code: domain.CodeOutOfRange, Message: "year is out of range"}}}
+	p, rec := serve(t, fmt.Errorf("update: %w", invalid), "book rejected")
+
+	if rec.Code != 400 || rec.Header().Get(fiber.HeaderContentType) != problem.ContentType {
+		t.Errorf("status %d, content type %q", rec.Code, rec.Header().Get(fiber.HeaderContentType))
+	}
+	if p.Type != "/problems/validation_failed" || p.Code != "validation_failed" || p.Status != 400 || p.Detail != "book rejected" || p.Instance != "/write" {
+		t.Errorf("problem: %+v", p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "year" {
+		t.Errorf("errors: %+v", p.Errors)
+	}
+	if p.RequestID == "" || p.RequestID != rec.Header().Get(fiber.HeaderXRequestID) {
+		t.Errorf("request_id %q, header %q", p.RequestID, rec.Header().Get(fiber.HeaderXRequestID))
+	}
+}
+
+// TestErrorHandlerHidesInternalErrors verifies unexpected errors do not leak
+// their message, while fiber errors keep theirs.
+func TestErrorHandlerHidesInternalErrors(t *testing.T) {
+	p, rec := serve(t, errors.New("pq: password authentication failed"), "")
+	if rec.Code != 500 || p.Code != "internal_error" || strings.Contains(p.Detail, "password") {
+		t.Errorf("internal error: status %d, %+v", rec.Code, p)
+	}
+
+	p, rec = serve(t, fiber.NewError(fiber.StatusMethodNotAllowed, "only GET"), "")
+	if rec.Code != 405 || p.Code != "method_not_allowed" || p.Detail != "only GET" {
+		t.Errorf("fiber error: status %d, %+v", rec.Code, p)
+	}
+}
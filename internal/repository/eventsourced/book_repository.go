@@ -0,0 +1,483 @@
+package eventsourced
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/google/uuid"
+)
+
+// DefaultSnapshotEvery is the snapshot interval used when none is given.
+const DefaultSnapshotEvery = 1000
+
+// BookRepository implements domain.BookRepository and domain.BookOutbox.
+// Every write is validated against the projection, appended to the Store and
+// only then applied to the projection, so the stream is always the source of
+// truth. Reads are served by the projection, a memory.BookRepository, and do
+// not touch the Store.
+//
+// A write's outbox events are part of its record, so they are as durable as
+// the write. The Store also keeps the version up to which events have been
+// dispatched; on startup, the events of later records are pending again, and
+// an event interrupted between publishing and MarkDispatched is published
+// again with the same ID.
+type BookRepository struct {
+	// mu serialises writes so records are appended and applied in the same
+	// order. Reads go straight to the projection.
+	mu            sync.Mutex
+	store         Store
+	snapshotEvery int
+	version       uint64
+
+	projection *memory.BookRepository
+	// trashed tracks every book in the projection and whether it is in the
+	// trash, which GetByID cannot tell apart from absence.
+	trashed map[string]bool
+
+	// outboxMu guards the outbox, which the relay drains concurrently with
+	// writes. Entries are in version order.
+	outboxMu sync.Mutex
+	outbox   []OutboxEntry
+	// lastEventVersion is the latest version that had events, and dispatched
+	// the version last saved with Store.SaveDispatched.
+	lastEventVersion uint64
+	dispatched       uint64
+	notify           chan struct{}
+}
+
+// NewBookRepository loads the latest snapshot from store, replays the records
+// after it and returns the repository. A snapshot is taken after every
+// snapshotEvery records; a non-positive value uses DefaultSnapshotEvery.
+func NewBookRepository(store Store, snapshotEvery int) (*BookRepository, error) {
+	r := newBookRepository(store, snapshotEvery)
+
+	snapshot, err := store.LoadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		for _, book := range snapshot.Books {
+			r.projection.Create(book)
+			r.trashed[book.ID] = book.DeletedAt != nil
+		}
+		r.version = snapshot.Version
+		r.outbox = append(r.outbox, snapshot.Outbox...)
+		if n := len(r.outbox); n > 0 {
+			r.lastEventVersion = r.outbox[n-1].Version
+		}
+	}
+	if err := r.replay(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Replay builds the repository from the first record of the stream, ignoring
+// any snapshot.
+func Replay(store Store, snapshotEvery int) (*BookRepository, error) {
+	r := newBookRepository(store, snapshotEvery)
+	if err := r.replay(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Rebuild replays the whole stream and replaces the stored snapshot with one
+// taken at its head, discarding a snapshot that may be stale or corrupt.
+func Rebuild(store Store, snapshotEvery int) (*BookRepository, error) {
+	r, err := Replay(store, snapshotEvery)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Snapshot(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newBookRepository(store Store, snapshotEvery int) *BookRepository {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	return &BookRepository{
+		store:         store,
+		snapshotEvery: snapshotEvery,
+		projection:    memory.NewBookRepository(),
+		trashed:       make(map[string]bool),
+		notify:        make(chan struct{}, 1),
+	}
+}
+
+// replay applies every stored record after the current version and then
+// drops the outbox events that were dispatched before the restart.
+func (r *BookRepository) replay() error {
+	err := r.store.Load(r.version, func(rec Record) error {
+		if rec.Version != r.version+1 {
+			return fmt.Errorf("event stream: expected version %d, found %d", r.version+1, rec.Version)
+		}
+		if err := r.apply(rec); err != nil {
+			return fmt.Errorf("event stream: version %d: %w", rec.Version, err)
+		}
+		r.version = rec.Version
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.dispatched, err = r.store.LoadDispatched(); err != nil {
+		return err
+	}
+	pending := r.outbox[:0]
+	for _, entry := range r.outbox {
+		if entry.Version > r.dispatched {
+			pending = append(pending, entry)
+		}
+	}
+	r.outbox = pending
+	return nil
+}
+
+// Version returns the version of the last applied record.
+func (r *BookRepository) Version() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+// Snapshot saves the current projection to the store.
+func (r *BookRepository) Snapshot() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot()
+}
+
+// snapshot saves the projection. Callers must hold r.mu.
+func (r *BookRepository) snapshot() error {
+	books, _, err := r.projection.GetAll(domain.BookFilter{Trash: domain.IncludeTrashed})
+	if err != nil {
+		return err
+	}
+	r.outboxMu.Lock()
+	outbox := append([]OutboxEntry(nil), r.outbox...)
+	r.outboxMu.Unlock()
+	return r.store.SaveSnapshot(&Snapshot{Version: r.version, Books: books, Outbox: outbox, TakenAt: time.Now().UTC()})
+}
+
+// Create records a BookCreated event. Returns domain.ErrConflict if the ID is
+// already in use.
+func (r *BookRepository) Create(book *domain.Book, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.trashed[book.ID]; exists {
+		return domain.ErrConflict
+	}
+	return r.commit(events, Record{Type: BookCreated, BookID: book.ID, Book: book})
+}
+
+// GetByID returns a single live book from the projection.
+func (r *BookRepository) GetByID(id string) (*domain.Book, error) {
+	return r.projection.GetByID(id)
+}
+
+// GetAll queries the projection.
+func (r *BookRepository) GetAll(filter domain.BookFilter) ([]*domain.Book, int, error) {
+	return r.projection.GetAll(filter)
+}
+
+// GetAllFaceted queries the projection.
+func (r *BookRepository) GetAllFaceted(filter domain.BookFilter) ([]*domain.Book, int, domain.BookFacets, error) {
+	return r.projection.GetAllFaceted(filter)
+}
+
+// Stats aggregates the projection.
+func (r *BookRepository) Stats(query domain.BookStatsQuery) ([]domain.BookGroup, error) {
+	return r.projection.Stats(query)
+}
+
+// Update records a BookUpdated event. Returns domain.ErrNotFound if the book
+// is absent or trashed. The recorded book keeps the stored rating, which only
+// UpdateRating changes.
+func (r *BookRepository) Update(book *domain.Book, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.projection.GetByID(book.ID)
+	if err != nil {
+		return err
+	}
+	if book.DeletedAt != nil {
+		return domain.ErrInvalidData
+	}
+	updated := *book
+	updated.AverageRating = stored.AverageRating
+	updated.ReviewCount = stored.ReviewCount
+	return r.commit(events, Record{Type: BookUpdated, BookID: book.ID, Book: &updated})
+}
+
+// UpdateRating records a BookRated event. Returns domain.ErrNotFound if the
+// book is absent.
+func (r *BookRepository) UpdateRating(id string, rating domain.RatingSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.trashed[id]; !ok {
+		return domain.ErrNotFound
+	}
+	return r.commit(nil, Record{Type: BookRated, BookID: id, Rating: &rating})
+}
+
+// SoftDelete records a BookDeleted event. Returns domain.ErrNotFound if the
+// book is absent or already trashed.
+func (r *BookRepository) SoftDelete(id, deletedBy string, at time.Time, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	book, err := r.projection.GetByID(id)
+	if err != nil {
+		return err
+	}
+	trashed := *book
+	trashed.DeletedAt = &at
+	trashed.DeletedBy = deletedBy
+	return r.commit(events, Record{Type: BookDeleted, BookID: book.ID, Book: &trashed})
+}
+
+// Restore records a BookRestored event. Returns domain.ErrNotFound if the
+// book is absent or not trashed.
+func (r *BookRepository) Restore(id string, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.trashed[id] {
+		return domain.ErrNotFound
+	}
+	return r.commit(events, Record{Type: BookRestored, BookID: id})
+}
+
+// PurgeTrashed records a BookPurged event for every book trashed before the
+// cutoff, appending them to the store in one batch.
+func (r *BookRepository) PurgeTrashed(before time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trashed, _, err := r.projection.GetAll(domain.BookFilter{Trash: domain.OnlyTrashed})
+	if err != nil {
+		return nil, err
+	}
+	var purged []string
+	var records []Record
+	for _, book := range trashed {
+		if book.DeletedAt.Before(before) {
+			purged = append(purged, book.ID)
+			records = append(records, Record{Type: BookPurged, BookID: book.ID})
+		}
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if err := r.commit(nil, records...); err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// Delete records a BookPurged event. Returns domain.ErrNotFound if absent.
+func (r *BookRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.trashed[id]; !ok {
+		return domain.ErrNotFound
+	}
+	return r.commit(nil, Record{Type: BookPurged, BookID: id})
+}
+
+// Pending returns up to limit undispatched outbox events in commit order.
+func (r *BookRepository) Pending(limit int) ([]domain.BookEvent, error) {
+	r.outboxMu.Lock()
+	defer r.outboxMu.Unlock()
+
+	n := len(r.outbox)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	pending := make([]domain.BookEvent, n)
+	for i, entry := range r.outbox[:n] {
+		pending[i] = entry.Event
+	}
+	return pending, nil
+}
+
+// MarkDispatched drops the given events from the outbox and saves the version
+// up to which every event is now dispatched. Unknown IDs are ignored. If the
+// version cannot be saved, the events are dispatched again after a restart.
+func (r *BookRepository) MarkDispatched(ids ...string) error {
+	r.outboxMu.Lock()
+	defer r.outboxMu.Unlock()
+
+	done := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		done[id] = true
+	}
+	kept := r.outbox[:0]
+	for _, entry := range r.outbox {
+		if !done[entry.Event.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	clear(r.outbox[len(kept):]) // release the dropped books
+	r.outbox = kept
+
+	dispatched := r.lastEventVersion
+	if len(r.outbox) > 0 {
+		dispatched = r.outbox[0].Version - 1
+	}
+	if dispatched <= r.dispatched {
+		return nil
+	}
+	if err := r.store.SaveDispatched(dispatched); err != nil {
+		return err
+	}
+	r.dispatched = dispatched
+	return nil
+}
+
+// Notify receives a value after outbox events are recorded.
+func (r *BookRepository) Notify() <-chan struct{} {
+	return r.notify
+}
+
+// commit numbers and appends records, applies them and takes a snapshot when
+// one is due. Outbox events get their IDs here and are stored with the first
+// record. Callers must hold r.mu and have validated the records against the
+// projection.
+func (r *BookRepository) commit(events []domain.BookEvent, records ...Record) error {
+	now := time.Now().UTC()
+	for i := range records {
+		records[i].Version = r.version + uint64(i) + 1
+		records[i].At = now
+		if records[i].Book != nil {
+			b := *records[i].Book
+			records[i].Book = &b
+		}
+	}
+	if len(events) > 0 {
+		records[0].Events = make([]domain.BookEvent, len(events))
+		for i, e := range events {
+			if e.ID == "" {
+				e.ID = uuid.New().String()
+			}
+			records[0].Events[i] = e
+		}
+	}
+	if err := r.store.Append(records...); err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if err := r.apply(rec); err != nil {
+			// The record is durable but the projection rejected it; the
+			// two only agree again after a restart or Rebuild.
+			return fmt.Errorf("apply version %d: %w", rec.Version, err)
+		}
+		r.version = rec.Version
+		if r.version%uint64(r.snapshotEvery) == 0 {
+			if err := r.snapshot(); err != nil {
+				log.Printf("event store: snapshot at version %d: %v", r.version, err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply updates the projection with one record and moves the record's
+// events, which the projection completes with the book's state, into the
+// outbox.
+func (r *BookRepository) apply(rec Record) error {
+	if err := r.applyBook(rec, rec.Events...); err != nil {
+		return err
+	}
+	if len(rec.Events) == 0 {
+		return nil
+	}
+
+	completed, _ := r.projection.Pending(0)
+	ids := make([]string, len(completed))
+	r.outboxMu.Lock()
+	for i, e := range completed {
+		ids[i] = e.ID
+		r.outbox = append(r.outbox, OutboxEntry{Version: rec.Version, Event: e})
+	}
+	r.lastEventVersion = rec.Version
+	r.outboxMu.Unlock()
+	r.projection.MarkDispatched(ids...)
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// applyBook updates the projection with one record.
+func (r *BookRepository) applyBook(rec Record, events ...domain.BookEvent) error {
+	var book *domain.Book
+	if rec.Book != nil {
+		b := *rec.Book
+		book = &b
+	}
+
+	switch rec.Type {
+	case BookCreated:
+		if book == nil {
+			return fmt.Errorf("%s without book", rec.Type)
+		}
+		if err := r.projection.Create(book, events...); err != nil {
+			return err
+		}
+		r.trashed[rec.BookID] = book.DeletedAt != nil
+	case BookUpdated:
+		if book == nil {
+			return fmt.Errorf("%s without book", rec.Type)
+		}
+		// Update keeps the stored rating, so set the recorded one first; in
+		// streams written before BookRated, updates carried rating changes.
+		rating := domain.RatingSummary{Average: book.AverageRating, Count: book.ReviewCount}
+		if err := r.projection.UpdateRating(rec.BookID, rating); err != nil {
+			return err
+		}
+		return r.projection.Update(book, events...)
+	case BookRated:
+		if rec.Rating == nil {
+			return fmt.Errorf("%s without rating", rec.Type)
+		}
+		return r.projection.UpdateRating(rec.BookID, *rec.Rating)
+	case BookDeleted:
+		if book == nil || book.DeletedAt == nil {
+			return fmt.Errorf("%s without trashed book", rec.Type)
+		}
+		if err := r.projection.SoftDelete(rec.BookID, book.DeletedBy, *book.DeletedAt, events...); err != nil {
+			return err
+		}
+		r.trashed[rec.BookID] = true
+	case BookRestored:
+		if err := r.projection.Restore(rec.BookID, events...); err != nil {
+			return err
+		}
+		r.trashed[rec.BookID] = false
+	case BookPurged:
+		if err := r.projection.Delete(rec.BookID); err != nil {
+			return err
+		}
+		delete(r.trashed, rec.BookID)
+	default:
+		return fmt.Errorf("unknown record type %q", rec.Type)
+	}
+	return nil
+}
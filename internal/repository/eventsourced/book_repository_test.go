@@ -0,0 +1,311 @@
+package eventsourced_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/eventsourced"
+)
+
+func newBook(i int) *domain.Book {
+	return &domain.Book{
+		ID:        fmt.Sprintf("book-%d", i),
+		Title:     fmt.Sprintf("Title %d", i),
+		Author:    fmt.Sprintf("Author %d", i),
+		Year:      2000 + i,
+		CreatedAt: time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// state renders every book, trashed ones included, as JSON for comparison.
+func state(t *testing.T, repo domain.BookRepository) string {
+	t.Helper()
+	books, _, err := repo.GetAll(domain.BookFilter{Trash: domain.IncludeTrashed})
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	raw, _ := json.Marshal(books)
+	return string(raw)
+}
+
+// writeHistory performs 8 writes covering every record type.
+func writeHistory(t *testing.T, repo *eventsourced.BookRepository) {
+	t.Helper()
+	at := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	steps := []error{
+		repo.Create(newBook(1)),
+		repo.Create(newBook(2)),
+		repo.Create(newBook(3)),
+		repo.Update(&domain.Book{ID: "book-2", Title: "Renamed", Author: "Author 2", CreatedAt: newBook(2).CreatedAt}),
+		repo.SoftDelete("book-1", "alice", at),
+		repo.SoftDelete("book-3", "bob", at.Add(time.Hour)),
+		repo.Restore("book-3"),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("write %d: %v", i+1, err)
+		}
+	}
+	if purged, err := repo.PurgeTrashed(at.Add(time.Minute)); err != nil || len(purged) != 1 || purged[0] != "book-1" {
+		t.Fatalf("PurgeTrashed: got %v, %v; want [book-1]", purged, err)
+	}
+}
+
+// TestReloadFromSnapshotAndStream verifies that reopening a file store
+// reproduces the projection from the latest snapshot plus the records after
+// it, and that a full replay agrees.
+func TestReloadFromSnapshotAndStream(t *testing.T) {
+	dir := t.TempDir()
+	store, err := eventsourced.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	repo, err := eventsourced.NewBookRepository(store, 3)
+	if err != nil {
+		t.Fatalf("NewBookRepository: %v", err)
+	}
+	writeHistory(t, repo)
+	want := state(t, repo)
+	store.Close()
+
+	store, _ = eventsourced.NewFileStore(dir)
+	defer store.Close()
+	snapshot, err := store.LoadSnapshot()
+	if err != nil || snapshot == nil || snapshot.Version != 6 {
+		t.Fatalf("LoadSnapshot: got %+v, %v; want version 6", snapshot, err)
+	}
+
+	reopened, err := eventsourced.NewBookRepository(store, 3)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Version() != 8 {
+		t.Errorf("Version: got %d, want 8", reopened.Version())
+	}
+	if got := state(t, reopened); got != want {
+		t.Errorf("reopened state:\n got %s\nwant %s", got, want)
+	}
+	replayed, err := eventsourced.Replay(store, 3)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if got := state(t, replayed); got != want {
+		t.Errorf("replayed state:\n got %s\nwant %s", got, want)
+	}
+
+	if b, err := reopened.GetByID("book-2"); err != nil || b.Title != "Renamed" {
+		t.Errorf("GetByID(book-2): got %+v, %v", b, err)
+	}
+	if _, err := reopened.GetByID("book-1"); err != domain.ErrNotFound {
+		t.Errorf("GetByID(purged): got %v, want ErrNotFound", err)
+	}
+}
+
+// TestRebuildReplacesStaleSnapshot verifies Rebuild recovers from a snapshot
+// that disagrees with the stream.
+func TestRebuildReplacesStaleSnapshot(t *testing.T) {
+	store := eventsourced.NewMemoryStore()
+	repo, _ := eventsourced.NewBookRepository(store, 100)
+	writeHistory(t, repo)
+	want := state(t, repo)
+
+	store.SaveSnapshot(&eventsourced.Snapshot{Version: repo.Version()})
+	stale, _ := eventsourced.NewBookRepository(store, 100)
+	if state(t, stale) == want {
+		t.Fatal("stale snapshot was not used")
+	}
+
+	rebuilt, err := eventsourced.Rebuild(store, 100)
+	if err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if got := state(t, rebuilt); got != want {
+		t.Errorf("rebuilt state:\n got %s\nwant %s", got, want)
+	}
+	reloaded, _ := eventsourced.NewBookRepository(store, 100)
+	if got := state(t, reloaded); got != want {
+		t.Errorf("state after rebuild:\n got %s\nwant %s", got, want)
+	}
+}
+
+// TestRejectedWritesAppendNothing verifies invalid writes leave the stream
+// and the outbox untouched, while accepted ones record their outbox events.
+func TestRejectedWritesAppendNothing(t *testing.T) {
+	repo, _ := eventsourced.NewBookRepository(eventsourced.NewMemoryStore(), 0)
+	repo.Create(newBook(1), domain.BookEvent{Type: domain.EventBookCreated})
+
+	rejected := map[string]error{
+		"Create duplicate": repo.Create(newBook(1), domain.BookEvent{Type: domain.EventBookCreated}),
+		"Update missing":   repo.Update(newBook(2), domain.BookEvent{Type: domain.EventBookUpdated}),
+		"Restore live":     repo.Restore("book-1", domain.BookEvent{Type: domain.EventBookRestored}),
+		"Delete missing":   repo.Delete("book-2"),
+	}
+	for name, err := range rejected {
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if repo.Version() != 1 {
+		t.Errorf("Version: got %d, want 1", repo.Version())
+	}
+	if pending, _ := repo.Pending(0); len(pending) != 1 || pending[0].Type != domain.EventBookCreated || pending[0].Book == nil {
+		t.Errorf("outbox: got %+v, want the single created event", pending)
+	}
+}
+
+// TestRatingSurvivesUpdatesAndReplay verifies that Update keeps the recorded
+// rating, that trashed books can be rated, and that a replay agrees.
+func TestRatingSurvivesUpdatesAndReplay(t *testing.T) {
+	store := eventsourced.NewMemoryStore()
+	repo, _ := eventsourced.NewBookRepository(store, 0)
+	repo.Create(newBook(1))
+	if err := repo.UpdateRating("book-1", domain.RatingSummary{Average: 4.5, Count: 2}); err != nil {
+		t.Fatalf("UpdateRating: %v", err)
+	}
+	stale := newBook(1) // fetched before the rating, as by a concurrent edit
+	stale.Title = "Renamed"
+	if err := repo.Update(stale); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if b, _ := repo.GetByID("book-1"); b.Title != "Renamed" || b.AverageRating != 4.5 || b.ReviewCount != 2 {
+		t.Fatalf("after Update: got %+v", b)
+	}
+	repo.SoftDelete("book-1", "alice", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err := repo.UpdateRating("book-1", domain.RatingSummary{Average: 4, Count: 1}); err != nil {
+		t.Fatalf("UpdateRating of trashed book: %v", err)
+	}
+	if err := repo.UpdateRating("book-2", domain.RatingSummary{}); err != domain.ErrNotFound {
+		t.Errorf("UpdateRating missing: got %v, want ErrNotFound", err)
+	}
+
+	want := state(t, repo)
+	replayed, err := eventsourced.Replay(store, 0)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if got := state(t, replayed); got != want {
+		t.Errorf("replayed state:\n got %s\nwant %s", got, want)
+	}
+}
+
+// pendingIDs returns the IDs of every undispatched outbox event.
+func pendingIDs(t *testing.T, repo *eventsourced.BookRepository) []string {
+	t.Helper()
+	pending, err := repo.Pending(0)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	ids := make([]string, len(pending))
+	for i, e := range pending {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// TestOutboxSurvivesReopen verifies that undispatched events come back with
+// the same IDs after a restart, including events older than the snapshot,
+// and that dispatched ones do not.
+func TestOutboxSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := eventsourced.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	repo, _ := eventsourced.NewBookRepository(store, 2)
+	for i := 1; i <= 3; i++ {
+		repo.Create(newBook(i), domain.BookEvent{Type: domain.EventBookCreated})
+	}
+	ids := pendingIDs(t, repo)
+	if len(ids) != 3 {
+		t.Fatalf("pending: got %d events, want 3", len(ids))
+	}
+	// The snapshot at version 2 is ahead of the dispatched version.
+	if err := repo.MarkDispatched(ids[1]); err != nil {
+		t.Fatalf("MarkDispatched: %v", err)
+	}
+	store.Close()
+
+	store, _ = eventsourced.NewFileStore(dir)
+	reopened, err := eventsourced.NewBookRepository(store, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	// Event 2 is pending again: only versions before the oldest pending
+	// event are known to be dispatched.
+	if got := fmt.Sprint(pendingIDs(t, reopened)); got != fmt.Sprint(ids) {
+		t.Errorf("pending after reopen: got %s, want %s", got, fmt.Sprint(ids))
+	}
+	if pending, _ := reopened.Pending(1); pending[0].Book == nil || pending[0].Book.ID != "book-1" {
+		t.Errorf("first pending event: got %+v, want book-1's state", pending[0])
+	}
+
+	if err := reopened.MarkDispatched(ids[0], ids[1]); err != nil {
+		t.Fatalf("MarkDispatched: %v", err)
+	}
+	reopened.Create(newBook(4), domain.BookEvent{Type: domain.EventBookCreated})
+	store.Close()
+
+	store, _ = eventsourced.NewFileStore(dir)
+	defer store.Close()
+	reopened, _ = eventsourced.NewBookRepository(store, 2)
+	pending := pendingIDs(t, reopened)
+	if len(pending) != 2 || pending[0] != ids[2] {
+		t.Errorf("pending after second reopen: got %v, want %s and book-4's event", pending, ids[2])
+	}
+	replayed, _ := eventsourced.Replay(store, 2)
+	if got := fmt.Sprint(pendingIDs(t, replayed)); got != fmt.Sprint(pending) {
+		t.Errorf("pending after replay: got %s, want %s", got, fmt.Sprint(pending))
+	}
+}
+
+// TestTornFinalRecordIsDropped verifies that a record cut off by a crash
+// mid-append is discarded on open instead of failing every later start.
+func TestTornFinalRecordIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := eventsourced.NewFileStore(dir)
+	repo, _ := eventsourced.NewBookRepository(store, 0)
+	repo.Create(newBook(1))
+	repo.Create(newBook(2))
+	store.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, "events.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	f.WriteString(`{"version":3,"type":"book.created","book_id":"bo`)
+	f.Close()
+
+	store, err = eventsourced.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	repo, err = eventsourced.NewBookRepository(store, 0)
+	if err != nil {
+		t.Fatalf("NewBookRepository: %v", err)
+	}
+	if repo.Version() != 2 {
+		t.Errorf("Version: got %d, want 2", repo.Version())
+	}
+	if err := repo.Create(newBook(3)); err != nil {
+		t.Fatalf("Create after truncation: %v", err)
+	}
+	store.Close()
+
+	store, _ = eventsourced.NewFileStore(dir)
+	defer store.Close()
+	reopened, err := eventsourced.NewBookRepository(store, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Version() != 3 {
+		t.Errorf("Version after reopen: got %d, want 3", reopened.Version())
+	}
+	if _, err := reopened.GetByID("book-3"); err != nil {
+		t.Errorf("GetByID(book-3): %v", err)
+	}
+}
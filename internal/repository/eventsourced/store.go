@@ -0,0 +1,376 @@
+// Package eventsourced provides a domain.BookRepository whose source of truth
+// is an append-only stream of book events. The current state is an in-memory
+// projection rebuilt from the latest snapshot plus the events after it.
+package eventsourced
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// Record types, one per repository write. BookCreated, BookUpdated and
+// BookDeleted carry the book as stored after the write; BookRated carries the
+// new rating; BookRestored and BookPurged only need the ID.
+const (
+	BookCreated  = "BookCreated"
+	BookUpdated  = "BookUpdated"
+	BookRated    = "BookRated"   // rating recomputed from the reviews
+	BookDeleted  = "BookDeleted" // moved to the trash
+	BookRestored = "BookRestored"
+	BookPurged   = "BookPurged" // permanently removed
+)
+
+// Record is one entry of the event stream. Versions start at 1 and increase
+// by one per record. Events are the outbox events of the write, with their
+// IDs but without the book state, which replaying the record fills in.
+type Record struct {
+	Version uint64                `json:"version"`
+	Type    string                `json:"type"`
+	BookID  string                `json:"book_id"`
+	Book    *domain.Book          `json:"book,omitempty"`
+	Rating  *domain.RatingSummary `json:"rating,omitempty"`
+	Events  []domain.BookEvent    `json:"events,omitempty"`
+	At      time.Time             `json:"at"`
+}
+
+// Snapshot is the projection after the record with the given Version. Books
+// are in insertion order and include trashed books. Outbox holds the events
+// not yet dispatched when the snapshot was taken, since the records they came
+// from are not replayed on top of it.
+type Snapshot struct {
+	Version uint64         `json:"version"`
+	Books   []*domain.Book `json:"books"`
+	Outbox  []OutboxEntry  `json:"outbox,omitempty"`
+	TakenAt time.Time      `json:"taken_at"`
+}
+
+// OutboxEntry is an outbox event and the version of the record it came from.
+type OutboxEntry struct {
+	Version uint64           `json:"version"`
+	Event   domain.BookEvent `json:"event"`
+}
+
+// Store persists the event stream and the latest snapshot.
+type Store interface {
+	// Append adds records to the end of the stream.
+	Append(records ...Record) error
+	// Load calls fn for every record with Version > afterVersion, in order.
+	Load(afterVersion uint64, fn func(Record) error) error
+	// SaveSnapshot replaces the stored snapshot.
+	SaveSnapshot(snapshot *Snapshot) error
+	// LoadSnapshot returns the stored snapshot, or nil if there is none.
+	LoadSnapshot() (*Snapshot, error)
+	// SaveDispatched records that the events of every record up to version
+	// have been dispatched.
+	SaveDispatched(version uint64) error
+	// LoadDispatched returns the version last passed to SaveDispatched, or 0.
+	LoadDispatched() (uint64, error)
+}
+
+// MemoryStore is a Store that lives only as long as the process.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	records    []Record
+	snapshot   []byte
+	dispatched uint64
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds records to the stream.
+func (s *MemoryStore) Append(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rec := range records {
+		if rec.Book != nil {
+			b := *rec.Book
+			rec.Book = &b
+		}
+		rec.Events = append([]domain.BookEvent(nil), rec.Events...)
+		s.records = append(s.records, rec)
+	}
+	return nil
+}
+
+// Load replays records after afterVersion.
+func (s *MemoryStore) Load(afterVersion uint64, fn func(Record) error) error {
+	s.mu.RLock()
+	records := append([]Record(nil), s.records...)
+	s.mu.RUnlock()
+
+	for _, rec := range records {
+		if rec.Version <= afterVersion {
+			continue
+		}
+		if rec.Book != nil {
+			b := *rec.Book
+			rec.Book = &b
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot stores an encoded copy of snapshot.
+func (s *MemoryStore) SaveSnapshot(snapshot *Snapshot) error {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = raw
+	return nil
+}
+
+// LoadSnapshot decodes the stored snapshot.
+func (s *MemoryStore) LoadSnapshot() (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.snapshot == nil {
+		return nil, nil
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(s.snapshot, &snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// SaveDispatched stores the dispatched version.
+func (s *MemoryStore) SaveDispatched(version uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatched = version
+	return nil
+}
+
+// LoadDispatched returns the dispatched version.
+func (s *MemoryStore) LoadDispatched() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dispatched, nil
+}
+
+// FileStore is a Store in a directory: events.jsonl holds one JSON record per
+// line and is fsynced after every append; snapshot.json and dispatched.json
+// are replaced atomically by writing a temporary file and renaming it.
+type FileStore struct {
+	mu   sync.Mutex
+	dir  string
+	file *os.File
+}
+
+const (
+	eventsFileName     = "events.jsonl"
+	snapshotFileName   = "snapshot.json"
+	dispatchedFileName = "dispatched.json"
+)
+
+// NewFileStore opens (or creates) the store in dir. A final line without a
+// newline is the remains of an append that crashed before it was synced, and
+// so was never reported as written; it is cut off.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create event store dir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, eventsFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open event stream: %w", err)
+	}
+	if err := truncateTornLine(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("repair event stream: %w", err)
+	}
+	return &FileStore{dir: dir, file: f}, nil
+}
+
+// truncateTornLine cuts f after its last newline.
+func truncateTornLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 64*1024)
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == info.Size() {
+		return nil
+	}
+	log.Printf("event store: dropping %d bytes of an incomplete final record", info.Size()-end)
+	if err := f.Truncate(end); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Append writes records as new lines and syncs the file.
+func (s *FileStore) Append(records ...Record) error {
+	var buf []byte
+	for _, rec := range records {
+		raw, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		buf = append(append(buf, raw...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(buf); err != nil {
+		return fmt.Errorf("write event stream: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync event stream: %w", err)
+	}
+	return nil
+}
+
+// Load reads the stream from the start and replays records after
+// afterVersion. A malformed line is reported with its line number.
+func (s *FileStore) Load(afterVersion uint64, fn func(Record) error) error {
+	f, err := os.Open(filepath.Join(s.dir, eventsFileName))
+	if err != nil {
+		return fmt.Errorf("open event stream: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("event stream line %d: %w", line, err)
+		}
+		if rec.Version <= afterVersion {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read event stream: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot atomically replaces snapshot.json.
+func (s *FileStore) SaveSnapshot(snapshot *Snapshot) error {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := s.replace(snapshotFileName, raw); err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads snapshot.json, returning nil if it does not exist.
+func (s *FileStore) LoadSnapshot() (*Snapshot, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// dispatchedFile is the layout of dispatched.json.
+type dispatchedFile struct {
+	Version uint64 `json:"version"`
+}
+
+// SaveDispatched atomically replaces dispatched.json.
+func (s *FileStore) SaveDispatched(version uint64) error {
+	raw, err := json.Marshal(dispatchedFile{Version: version})
+	if err != nil {
+		return fmt.Errorf("encode dispatched version: %w", err)
+	}
+	if err := s.replace(dispatchedFileName, raw); err != nil {
+		return fmt.Errorf("save dispatched version: %w", err)
+	}
+	return nil
+}
+
+// LoadDispatched reads dispatched.json, returning 0 if it does not exist.
+func (s *FileStore) LoadDispatched() (uint64, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, dispatchedFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read dispatched version: %w", err)
+	}
+	var file dispatchedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return 0, fmt.Errorf("decode dispatched version: %w", err)
+	}
+	return file.Version, nil
+}
+
+// replace atomically replaces the named file in the store directory.
+func (s *FileStore) replace(name string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+// Close closes the event stream file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,171 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// AuditRepository is a domain.AuditRepository backed by an append-only file
+// holding one JSON-encoded entry per line. Every Append is fsynced before it
+// returns. Existing entries are loaded into memory on open to serve queries.
+type AuditRepository struct {
+	mu      sync.RWMutex
+	file    *os.File
+	size    int64 // length of the file up to the last complete entry
+	entries []*domain.AuditEntry
+}
+
+// NewAuditRepository opens (or creates) the audit log at path. A final line
+// without a newline is the remains of an Append that crashed before it was
+// synced, and so was never reported as written; it is cut off before the
+// entries are read, leaving the hash chain ending at the last whole entry.
+func NewAuditRepository(path string) (*AuditRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	size, err := truncateTornLine(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("repair audit log: %w", err)
+	}
+	entries, err := ReadAuditLog(path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &AuditRepository{file: f, size: size, entries: entries}, nil
+}
+
+// truncateTornLine cuts f after its last newline and returns its new size.
+func truncateTornLine(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	end := info.Size()
+	buf := make([]byte, 64*1024)
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == info.Size() {
+		return end, nil
+	}
+	log.Printf("audit log: dropping %d bytes of an incomplete final entry", info.Size()-end)
+	if err := f.Truncate(end); err != nil {
+		return 0, err
+	}
+	return end, f.Sync()
+}
+
+// ReadAuditLog parses every entry of the audit log at path, in file order.
+// A line that is not a valid entry is reported with its line number.
+func ReadAuditLog(path string) ([]*domain.AuditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*domain.AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e domain.AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		entries = append(entries, &e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Append writes entry as a new line and syncs the file. If either fails, the
+// file is cut back to its last complete entry, so that the next entry does
+// not follow a partial line.
+func (r *AuditRepository) Append(entry *domain.AuditEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+	raw = append(raw, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(raw); err != nil {
+		return r.rollback(fmt.Errorf("write audit log: %w", err))
+	}
+	if err := r.file.Sync(); err != nil {
+		return r.rollback(fmt.Errorf("sync audit log: %w", err))
+	}
+	r.size += int64(len(raw))
+	stored := *entry
+	r.entries = append(r.entries, &stored)
+	return nil
+}
+
+// rollback cuts the file back to r.size after a failed Append and returns
+// err, joined with any failure to do so. Callers must hold r.mu.
+func (r *AuditRepository) rollback(err error) error {
+	if terr := r.file.Truncate(r.size); terr != nil {
+		return errors.Join(err, fmt.Errorf("truncate audit log: %w", terr))
+	}
+	return err
+}
+
+// Last returns the newest entry. Returns domain.ErrNotFound if the log is empty.
+func (r *AuditRepository) Last() (*domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	last := *r.entries[len(r.entries)-1]
+	return &last, nil
+}
+
+// All returns copies of every entry in Seq order.
+func (r *AuditRepository) All() ([]*domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*domain.AuditEntry, len(r.entries))
+	for i, e := range r.entries {
+		c := *e
+		out[i] = &c
+	}
+	return out, nil
+}
+
+// Close closes the underlying file.
+func (r *AuditRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,53 @@
+package filesystem_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// TestAuditRepositoryTornFinalLine verifies that an entry cut off by a crash
+// mid-append is dropped on open, and that the next entry continues a valid
+// hash chain instead of following the partial line.
+func TestAuditRepositoryTornFinalLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	repo, err := filesystem.NewAuditRepository(path)
+	if err != nil {
+		t.Fatalf("NewAuditRepository: %v", err)
+	}
+	uc := usecase.NewAuditUseCase(repo)
+	for _, action := range []string{"POST /v1/books", "DELETE /v1/books/:id"} {
+		if err := uc.Record(domain.AuditEntry{Subject: "admin", Action: action, Outcome: "success", Status: 200}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	repo.Close()
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"seq":3,"time":"2024-03-01T12:00:00Z","subj`)
+	f.Close()
+
+	repo, err = filesystem.NewAuditRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if last, err := repo.Last(); err != nil || last.Seq != 2 {
+		t.Fatalf("Last after reopen: got %+v, %v; want seq 2", last, err)
+	}
+	if err := usecase.NewAuditUseCase(repo).Record(domain.AuditEntry{Subject: "admin", Action: "PUT /v1/books/:id", Outcome: "success", Status: 200}); err != nil {
+		t.Fatalf("Record after reopen: %v", err)
+	}
+	repo.Close()
+
+	entries, err := filesystem.ReadAuditLog(path)
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	if v := usecase.VerifyAuditChain(entries); !v.Valid || v.Entries != 3 {
+		t.Errorf("chain: got %+v, want 3 valid entries", v)
+	}
+}
@@ -0,0 +1,108 @@
+// Package filesystem provides implementations of domain stores backed by the
+// local filesystem.
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// BlobStore is a content-addressed domain.BlobStore rooted at a directory.
+// A blob with key "abcdef…" lives at <root>/ab/cd/abcdef…, which keeps any one
+// directory small. Files are written to a temporary name and renamed into
+// place, so readers never observe a partially written blob.
+type BlobStore struct {
+	root string
+}
+
+// NewBlobStore creates the root directory if needed and returns a BlobStore.
+func NewBlobStore(root string) (*BlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create blob root: %w", err)
+	}
+	return &BlobStore{root: root}, nil
+}
+
+// Put stores data and returns its key. Storing existing content is a no-op.
+func (s *BlobStore) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	path := s.path(key)
+
+	if _, err := os.Stat(path); err == nil {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("create blob dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("create blob temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("close blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("commit blob: %w", err)
+	}
+	return key, nil
+}
+
+// Get returns the content stored under key. Returns domain.ErrNotFound if absent.
+func (s *BlobStore) Get(key string) ([]byte, error) {
+	if !validKey(key) {
+		return nil, domain.ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read blob: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the blob stored under key. Returns domain.ErrNotFound if absent.
+func (s *BlobStore) Delete(key string) error {
+	if !validKey(key) {
+		return domain.ErrNotFound
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+func (s *BlobStore) path(key string) string {
+	return filepath.Join(s.root, key[0:2], key[2:4], key)
+}
+
+// validKey reports whether key is a lowercase hex SHA-256 digest, which also
+// rules out path traversal through crafted keys.
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	for _, r := range key {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
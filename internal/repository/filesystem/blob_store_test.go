@@ -0,0 +1,60 @@
+package filesystem_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+)
+
+// TestBlobStoreContentAddressed verifies keys are the SHA-256 of the content
+// and that storing identical content twice yields the same key.
+func TestBlobStoreContentAddressed(t *testing.T) {
+	store, err := filesystem.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobStore: %v", err)
+	}
+
+	data := []byte("cover bytes")
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := store.Put(data)
+			if err != nil || key != want {
+				t.Errorf("Put: got (%q, %v), want (%q, nil)", key, err, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := store.Get(want)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get: got (%q, %v), want (%q, nil)", got, err, data)
+	}
+
+	if err := store.Delete(want); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(want); err != domain.ErrNotFound {
+		t.Errorf("Get after Delete: want ErrNotFound, got %v", err)
+	}
+}
+
+// TestBlobStoreRejectsInvalidKeys ensures crafted keys cannot escape the root.
+func TestBlobStoreRejectsInvalidKeys(t *testing.T) {
+	store, _ := filesystem.NewBlobStore(t.TempDir())
+	for _, key := range []string{"", "../../etc/passwd", "ABCDEF", string(make([]byte, 64))} {
+		if _, err := store.Get(key); err != domain.ErrNotFound {
+			t.Errorf("Get(%q): want ErrNotFound, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// CoverRepository is a domain.CoverRepository keeping each book's cover
+// metadata in its own JSON file under a directory, so that covers survive a
+// restart together with the blobs they point to. A file is named after the
+// SHA-256 of the book ID, which no crafted ID can turn into a path outside
+// the directory, and is replaced atomically by writing a temporary file and
+// renaming it.
+type CoverRepository struct {
+	mu  sync.Mutex // serialises writes so the last Save of a book wins
+	dir string
+}
+
+// NewCoverRepository creates dir if needed and returns a CoverRepository.
+func NewCoverRepository(dir string) (*CoverRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cover dir: %w", err)
+	}
+	return &CoverRepository{dir: dir}, nil
+}
+
+// Save stores or replaces the cover of cover.BookID.
+func (r *CoverRepository) Save(cover *domain.Cover) error {
+	raw, err := json.Marshal(cover)
+	if err != nil {
+		return fmt.Errorf("encode cover: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tmp, err := os.CreateTemp(r.dir, "cover.tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cover temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write cover: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync cover: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close cover: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path(cover.BookID)); err != nil {
+		return fmt.Errorf("commit cover: %w", err)
+	}
+	return nil
+}
+
+// Get returns a book's cover. Returns domain.ErrNotFound if the book has none.
+func (r *CoverRepository) Get(bookID string) (*domain.Cover, error) {
+	raw, err := os.ReadFile(r.path(bookID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cover: %w", err)
+	}
+	var cover domain.Cover
+	if err := json.Unmarshal(raw, &cover); err != nil {
+		return nil, fmt.Errorf("decode cover: %w", err)
+	}
+	return &cover, nil
+}
+
+// RemoveBook forgets the cover of a deleted book. The blobs stay in the blob
+// store since other books may share identical content.
+func (r *CoverRepository) RemoveBook(bookID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.Remove(r.path(bookID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove cover: %w", err)
+	}
+	return nil
+}
+
+func (r *CoverRepository) path(bookID string) string {
+	sum := sha256.Sum256([]byte(bookID))
+	return filepath.Join(r.dir, hex.EncodeToString(sum[:])+".json")
+}
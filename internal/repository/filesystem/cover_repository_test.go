@@ -0,0 +1,52 @@
+package filesystem_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+)
+
+// TestCoverRepositoryPersists verifies that covers saved by one repository
+// are read back by another on the same directory, as after a restart, and
+// that crafted book IDs stay inside it.
+func TestCoverRepositoryPersists(t *testing.T) {
+	root := t.TempDir()
+	dir := root + "/meta"
+	repo, err := filesystem.NewCoverRepository(dir)
+	if err != nil {
+		t.Fatalf("NewCoverRepository: %v", err)
+	}
+	cover := &domain.Cover{
+		BookID:    "../../book-1",
+		Images:    map[string]domain.CoverImage{domain.CoverSizeOriginal: {Key: "abc", ContentType: "image/png", Width: 10, Height: 20, Size: 30}},
+		UpdatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := repo.Save(cover); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Errorf("files outside the cover dir: %v", entries)
+	}
+
+	reopened, _ := filesystem.NewCoverRepository(dir)
+	got, err := reopened.Get(cover.BookID)
+	if err != nil {
+		t.Fatalf("Get after reopen: %v", err)
+	}
+	if got.BookID != cover.BookID || got.Images[domain.CoverSizeOriginal] != cover.Images[domain.CoverSizeOriginal] || !got.UpdatedAt.Equal(cover.UpdatedAt) {
+		t.Errorf("Get: got %+v, want %+v", got, cover)
+	}
+
+	if err := reopened.RemoveBook(cover.BookID); err != nil {
+		t.Fatalf("RemoveBook: %v", err)
+	}
+	if _, err := repo.Get(cover.BookID); err != domain.ErrNotFound {
+		t.Errorf("Get after RemoveBook: got %v, want ErrNotFound", err)
+	}
+	if err := repo.RemoveBook("never-had-one"); err != nil {
+		t.Errorf("RemoveBook without cover: %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// MaxDeliveriesPerWebhook bounds the delivery log kept for each webhook. When
+// a new delivery would exceed it, the oldest finished delivery is discarded;
+// pending and dead deliveries are never discarded to make room.
+const MaxDeliveriesPerWebhook = 500
+
+const (
+	webhooksFile  = "webhooks.json"
+	deliveriesDir = "deliveries"
+)
+
+// WebhookRepository implements domain.WebhookRepository and
+// domain.WebhookDeliveryRepository in a directory. Webhooks change rarely and
+// share webhooks.json; every delivery has its own file under deliveries/, so
+// queueing or updating a delivery writes only that delivery. Files are
+// replaced atomically (temp file, fsync, rename) before the in-memory state
+// changes, so a failed write leaves both as they were and a crash never
+// leaves a half-written file behind.
+type WebhookRepository struct {
+	mu         sync.RWMutex
+	dir        string
+	hooks      map[string]*domain.Webhook
+	deliveries map[string]*domain.WebhookDelivery
+}
+
+// NewWebhookRepository opens (or creates) the webhook store in dir.
+// Deliveries whose webhook no longer exists, left behind by a crash during
+// Delete, are removed.
+func NewWebhookRepository(dir string) (*WebhookRepository, error) {
+	r := &WebhookRepository{
+		dir:        dir,
+		hooks:      make(map[string]*domain.Webhook),
+		deliveries: make(map[string]*domain.WebhookDelivery),
+	}
+	if err := os.MkdirAll(filepath.Join(dir, deliveriesDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create webhook store dir: %w", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, webhooksFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read webhooks: %w", err)
+	default:
+		var hooks []*domain.Webhook
+		if err := json.Unmarshal(raw, &hooks); err != nil {
+			return nil, fmt.Errorf("parse webhooks: %w", err)
+		}
+		for _, h := range hooks {
+			r.hooks[h.ID] = h
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, deliveriesDir))
+	if err != nil {
+		return nil, fmt.Errorf("read deliveries: %w", err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, deliveriesDir, entry.Name())
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			os.Remove(path) // temp file of an interrupted write
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read delivery: %w", err)
+		}
+		var d domain.WebhookDelivery
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return nil, fmt.Errorf("parse delivery %s: %w", entry.Name(), err)
+		}
+		if _, ok := r.hooks[d.WebhookID]; !ok {
+			os.Remove(path)
+			continue
+		}
+		r.deliveries[d.ID] = &d
+	}
+	return r, nil
+}
+
+// Create stores a new webhook.
+func (r *WebhookRepository) Create(hook *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.hooks[hook.ID]; exists {
+		return domain.ErrConflict
+	}
+	return r.saveHooks(hook.ID, copyWebhook(hook))
+}
+
+// GetByID returns a copy of the webhook. Returns domain.ErrNotFound if absent.
+func (r *WebhookRepository) GetByID(id string) (*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, ok := r.hooks[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return copyWebhook(h), nil
+}
+
+// List returns copies of owner's webhooks oldest first; "" lists them all.
+func (r *WebhookRepository) List(owner string) ([]*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*domain.Webhook, 0)
+	for _, h := range r.hooks {
+		if owner == "" || h.Owner == owner {
+			result = append(result, copyWebhook(h))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// Update replaces a stored webhook. Returns domain.ErrNotFound if absent.
+func (r *WebhookRepository) Update(hook *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[hook.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	return r.saveHooks(hook.ID, copyWebhook(hook))
+}
+
+// Delete removes a webhook and all of its deliveries.
+// Returns domain.ErrNotFound if absent.
+func (r *WebhookRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return domain.ErrNotFound
+	}
+	if err := r.saveHooks(id, nil); err != nil {
+		return err
+	}
+	// The webhook is gone; a delivery file that cannot be removed now is
+	// dropped on the next start.
+	for did, d := range r.deliveries {
+		if d.WebhookID == id {
+			os.Remove(r.deliveryPath(did))
+			delete(r.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// Enqueue stores a new delivery, evicting the webhook's oldest finished
+// delivery if it already has MaxDeliveriesPerWebhook.
+// Returns domain.ErrNotFound if the webhook does not exist and
+// domain.ErrConflict if a delivery with the same ID is already stored.
+func (r *WebhookRepository) Enqueue(delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[delivery.WebhookID]; !ok {
+		return domain.ErrNotFound
+	}
+	if _, exists := r.deliveries[delivery.ID]; exists {
+		return domain.ErrConflict
+	}
+
+	var count int
+	var oldest *domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID != delivery.WebhookID {
+			continue
+		}
+		count++
+		if d.Status == domain.DeliverySucceeded && (oldest == nil || d.CreatedAt.Before(oldest.CreatedAt)) {
+			oldest = d
+		}
+	}
+
+	stored := copyDelivery(delivery)
+	if err := r.saveDelivery(stored); err != nil {
+		return err
+	}
+	r.deliveries[stored.ID] = stored
+	if count >= MaxDeliveriesPerWebhook && oldest != nil {
+		// Only forget the evicted delivery once its file is gone, or it
+		// would come back on the next start.
+		if err := os.Remove(r.deliveryPath(oldest.ID)); err == nil || errors.Is(err, fs.ErrNotExist) {
+			delete(r.deliveries, oldest.ID)
+		}
+	}
+	return nil
+}
+
+// GetDelivery returns a copy of the delivery. Returns domain.ErrNotFound if absent.
+func (r *WebhookRepository) GetDelivery(id string) (*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.deliveries[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return copyDelivery(d), nil
+}
+
+// UpdateDelivery replaces a stored delivery if delivery.Version is the stored
+// version, and on success advances delivery.Version. Returns
+// domain.ErrNotFound if absent, which includes deliveries of a webhook
+// deleted in the meantime, and domain.ErrConflict if the delivery was updated
+// since it was read.
+func (r *WebhookRepository) UpdateDelivery(delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.deliveries[delivery.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	if current.Version != delivery.Version {
+		return domain.ErrConflict
+	}
+	stored := copyDelivery(delivery)
+	stored.Version++
+	if err := r.saveDelivery(stored); err != nil {
+		return err
+	}
+	r.deliveries[stored.ID] = stored
+	delivery.Version = stored.Version
+	return nil
+}
+
+// Due returns up to limit pending deliveries that are ready at now, in
+// NextAttemptAt order.
+func (r *WebhookRepository) Due(now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var due []*domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == domain.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].EventSeq < due[j].EventSeq
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	result := make([]*domain.WebhookDelivery, len(due))
+	for i, d := range due {
+		result[i] = copyDelivery(d)
+	}
+	return result, nil
+}
+
+// ListDeliveries returns copies of matching deliveries, newest first.
+func (r *WebhookRepository) ListDeliveries(filter domain.DeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hooks := make(map[string]bool, len(filter.WebhookIDs))
+	for _, id := range filter.WebhookIDs {
+		hooks[id] = true
+	}
+
+	var matched []*domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if len(hooks) > 0 && !hooks[d.WebhookID] {
+			continue
+		}
+		if filter.Status != "" && d.Status != filter.Status {
+			continue
+		}
+		matched = append(matched, d)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].EventSeq > matched[j].EventSeq
+	})
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+
+	result := make([]*domain.WebhookDelivery, len(matched))
+	for i, d := range matched {
+		result[i] = copyDelivery(d)
+	}
+	return result, nil
+}
+
+// saveHooks writes the webhooks with id set to hook, or removed if hook is
+// nil, and then applies the change in memory. Callers must hold the write
+// lock.
+func (r *WebhookRepository) saveHooks(id string, hook *domain.Webhook) error {
+	hooks := make([]*domain.Webhook, 0, len(r.hooks)+1)
+	for _, h := range r.hooks {
+		if h.ID != id {
+			hooks = append(hooks, h)
+		}
+	}
+	if hook != nil {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+
+	raw, err := json.Marshal(hooks)
+	if err != nil {
+		return fmt.Errorf("encode webhooks: %w", err)
+	}
+	if err := replaceFile(filepath.Join(r.dir, webhooksFile), raw); err != nil {
+		return fmt.Errorf("save webhooks: %w", err)
+	}
+
+	if hook != nil {
+		r.hooks[id] = hook
+	} else {
+		delete(r.hooks, id)
+	}
+	return nil
+}
+
+// saveDelivery writes one delivery's file.
+func (r *WebhookRepository) saveDelivery(d *domain.WebhookDelivery) error {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode delivery: %w", err)
+	}
+	if err := replaceFile(r.deliveryPath(d.ID), raw); err != nil {
+		return fmt.Errorf("save delivery: %w", err)
+	}
+	return nil
+}
+
+// deliveryPath names a delivery's file. Delivery IDs are generated by the
+// dispatcher, never taken from a request, so they are safe as file names.
+func (r *WebhookRepository) deliveryPath(id string) string {
+	return filepath.Join(r.dir, deliveriesDir, id+".json")
+}
+
+// replaceFile atomically replaces path with data.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func copyWebhook(h *domain.Webhook) *domain.Webhook {
+	c := *h
+	c.Events = append(make([]string, 0, len(h.Events)), h.Events...)
+	return &c
+}
+
+func copyDelivery(d *domain.WebhookDelivery) *domain.WebhookDelivery {
+	c := *d
+	c.Payload = append([]byte(nil), d.Payload...)
+	c.History = append(make([]domain.DeliveryAttempt, 0, len(d.History)), d.History...)
+	return &c
+}
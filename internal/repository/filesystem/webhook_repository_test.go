@@ -0,0 +1,94 @@
+package filesystem_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+)
+
+func newDelivery(id, webhookID string) *domain.WebhookDelivery {
+	now := time.Now().UTC()
+	return &domain.WebhookDelivery{ID: id, WebhookID: webhookID, Status: domain.DeliveryPending, Payload: []byte(`{}`), NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+}
+
+// TestWebhookRepositoryFilePerDelivery verifies that every delivery is kept in
+// its own file and that the store reloads from them.
+func TestWebhookRepositoryFilePerDelivery(t *testing.T) {
+	dir := t.TempDir()
+	repo, _ := filesystem.NewWebhookRepository(dir)
+	repo.Create(&domain.Webhook{ID: "h1", Owner: "alice", URL: "https://example.com/hook"})
+	for _, id := range []string{"d1", "d2"} {
+		if err := repo.Enqueue(newDelivery(id, "h1")); err != nil {
+			t.Fatalf("Enqueue %s: %v", id, err)
+		}
+	}
+	if err := repo.Enqueue(newDelivery("d1", "h1")); err != domain.ErrConflict {
+		t.Errorf("Enqueue of a queued ID: got %v, want ErrConflict", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "deliveries")); len(entries) != 2 {
+		t.Errorf("delivery files: got %d, want 2", len(entries))
+	}
+
+	reopened, err := filesystem.NewWebhookRepository(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if due, _ := reopened.Due(time.Now().Add(time.Second), 0); len(due) != 2 {
+		t.Errorf("Due after reopen: got %d, want 2", len(due))
+	}
+}
+
+// TestWebhookRepositoryFailedWrite verifies that a write that fails leaves
+// the stored state unchanged.
+func TestWebhookRepositoryFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	repo, _ := filesystem.NewWebhookRepository(dir)
+	repo.Create(&domain.Webhook{ID: "h1", Owner: "alice", URL: "https://example.com/hook"})
+	repo.Enqueue(newDelivery("d1", "h1"))
+
+	os.RemoveAll(filepath.Join(dir, "deliveries"))
+	if err := repo.Enqueue(newDelivery("d2", "h1")); err == nil {
+		t.Fatal("Enqueue into a missing directory succeeded")
+	}
+	if _, err := repo.GetDelivery("d2"); err != domain.ErrNotFound {
+		t.Errorf("GetDelivery after failed Enqueue: got %v, want ErrNotFound", err)
+	}
+	d1, _ := repo.GetDelivery("d1")
+	d1.Status = domain.DeliveryDead
+	if err := repo.UpdateDelivery(d1); err == nil {
+		t.Fatal("UpdateDelivery into a missing directory succeeded")
+	}
+	if got, _ := repo.GetDelivery("d1"); got.Status != domain.DeliveryPending || got.Version != 0 {
+		t.Errorf("after failed UpdateDelivery: got status %q, version %d", got.Status, got.Version)
+	}
+}
+
+// TestWebhookRepositoryVersionCheck verifies that an update based on a stale
+// read is rejected.
+func TestWebhookRepositoryVersionCheck(t *testing.T) {
+	repo, _ := filesystem.NewWebhookRepository(t.TempDir())
+	repo.Create(&domain.Webhook{ID: "h1", Owner: "alice", URL: "https://example.com/hook"})
+	repo.Enqueue(newDelivery("d1", "h1"))
+
+	first, _ := repo.GetDelivery("d1")
+	second, _ := repo.GetDelivery("d1")
+	first.Attempts = 1
+	if err := repo.UpdateDelivery(first); err != nil || first.Version != 1 {
+		t.Fatalf("first update: got %v, version %d", err, first.Version)
+	}
+	second.Status = domain.DeliveryDead
+	if err := repo.UpdateDelivery(second); err != domain.ErrConflict {
+		t.Errorf("stale update: got %v, want ErrConflict", err)
+	}
+	first.Attempts = 2
+	if err := repo.UpdateDelivery(first); err != nil {
+		t.Errorf("second update with the advanced version: %v", err)
+	}
+	if got, _ := repo.GetDelivery("d1"); got.Attempts != 2 || got.Status != domain.DeliveryPending || got.Version != 2 {
+		t.Errorf("stored: got %+v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// AuditRepository is a thread-safe, append-only, in-memory implementation of
+// domain.AuditRepository.
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries []*domain.AuditEntry
+}
+
+// NewAuditRepository creates and returns an empty AuditRepository.
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// Append stores a copy of entry. O(1) amortised.
+func (r *AuditRepository) Append(entry *domain.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *entry
+	r.entries = append(r.entries, &stored)
+	return nil
+}
+
+// Last returns the newest entry. Returns domain.ErrNotFound if the log is empty.
+func (r *AuditRepository) Last() (*domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	last := *r.entries[len(r.entries)-1]
+	return &last, nil
+}
+
+// All returns copies of every entry in Seq order.
+func (r *AuditRepository) All() ([]*domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*domain.AuditEntry, len(r.entries))
+	for i, e := range r.entries {
+		c := *e
+		out[i] = &c
+	}
+	return out, nil
+}
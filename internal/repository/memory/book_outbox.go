@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/google/uuid"
+)
+
+// bookOutbox implements domain.BookOutbox for the book repositories that
+// embed it. They record a write's events while still holding the lock that
+// made the write, so events for the same book are in commit order and no
+// reader can see a change without its event.
+type bookOutbox struct {
+	mu     sync.Mutex
+	events []domain.BookEvent
+	notify chan struct{}
+}
+
+func newBookOutbox() bookOutbox {
+	return bookOutbox{notify: make(chan struct{}, 1)}
+}
+
+// Pending returns up to limit undispatched outbox events, oldest first.
+func (o *bookOutbox) Pending(limit int) ([]domain.BookEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(o.events)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	pending := make([]domain.BookEvent, n)
+	copy(pending, o.events)
+	return pending, nil
+}
+
+// MarkDispatched drops the given events from the outbox. Unknown IDs are
+// ignored, so marking twice is harmless.
+func (o *bookOutbox) MarkDispatched(ids ...string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	done := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		done[id] = true
+	}
+	kept := o.events[:0]
+	for _, e := range o.events {
+		if !done[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+	clear(o.events[len(kept):]) // release the dropped snapshots
+	o.events = kept
+	return nil
+}
+
+// Notify receives a value after events are recorded.
+func (o *bookOutbox) Notify() <-chan struct{} {
+	return o.notify
+}
+
+// record completes events with stored's state and appends them to the outbox.
+// Callers must hold the lock of the write and call it only once the write has
+// succeeded.
+func (o *bookOutbox) record(stored *domain.Book, events []domain.BookEvent) {
+	if len(events) == 0 {
+		return
+	}
+	o.mu.Lock()
+	for _, e := range events {
+		if e.ID == "" {
+			e.ID = uuid.New().String()
+		}
+		e.BookID = stored.ID
+		e.Book = copyBook(stored)
+		o.events = append(o.events, e)
+	}
+	o.mu.Unlock()
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
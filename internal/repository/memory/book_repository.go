@@ -2,113 +2,395 @@
 package memory
 
 import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/andrimuhayat/crud-test/internal/domain"
 )
 
 // BookRepository is a thread-safe, in-memory implementation of domain.BookRepository.
 // It uses a sync.RWMutex to allow many concurrent readers but only one writer at a time,
-// which is efficient for read-heavy workloads.
+// which is efficient for read-heavy workloads. ShardedBookRepository spreads
+// writers over several locks instead.
+//
+// Books are kept in insertion order in a linked list, so that removing one is
+// O(1), and indexed by author and by year, so that a listing filtered by
+// either visits only the matching books. The indexes are maintained by every
+// write.
+//
+// Books are copied on the way in and on the way out, so a caller can never
+// modify a stored book, or observe one change, outside the lock.
+//
+// It also implements domain.BookOutbox. Outbox events are appended under the
+// same write lock as the change they describe, so the outbox order is the
+// commit order and no reader can see a change without its event.
 type BookRepository struct {
-	mu    sync.RWMutex
-	books map[string]*domain.Book
-	order []string // insertion-order slice of IDs for stable LIST results
+	mu  sync.RWMutex
+	seq uint64 // sequence number of the last inserted book
+	bookSet
+	bookOutbox
 }
 
 // NewBookRepository creates and returns an initialised BookRepository.
 func NewBookRepository() *BookRepository {
-	return &BookRepository{
-		books: make(map[string]*domain.Book),
-		order: make([]string, 0),
-	}
+	return &BookRepository{bookSet: newBookSet(), bookOutbox: newBookOutbox()}
 }
 
-// Create stores a new book. O(1) amortised.
-func (r *BookRepository) Create(book *domain.Book) error {
+// Create stores a copy of a new book. O(1) amortised.
+func (r *BookRepository) Create(book *domain.Book, events ...domain.BookEvent) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.books[book.ID] = book
-	r.order = append(r.order, book.ID)
+	r.seq++
+	r.record(r.create(book, r.seq), events)
 	return nil
 }
 
-// GetByID returns a single book by ID. Returns domain.ErrNotFound if absent.
+// GetByID returns a copy of a single book by ID. Returns domain.ErrNotFound
+// if absent or trashed.
 func (r *BookRepository) GetByID(id string) (*domain.Book, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	book, ok := r.books[id]
-	if !ok {
-		return nil, domain.ErrNotFound
+	book, err := r.get(id)
+	if err != nil {
+		return nil, err
 	}
-	return book, nil
+	return copyBook(book), nil
 }
 
 // GetAll returns books matching the filter, plus the total count before pagination.
-// Filtering by Author is case-sensitive substring-free (exact match).
+// Filtering by Author and Tag is case-sensitive substring-free (exact match).
 // Pagination uses 1-based page numbers.
+//
+// Without an Author or Year filter every book is visited; with one, only the
+// books in the smaller matching index, which are then put back in insertion
+// order: O(k log k) for k such books. Tag and Query are checked on each
+// visited book.
 func (r *BookRepository) GetAll(filter domain.BookFilter) ([]*domain.Book, int, error) {
+	books, total := page(r.list(filter), filter, nil)
+	return books, total, nil
+}
+
+// GetAllFaceted is GetAll counting the facets of the matching books while it
+// filters them, so faceting adds no pass over the books.
+func (r *BookRepository) GetAllFaceted(filter domain.BookFilter) ([]*domain.Book, int, domain.BookFacets, error) {
+	counts := newFacetCounts()
+	books, total := page(r.list(filter), filter, &counts)
+	return books, total, counts.facets(), nil
+}
+
+// list returns the stored books matching filter, in insertion order.
+func (r *BookRepository) list(filter domain.BookFilter) []*domain.Book {
+	match := matcher(filter)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	filtered := make([]*domain.Book, 0, len(r.order))
-	for _, id := range r.order {
-		book := r.books[id]
-		if filter.Author != "" && book.Author != filter.Author {
-			continue
+	books := make([]*domain.Book, 0, r.size(filter.Author, filter.Year))
+	r.each(filter.Author, filter.Year, func(e *bookEntry) {
+		if match(e.book) {
+			books = append(books, e.book)
+		}
+	})
+	return books
+}
+
+// page counts the facets of the matching stored books unless counts is nil,
+// then sorts and pages them and copies the books of the page. It returns the
+// page and the number of matching books.
+func page(books []*domain.Book, filter domain.BookFilter, counts *facetCounts) ([]*domain.Book, int) {
+	if counts != nil {
+		for _, book := range books {
+			counts.add(book)
 		}
-		filtered = append(filtered, book)
 	}
 
-	total := len(filtered)
+	sortBooks(books, filter.Sort)
+
+	total := len(books)
 
 	// Apply pagination only when both page and limit are explicitly provided.
 	if filter.Page > 0 && filter.Limit > 0 {
 		start := (filter.Page - 1) * filter.Limit
 		if start >= total {
-			return make([]*domain.Book, 0), total, nil
+			return make([]*domain.Book, 0), total
 		}
 		end := start + filter.Limit
 		if end > total {
 			end = total
 		}
-		filtered = filtered[start:end]
+		books = books[start:end]
 	}
 
-	return filtered, total, nil
+	out := make([]*domain.Book, len(books))
+	for i, book := range books {
+		out[i] = copyBook(book)
+	}
+	return out, total
 }
 
-// Update replaces the stored book. Returns domain.ErrNotFound if the ID is absent.
-func (r *BookRepository) Update(book *domain.Book) error {
+// facetCounts accumulates the facet values of the books of a listing.
+type facetCounts struct {
+	author, decade, tag map[string]int
+}
+
+func newFacetCounts() facetCounts {
+	return facetCounts{author: make(map[string]int), decade: make(map[string]int), tag: make(map[string]int)}
+}
+
+func (f *facetCounts) add(book *domain.Book) {
+	f.author[book.Author]++
+	f.decade[groupKeys[domain.GroupByDecade](book)]++
+	for _, tag := range book.Tags {
+		f.tag[tag]++
+	}
+}
+
+func (f *facetCounts) facets() domain.BookFacets {
+	return domain.BookFacets{Author: sortedCounts(f.author), Decade: sortedCounts(f.decade), Tag: sortedCounts(f.tag)}
+}
+
+// sortedCounts orders counts by descending count, then by value.
+func sortedCounts(counts map[string]int) []domain.FacetCount {
+	out := make([]domain.FacetCount, 0, len(counts))
+	for v, n := range counts {
+		out = append(out, domain.FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// Update replaces the stored book with a copy of book. Returns domain.ErrNotFound if the ID is absent or trashed.
+// The trash state is owned by SoftDelete and Restore, and the rating by
+// UpdateRating, so the stored ones are kept.
+func (r *BookRepository) Update(book *domain.Book, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.update(book)
+	if err != nil {
+		return err
+	}
+	r.record(stored, events)
+	return nil
+}
+
+// UpdateRating sets the rating of a book, trashed or not. Returns
+// domain.ErrNotFound if the ID is absent.
+func (r *BookRepository) UpdateRating(id string, rating domain.RatingSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rate(id, rating)
+}
+
+// SoftDelete moves a book to the trash. Returns domain.ErrNotFound if the ID is
+// absent or already trashed. The stored book is replaced, not modified, so
+// callers holding an earlier *domain.Book do not observe the change.
+func (r *BookRepository) SoftDelete(id, deletedBy string, at time.Time, events ...domain.BookEvent) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.books[book.ID]; !ok {
-		return domain.ErrNotFound
+	trashed, err := r.softDelete(id, deletedBy, at)
+	if err != nil {
+		return err
 	}
-	r.books[book.ID] = book
+	r.record(trashed, events)
 	return nil
 }
 
-// Delete removes a book by ID. Returns domain.ErrNotFound if the ID is absent.
+// Restore takes a book out of the trash. Returns domain.ErrNotFound if the ID
+// is absent or not trashed.
+func (r *BookRepository) Restore(id string, events ...domain.BookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restored, err := r.restore(id)
+	if err != nil {
+		return err
+	}
+	r.record(restored, events)
+	return nil
+}
+
+// PurgeTrashed permanently deletes every book trashed before the cutoff in a
+// single critical section, so a concurrent Restore either wins or loses as a
+// whole. O(n).
+func (r *BookRepository) PurgeTrashed(before time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged []string
+	for _, e := range r.purge(before) {
+		purged = append(purged, e.book.ID)
+	}
+	return purged, nil
+}
+
+// Delete permanently removes a book by ID, trashed or not. Returns
+// domain.ErrNotFound if the ID is absent. O(1).
 func (r *BookRepository) Delete(id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.books[id]; !ok {
-		return domain.ErrNotFound
+	return r.delete(id)
+}
+
+// Stats aggregates the live books matching query in a single pass under the
+// read lock, then orders the groups. O(n + g log g) for g groups; with an
+// Author, only that author's books are visited.
+func (r *BookRepository) Stats(query domain.BookStatsQuery) ([]domain.BookGroup, error) {
+	groups, ok := newBookGroups(query.GroupBy)
+	if !ok {
+		return nil, domain.ErrInvalidData
 	}
-	delete(r.books, id)
 
-	// Remove id from the insertion-order slice.
-	for i, oid := range r.order {
-		if oid == id {
-			r.order = append(r.order[:i], r.order[i+1:]...)
-			break
+	r.mu.RLock()
+	r.each(query.Author, 0, groups.add)
+	r.mu.RUnlock()
+
+	return groups.sorted(), nil
+}
+
+// bookGroups accumulates the BookGroup of each key of a Stats query.
+type bookGroups struct {
+	groupBy string
+	key     func(*domain.Book) string
+	groups  map[string]*domain.BookGroup
+}
+
+// newBookGroups reports false for an unknown groupBy.
+func newBookGroups(groupBy string) (*bookGroups, bool) {
+	key, ok := groupKeys[groupBy]
+	if !ok {
+		return nil, false
+	}
+	return &bookGroups{groupBy: groupBy, key: key, groups: make(map[string]*domain.BookGroup)}, true
+}
+
+// add counts e's book if it is live.
+func (g *bookGroups) add(e *bookEntry) {
+	book := e.book
+	if book.DeletedAt != nil {
+		return
+	}
+	k := g.key(book)
+	group, ok := g.groups[k]
+	if !ok {
+		group = &domain.BookGroup{Key: k}
+		g.groups[k] = group
+	}
+	group.Count++
+	if book.Year != 0 {
+		if group.MinYear == 0 || book.Year < group.MinYear {
+			group.MinYear = book.Year
 		}
+		group.MaxYear = max(group.MaxYear, book.Year)
 	}
-	return nil
 }
 
+// sorted returns the groups ordered by key: O(g log g) for g groups.
+func (g *bookGroups) sorted() []domain.BookGroup {
+	out := make([]domain.BookGroup, 0, len(g.groups))
+	for _, group := range g.groups {
+		out = append(out, *group)
+	}
+	numeric := g.groupBy == domain.GroupByYear || g.groupBy == domain.GroupByDecade
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].Key, out[j].Key
+		if numeric && len(a) != len(b) {
+			// Keys are unpadded numbers, so a shorter one is smaller.
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return out
+}
+
+// groupKeys maps each BookStatsQuery.GroupBy to the group key of a book.
+var groupKeys = map[string]func(*domain.Book) string{
+	domain.GroupByAuthor: func(b *domain.Book) string { return b.Author },
+	domain.GroupByYear: func(b *domain.Book) string {
+		if b.Year == 0 {
+			return ""
+		}
+		return strconv.Itoa(b.Year)
+	},
+	domain.GroupByDecade: func(b *domain.Book) string {
+		if b.Year == 0 {
+			return ""
+		}
+		return strconv.Itoa(b.Year/10*10) + "s"
+	},
+	domain.GroupByCreatedMonth: func(b *domain.Book) string { return b.CreatedAt.UTC().Format("2006-01") },
+}
+
+// matcher returns a predicate for the books matching filter's Tag, Query and
+// Trash; bookSet.each selects by Author and Year.
+func matcher(filter domain.BookFilter) func(*domain.Book) bool {
+	words := strings.Fields(strings.ToLower(filter.Query))
+	return func(book *domain.Book) bool {
+		if filter.Tag != "" && !slices.Contains(book.Tags, filter.Tag) ||
+			!matchesTrash(book, filter.Trash) {
+			return false
+		}
+		if len(words) == 0 {
+			return true
+		}
+		text := strings.ToLower(book.Title + "\n" + book.Author + "\n" + strings.Join(book.Tags, "\n"))
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func matchesTrash(book *domain.Book, mode domain.TrashFilter) bool {
+	switch mode {
+	case domain.OnlyTrashed:
+		return book.DeletedAt != nil
+	case domain.IncludeTrashed:
+		return true
+	default:
+		return book.DeletedAt == nil
+	}
+}
+
+// sortBooks orders books in place by the given BookFilter.Sort key. The sort is
+// stable so ties keep their insertion order.
+func sortBooks(books []*domain.Book, key string) {
+	if key == "" {
+		return
+	}
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	var less func(a, b *domain.Book) bool
+	switch key {
+	case domain.SortByRating:
+		less = func(a, b *domain.Book) bool { return a.AverageRating < b.AverageRating }
+	case domain.SortByReviews:
+		less = func(a, b *domain.Book) bool { return a.ReviewCount < b.ReviewCount }
+	default:
+		return
+	}
+
+	sort.SliceStable(books, func(i, j int) bool {
+		if desc {
+			return less(books[j], books[i])
+		}
+		return less(books[i], books[j])
+	})
+}
@@ -0,0 +1,161 @@
+package memory_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// Benchmark catalogue: benchBooks books by benchAuthors authors, spread over
+// benchYears years.
+const (
+	benchBooks   = 1_000_000
+	benchAuthors = 1000
+	benchYears   = 125
+)
+
+func benchBook(i int) *domain.Book {
+	return &domain.Book{
+		ID:        fmt.Sprintf("book-%07d", i),
+		Title:     fmt.Sprintf("Title %d", i),
+		Author:    fmt.Sprintf("Author %d", i%benchAuthors),
+		Year:      1900 + i%benchYears,
+		CreatedAt: time.Unix(int64(i), 0).UTC(),
+	}
+}
+
+// populate fills repo, or a new BookRepository if it is nil, with the
+// benchmark catalogue.
+func populate(b *testing.B, repo domain.BookRepository) domain.BookRepository {
+	b.Helper()
+	if repo == nil {
+		repo = memory.NewBookRepository()
+	}
+	for i := 0; i < benchBooks; i++ {
+		if err := repo.Create(benchBook(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// BenchmarkGetAllByAuthor lists the first page of one author's 1000 books.
+func BenchmarkGetAllByAuthor(b *testing.B) {
+	repo := populate(b, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		books, total, _ := repo.GetAll(domain.BookFilter{Author: "Author 500", Page: 1, Limit: 20})
+		if len(books) != 20 || total != benchBooks/benchAuthors {
+			b.Fatalf("got %d of %d", len(books), total)
+		}
+	}
+}
+
+// BenchmarkGetAllByYear lists the first page of one year's 8000 books.
+func BenchmarkGetAllByYear(b *testing.B) {
+	repo := populate(b, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		books, total, _ := repo.GetAll(domain.BookFilter{Year: 1950, Page: 1, Limit: 20})
+		if len(books) != 20 || total != benchBooks/benchYears {
+			b.Fatalf("got %d of %d", len(books), total)
+		}
+	}
+}
+
+// BenchmarkGetAllPage lists the first page of every book, which scans them all
+// whatever the indexes.
+func BenchmarkGetAllPage(b *testing.B) {
+	repo := populate(b, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if books, _, _ := repo.GetAll(domain.BookFilter{Page: 1, Limit: 20}); len(books) != 20 {
+			b.Fatalf("got %d books", len(books))
+		}
+	}
+}
+
+// BenchmarkGetAllPageSharded is BenchmarkGetAllPage on a ShardedBookRepository,
+// which merges its shards back into insertion order.
+func BenchmarkGetAllPageSharded(b *testing.B) {
+	repo := populate(b, memory.NewShardedBookRepository(memory.DefaultBookShards))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if books, _, _ := repo.GetAll(domain.BookFilter{Page: 1, Limit: 20}); len(books) != 20 {
+			b.Fatalf("got %d books", len(books))
+		}
+	}
+}
+
+// BenchmarkDelete removes books spread over the insertion order, refilling
+// the repository outside the timer when every book has been removed.
+func BenchmarkDelete(b *testing.B) {
+	repo := populate(b, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i > 0 && i%benchBooks == 0 {
+			b.StopTimer()
+			repo = populate(b, nil)
+			b.StartTimer()
+		}
+		// 7919 is prime, so i*7919 visits every index once per round.
+		id := fmt.Sprintf("book-%07d", i*7919%benchBooks)
+		if err := repo.Delete(id); err != nil {
+			b.Fatalf("Delete %s: %v", id, err)
+		}
+	}
+}
+
+// mixedBooks is the size of the repositories of BenchmarkMixed.
+const mixedBooks = 100_000
+
+// BenchmarkMixed runs GetByID and Update on random books from parallel
+// goroutines, with 10%, 50% and 90% of the operations writes, against the
+// single-lock BookRepository and a ShardedBookRepository. Run it with -cpu to
+// compare them as the number of cores grows.
+func BenchmarkMixed(b *testing.B) {
+	books := make([]*domain.Book, mixedBooks)
+	for i := range books {
+		books[i] = benchBook(i)
+	}
+	repos := []struct {
+		name string
+		new  func() domain.BookRepository
+	}{
+		{"single", func() domain.BookRepository { return memory.NewBookRepository() }},
+		{"sharded", func() domain.BookRepository { return memory.NewShardedBookRepository(memory.DefaultBookShards) }},
+	}
+	for _, writes := range []int{10, 50, 90} {
+		for _, r := range repos {
+			b.Run(fmt.Sprintf("writes=%d%%/%s", writes, r.name), func(b *testing.B) {
+				repo := r.new()
+				for _, book := range books {
+					_ = repo.Create(book)
+				}
+				var seed atomic.Uint64
+				b.SetParallelism(8)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rng := rand.New(rand.NewPCG(seed.Add(1), 0))
+					for pb.Next() {
+						book := books[rng.IntN(mixedBooks)]
+						var err error
+						if rng.IntN(100) < writes {
+							err = repo.Update(book)
+						} else {
+							_, err = repo.GetByID(book.ID)
+						}
+						if err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
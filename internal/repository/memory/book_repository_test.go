@@ -114,6 +114,60 @@ func TestConcurrentMixedOps(t *testing.T) {
 	wg.Wait()
 }
 
+// TestBooksAreCopied verifies that the repository never shares a book with
+// its callers: changing a book after Create or Update, or one returned by
+// GetByID or GetAll, leaves the stored book as it was. Under -race, the
+// concurrent part fails if GetByID returns the stored book, which the writer
+// modifies while the reader reads it.
+func TestBooksAreCopied(t *testing.T) {
+	repo := memory.NewBookRepository()
+	book := newBook(1)
+	book.Tags = []string{"sf"}
+	_ = repo.Create(book)
+	book.Title, book.Tags[0] = "changed after Create", "changed after Create"
+	got, _ := repo.GetByID("book-1")
+	got.Title, got.Tags[0] = "changed after GetByID", "changed after GetByID"
+	books, _, _ := repo.GetAll(domain.BookFilter{})
+	books[0].Title, books[0].Tags[0] = "changed after GetAll", "changed after GetAll"
+	update := newBook(1)
+	update.Tags = []string{"sf"}
+	_ = repo.Update(update)
+	update.Title, update.Tags[0] = "changed after Update", "changed after Update"
+	trashedAt := time.Now()
+	_ = repo.SoftDelete("book-1", "alice", trashedAt)
+	trashed, _, _ := repo.GetAll(domain.BookFilter{Trash: domain.OnlyTrashed})
+	*trashed[0].DeletedAt = time.Time{}
+
+	trashed, _, _ = repo.GetAll(domain.BookFilter{Trash: domain.OnlyTrashed})
+	if got := trashed[0]; got.Title != "Title 1" || !got.DeletedAt.Equal(trashedAt) {
+		t.Fatalf("stored book: got %q trashed at %v, want %q trashed at %v", got.Title, got.DeletedAt, "Title 1", trashedAt)
+	}
+	if got := fmt.Sprint(trashed[0].Tags); got != "[sf]" {
+		t.Fatalf("stored tags: got %s, want [sf]", got)
+	}
+	_ = repo.Restore("book-1")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if b, err := repo.GetByID("book-1"); err != nil || b.Title == "" {
+				t.Errorf("GetByID: %+v, %v", b, err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			b, _ := repo.GetByID("book-1")
+			b.Title = fmt.Sprintf("Title %d", i)
+			_ = repo.Update(b)
+		}
+	}()
+	wg.Wait()
+}
+
 // TestPagination verifies correct page/total behaviour.
 func TestPagination(t *testing.T) {
 	repo := memory.NewBookRepository()
@@ -150,3 +204,286 @@ func TestNotFound(t *testing.T) {
 		t.Errorf("Delete: want ErrNotFound, got %v", err)
 	}
 }
+
+// TestSoftDeleteAndRestore verifies trashed books are hidden by default, listed
+// with OnlyTrashed, and visible again after Restore.
+func TestSoftDeleteAndRestore(t *testing.T) {
+	repo := memory.NewBookRepository()
+	for i := 0; i < 3; i++ {
+		_ = repo.Create(newBook(i))
+	}
+
+	if err := repo.SoftDelete("book-1", "alice", time.Now()); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	if err := repo.SoftDelete("book-1", "alice", time.Now()); err != domain.ErrNotFound {
+		t.Errorf("SoftDelete twice: want ErrNotFound, got %v", err)
+	}
+	if _, err := repo.GetByID("book-1"); err != domain.ErrNotFound {
+		t.Errorf("GetByID trashed: want ErrNotFound, got %v", err)
+	}
+	if err := repo.Update(newBook(1)); err != domain.ErrNotFound {
+		t.Errorf("Update trashed: want ErrNotFound, got %v", err)
+	}
+
+	_, live, _ := repo.GetAll(domain.BookFilter{})
+	trash, trashed, _ := repo.GetAll(domain.BookFilter{Trash: domain.OnlyTrashed})
+	_, all, _ := repo.GetAll(domain.BookFilter{Trash: domain.IncludeTrashed})
+	if live != 2 || trashed != 1 || all != 3 {
+		t.Errorf("counts: live=%d trashed=%d all=%d, want 2/1/3", live, trashed, all)
+	}
+	if trash[0].DeletedBy != "alice" || trash[0].DeletedAt == nil {
+		t.Errorf("trashed book missing deletion metadata: %+v", trash[0])
+	}
+
+	if err := repo.Restore("book-1"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if err := repo.Restore("book-1"); err != domain.ErrNotFound {
+		t.Errorf("Restore live book: want ErrNotFound, got %v", err)
+	}
+	book, err := repo.GetByID("book-1")
+	if err != nil || book.DeletedAt != nil || book.DeletedBy != "" {
+		t.Errorf("restored book: got %+v, %v", book, err)
+	}
+}
+
+// TestPurgeTrashed verifies only books trashed before the cutoff are removed
+// and that insertion order of the survivors is preserved.
+func TestPurgeTrashed(t *testing.T) {
+	repo := memory.NewBookRepository()
+	for i := 0; i < 4; i++ {
+		_ = repo.Create(newBook(i))
+	}
+	now := time.Now()
+	_ = repo.SoftDelete("book-0", "alice", now.Add(-48*time.Hour))
+	_ = repo.SoftDelete("book-2", "alice", now)
+
+	purged, err := repo.PurgeTrashed(now.Add(-24 * time.Hour))
+	if err != nil || len(purged) != 1 || purged[0] != "book-0" {
+		t.Fatalf("PurgeTrashed: got %v, %v, want [book-0]", purged, err)
+	}
+
+	books, _, _ := repo.GetAll(domain.BookFilter{Trash: domain.IncludeTrashed})
+	var ids []string
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	if fmt.Sprint(ids) != "[book-1 book-2 book-3]" {
+		t.Errorf("remaining: got %v, want [book-1 book-2 book-3]", ids)
+	}
+}
+
+// TestIndexedFilters verifies that author and year filters follow updates,
+// trashing, deletion and purging, and list their books in insertion order.
+func TestIndexedFilters(t *testing.T) {
+	repo := memory.NewBookRepository()
+	for i := 0; i < 6; i++ {
+		b := newBook(i)
+		b.Author, b.Year = []string{"Herbert", "Austen"}[i%2], 1965+i%3
+		_ = repo.Create(b)
+	}
+	moved := newBook(0)
+	moved.Author, moved.Year = "Austen", 1811
+	_ = repo.Update(moved)
+	_ = repo.Delete("book-3")
+	_ = repo.SoftDelete("book-5", "alice", time.Now().Add(-time.Hour))
+	_, _ = repo.PurgeTrashed(time.Now())
+
+	ids := func(filter domain.BookFilter) string {
+		books, total, _ := repo.GetAll(filter)
+		var out []string
+		for _, b := range books {
+			out = append(out, b.ID)
+		}
+		return fmt.Sprint(total, out)
+	}
+	cases := []struct {
+		filter domain.BookFilter
+		want   string
+	}{
+		{domain.BookFilter{}, "4 [book-0 book-1 book-2 book-4]"},
+		{domain.BookFilter{Author: "Austen"}, "2 [book-0 book-1]"},
+		{domain.BookFilter{Author: "Herbert"}, "2 [book-2 book-4]"},
+		{domain.BookFilter{Year: 1966}, "2 [book-1 book-4]"},
+		{domain.BookFilter{Year: 1811}, "1 [book-0]"},
+		{domain.BookFilter{Year: 1965}, "0 []"},
+		{domain.BookFilter{Author: "Herbert", Year: 1966}, "1 [book-4]"},
+		{domain.BookFilter{Author: "Herbert", Year: 1966, Page: 2, Limit: 1}, "1 []"},
+		{domain.BookFilter{Author: "Nobody"}, "0 []"},
+	}
+	for _, tc := range cases {
+		if got := ids(tc.filter); got != tc.want {
+			t.Errorf("GetAll(%+v): got %s, want %s", tc.filter, got, tc.want)
+		}
+	}
+}
+
+// TestStats verifies each grouping, the author filter, the exclusion of
+// trashed books and the order of the groups.
+func TestStats(t *testing.T) {
+	repo := memory.NewBookRepository()
+	created := time.Date(2024, 3, 31, 23, 0, 0, 0, time.FixedZone("", -2*3600))
+	for i, year := range []int{1965, 1969, 0, 987, 2001, 1965} {
+		b := newBook(i)
+		b.Author, b.Year, b.CreatedAt = []string{"Herbert", "Austen"}[i%2], year, created
+		_ = repo.Create(b)
+	}
+	_ = repo.SoftDelete("book-4", "alice", time.Now())
+
+	format := func(groups []domain.BookGroup) string {
+		var out []string
+		for _, g := range groups {
+			out = append(out, fmt.Sprintf("%s:%d:%d-%d", g.Key, g.Count, g.MinYear, g.MaxYear))
+		}
+		return fmt.Sprint(out)
+	}
+	cases := []struct {
+		query domain.BookStatsQuery
+		want  string
+	}{
+		{domain.BookStatsQuery{GroupBy: domain.GroupByAuthor}, "[Austen:3:987-1969 Herbert:2:1965-1965]"},
+		{domain.BookStatsQuery{GroupBy: domain.GroupByYear}, "[:1:0-0 987:1:987-987 1965:2:1965-1965 1969:1:1969-1969]"},
+		{domain.BookStatsQuery{GroupBy: domain.GroupByDecade}, "[:1:0-0 980s:1:987-987 1960s:3:1965-1969]"},
+		{domain.BookStatsQuery{GroupBy: domain.GroupByDecade, Author: "Herbert"}, "[:1:0-0 1960s:1:1965-1965]"},
+		{domain.BookStatsQuery{GroupBy: domain.GroupByCreatedMonth}, "[2024-04:5:987-1969]"},
+	}
+	for _, tc := range cases {
+		groups, err := repo.Stats(tc.query)
+		if got := format(groups); err != nil || got != tc.want {
+			t.Errorf("Stats(%+v): got %s, %v; want %s", tc.query, got, err, tc.want)
+		}
+	}
+	if _, err := repo.Stats(domain.BookStatsQuery{GroupBy: "title"}); err != domain.ErrInvalidData {
+		t.Errorf("Stats by title: got %v, want ErrInvalidData", err)
+	}
+}
+
+// TestGetAllFaceted verifies that facets count every matching book, not only
+// the requested page, and ignore filtered-out and trashed books.
+func TestGetAllFaceted(t *testing.T) {
+	repo := memory.NewBookRepository()
+	for i, year := range []int{1965, 1969, 0, 1811, 1815, 1976} {
+		b := newBook(i)
+		b.Author, b.Year = []string{"Herbert", "Austen", "Herbert"}[i%3], year
+		_ = repo.Create(b)
+	}
+	_ = repo.SoftDelete("book-5", "alice", time.Now())
+
+	books, total, facets, err := repo.GetAllFaceted(domain.BookFilter{Page: 1, Limit: 2})
+	if err != nil || len(books) != 2 || total != 5 {
+		t.Fatalf("GetAllFaceted: got %d books of %d, %v; want 2 of 5", len(books), total, err)
+	}
+	if got := fmt.Sprint(facets.Author); got != "[{Herbert 3} {Austen 2}]" {
+		t.Errorf("author facet: got %s", got)
+	}
+	if got := fmt.Sprint(facets.Decade); got != "[{1810s 2} {1960s 2} { 1}]" {
+		t.Errorf("decade facet: got %s", got)
+	}
+
+	_, _, facets, _ = repo.GetAllFaceted(domain.BookFilter{Author: "Austen"})
+	if got := fmt.Sprint(facets.Author, facets.Decade); got != "[{Austen 2}] [{1810s 1} {1960s 1}]" {
+		t.Errorf("Austen facets: got %s", got)
+	}
+}
+
+// TestTagAndQueryFilters verifies that Tag matches one of a book's tags
+// exactly, that Query matches each of its words in the title, author or tags
+// ignoring case, and that the tag facet counts each tag of the matching books.
+func TestTagAndQueryFilters(t *testing.T) {
+	repo := memory.NewBookRepository()
+	for i, b := range []struct {
+		title, author string
+		tags          []string
+	}{
+		{"Dune", "Frank Herbert", []string{"science fiction", "classic"}},
+		{"Emma", "Jane Austen", []string{"classic", "romance"}},
+		{"Persuasion", "Jane Austen", nil},
+		{"The Dispossessed", "Ursula K. Le Guin", []string{"science fiction"}},
+	} {
+		book := newBook(i)
+		book.Title, book.Author, book.Tags = b.title, b.author, b.tags
+		_ = repo.Create(book)
+	}
+
+	titles := func(books []*domain.Book) string {
+		var out []string
+		for _, b := range books {
+			out = append(out, b.Title)
+		}
+		return fmt.Sprint(out)
+	}
+	cases := []struct {
+		filter     domain.BookFilter
+		want, tags string
+	}{
+		{domain.BookFilter{}, "[Dune Emma Persuasion The Dispossessed]", "[{classic 2} {science fiction 2} {romance 1}]"},
+		{domain.BookFilter{Tag: "classic"}, "[Dune Emma]", "[{classic 2} {romance 1} {science fiction 1}]"},
+		{domain.BookFilter{Tag: "Classic"}, "[]", "[]"},
+		{domain.BookFilter{Query: "austen"}, "[Emma Persuasion]", "[{classic 1} {romance 1}]"},
+		{domain.BookFilter{Query: "FICTION le"}, "[The Dispossessed]", "[{science fiction 1}]"},
+		{domain.BookFilter{Query: "classic", Author: "Jane Austen"}, "[Emma]", "[{classic 1} {romance 1}]"},
+		{domain.BookFilter{Query: "dune austen"}, "[]", "[]"},
+	}
+	for _, tc := range cases {
+		books, total, facets, err := repo.GetAllFaceted(tc.filter)
+		if got := titles(books); err != nil || got != tc.want || total != len(books) {
+			t.Errorf("GetAllFaceted(%+v): got %s (total %d), %v; want %s", tc.filter, got, total, err, tc.want)
+		}
+		if got := fmt.Sprint(facets.Tag); got != tc.tags {
+			t.Errorf("tag facet of %+v: got %s, want %s", tc.filter, got, tc.tags)
+		}
+	}
+}
+
+// TestOutboxRecordsEventsWithWrites verifies events are recorded only when
+// their write succeeds, in commit order, with IDs and book snapshots filled in.
+func TestOutboxRecordsEventsWithWrites(t *testing.T) {
+	repo := memory.NewBookRepository()
+	book := newBook(1)
+
+	if err := repo.Create(book, domain.BookEvent{Type: domain.EventBookCreated}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	missing := newBook(2)
+	if err := repo.Update(missing, domain.BookEvent{Type: domain.EventBookUpdated}); err != domain.ErrNotFound {
+		t.Fatalf("Update missing: got %v, want ErrNotFound", err)
+	}
+	if err := repo.SoftDelete(book.ID, "alice", time.Now(), domain.BookEvent{Type: domain.EventBookDeleted}); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	if err := repo.Restore(book.ID, domain.BookEvent{ID: "fixed", Type: domain.EventBookRestored}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	select {
+	case <-repo.Notify():
+	default:
+		t.Error("Notify: no notification after writes")
+	}
+
+	pending, _ := repo.Pending(0)
+	wantTypes := []string{domain.EventBookCreated, domain.EventBookDeleted, domain.EventBookRestored}
+	if len(pending) != len(wantTypes) {
+		t.Fatalf("Pending: got %d events, want %d", len(pending), len(wantTypes))
+	}
+	for i, e := range pending {
+		if e.Type != wantTypes[i] || e.ID == "" || e.BookID != book.ID || e.Book == nil {
+			t.Errorf("event %d: got %+v", i, e)
+		}
+	}
+	if pending[1].Book.DeletedBy != "alice" || pending[2].Book.DeletedAt != nil {
+		t.Error("snapshots do not reflect the state after each write")
+	}
+	if pending[2].ID != "fixed" {
+		t.Errorf("caller-supplied ID: got %q, want %q", pending[2].ID, "fixed")
+	}
+
+	if first, _ := repo.Pending(1); len(first) != 1 || first[0].ID != pending[0].ID {
+		t.Errorf("Pending(1): got %+v", first)
+	}
+	repo.MarkDispatched(pending[0].ID, pending[2].ID, "unknown")
+	if rest, _ := repo.Pending(0); len(rest) != 1 || rest[0].ID != pending[1].ID {
+		t.Errorf("after MarkDispatched: got %+v", rest)
+	}
+}
@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"cmp"
+	"container/list"
+	"slices"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// bookEntry is a stored book with its place in the insertion order.
+type bookEntry struct {
+	book *domain.Book
+	seq  uint64        // increases with each insertion
+	elem *list.Element // the entry's element in bookSet.order
+}
+
+// bookIndex maps a key to the entries having it, by book ID. Keys with no
+// entries are removed, so the index never outgrows the books it covers.
+type bookIndex[K comparable] map[K]map[string]*bookEntry
+
+func (ix bookIndex[K]) add(key K, e *bookEntry) {
+	set, ok := ix[key]
+	if !ok {
+		set = make(map[string]*bookEntry)
+		ix[key] = set
+	}
+	set[e.book.ID] = e
+}
+
+func (ix bookIndex[K]) remove(key K, id string) {
+	set := ix[key]
+	delete(set, id)
+	if len(set) == 0 {
+		delete(ix, key)
+	}
+}
+
+// bookSet holds books in insertion order, in a linked list so that removing
+// one is O(1), and indexes them by author and by year, so that a listing
+// filtered by either visits only the matching books. It is the storage of
+// BookRepository and of each shard of ShardedBookRepository, which lock it;
+// it is not safe for concurrent use itself.
+//
+// Stored books are never modified: a write replaces the entry's book. A
+// *domain.Book taken from an entry under the lock can therefore be read after
+// the lock is released, though the entry cannot, and must be copied before it
+// is handed out.
+type bookSet struct {
+	books    map[string]*bookEntry
+	order    *list.List // of *bookEntry, in insertion order
+	byAuthor bookIndex[string]
+	byYear   bookIndex[int]
+}
+
+func newBookSet() bookSet {
+	return bookSet{
+		books:    make(map[string]*bookEntry),
+		order:    list.New(),
+		byAuthor: make(bookIndex[string]),
+		byYear:   make(bookIndex[int]),
+	}
+}
+
+// create stores a copy of book with sequence number seq, which must exceed
+// that of every stored book, replacing any book with its ID. It returns the
+// stored book.
+func (s *bookSet) create(book *domain.Book, seq uint64) *domain.Book {
+	if e, ok := s.books[book.ID]; ok {
+		s.remove(e)
+	}
+	book = copyBook(book)
+	e := &bookEntry{book: book, seq: seq}
+	e.elem = s.order.PushBack(e)
+	s.books[book.ID] = e
+	s.byAuthor.add(book.Author, e)
+	s.byYear.add(book.Year, e)
+	return book
+}
+
+// get returns the stored live book with the given ID.
+func (s *bookSet) get(id string) (*domain.Book, error) {
+	e, ok := s.books[id]
+	if !ok || e.book.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	return e.book, nil
+}
+
+// update replaces a live book with a copy of book and returns the copy. The
+// trash state is owned by softDelete and restore, and the rating by rate, so
+// the copy keeps the stored ones.
+func (s *bookSet) update(book *domain.Book) (*domain.Book, error) {
+	e, ok := s.books[book.ID]
+	if !ok || e.book.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	if book.DeletedAt != nil {
+		return nil, domain.ErrInvalidData
+	}
+	book = copyBook(book)
+	book.AverageRating = e.book.AverageRating
+	book.ReviewCount = e.book.ReviewCount
+	s.replace(e, book)
+	return book, nil
+}
+
+// rate replaces a book, trashed or not, with a copy having the given rating.
+func (s *bookSet) rate(id string, rating domain.RatingSummary) error {
+	e, ok := s.books[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	rated := *e.book
+	rated.AverageRating = rating.Average
+	rated.ReviewCount = rating.Count
+	s.replace(e, &rated)
+	return nil
+}
+
+// softDelete replaces a live book with a trashed copy and returns it.
+func (s *bookSet) softDelete(id, deletedBy string, at time.Time) (*domain.Book, error) {
+	e, ok := s.books[id]
+	if !ok || e.book.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	trashed := *e.book
+	trashed.DeletedAt = &at
+	trashed.DeletedBy = deletedBy
+	s.replace(e, &trashed)
+	return &trashed, nil
+}
+
+// restore replaces a trashed book with a live copy and returns it.
+func (s *bookSet) restore(id string) (*domain.Book, error) {
+	e, ok := s.books[id]
+	if !ok || e.book.DeletedAt == nil {
+		return nil, domain.ErrNotFound
+	}
+	restored := *e.book
+	restored.DeletedAt = nil
+	restored.DeletedBy = ""
+	s.replace(e, &restored)
+	return &restored, nil
+}
+
+// delete removes a book, trashed or not. O(1).
+func (s *bookSet) delete(id string) error {
+	e, ok := s.books[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	s.remove(e)
+	return nil
+}
+
+// purge removes every book trashed before the cutoff and returns their
+// entries in insertion order. No longer stored, the entries may be read once
+// the lock is released. O(n).
+func (s *bookSet) purge(before time.Time) []*bookEntry {
+	var purged []*bookEntry
+	for el := s.order.Front(); el != nil; {
+		e := el.Value.(*bookEntry)
+		el = el.Next()
+		if e.book.DeletedAt != nil && e.book.DeletedAt.Before(before) {
+			s.remove(e)
+			purged = append(purged, e)
+		}
+	}
+	return purged
+}
+
+// replace stores book in e, keeping its place in the insertion order and
+// moving it between index sets when its author or year changed.
+func (s *bookSet) replace(e *bookEntry, book *domain.Book) {
+	old := e.book
+	e.book = book
+	if book.Author != old.Author {
+		s.byAuthor.remove(old.Author, old.ID)
+		s.byAuthor.add(book.Author, e)
+	}
+	if book.Year != old.Year {
+		s.byYear.remove(old.Year, old.ID)
+		s.byYear.add(book.Year, e)
+	}
+}
+
+// remove deletes e from the books, the insertion order and the indexes. O(1).
+func (s *bookSet) remove(e *bookEntry) {
+	s.order.Remove(e.elem)
+	delete(s.books, e.book.ID)
+	s.byAuthor.remove(e.book.Author, e.book.ID)
+	s.byYear.remove(e.book.Year, e.book.ID)
+}
+
+// candidates returns the entries by author in year, either of which may be
+// unset, from the smaller of the matching index sets; it may hold entries
+// matching only one of them. all reports that neither is set, so that every
+// entry is a candidate and set is nil.
+func (s *bookSet) candidates(author string, year int) (set map[string]*bookEntry, all bool) {
+	switch {
+	case author != "" && year != 0:
+		set = s.byAuthor[author]
+		if ys := s.byYear[year]; len(ys) < len(set) {
+			set = ys
+		}
+	case author != "":
+		set = s.byAuthor[author]
+	case year != 0:
+		set = s.byYear[year]
+	default:
+		return nil, true
+	}
+	return set, false
+}
+
+// each calls fn for every entry, trashed or not, by author in year, either of
+// which may be unset, in insertion order. Unfiltered, it walks the insertion
+// order: O(n). Filtered, it visits only the candidates and sorts the matches
+// back into insertion order: O(k log k) for k candidates.
+func (s *bookSet) each(author string, year int, fn func(*bookEntry)) {
+	set, all := s.candidates(author, year)
+	if all {
+		for el := s.order.Front(); el != nil; el = el.Next() {
+			fn(el.Value.(*bookEntry))
+		}
+		return
+	}
+	matches := make([]*bookEntry, 0, len(set))
+	for _, e := range set {
+		if (author == "" || e.book.Author == author) && (year == 0 || e.book.Year == year) {
+			matches = append(matches, e)
+		}
+	}
+	slices.SortFunc(matches, func(a, b *bookEntry) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	for _, e := range matches {
+		fn(e)
+	}
+}
+
+// size returns how many candidates each would visit for author and year.
+func (s *bookSet) size(author string, year int) int {
+	if set, all := s.candidates(author, year); !all {
+		return len(set)
+	}
+	return s.order.Len()
+}
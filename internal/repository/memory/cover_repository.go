@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// CoverRepository is a thread-safe, in-memory implementation of domain.CoverRepository.
+type CoverRepository struct {
+	mu     sync.RWMutex
+	covers map[string]*domain.Cover // bookID -> cover
+}
+
+// NewCoverRepository creates and returns an initialised CoverRepository.
+func NewCoverRepository() *CoverRepository {
+	return &CoverRepository{covers: make(map[string]*domain.Cover)}
+}
+
+// Save stores or replaces the cover of cover.BookID.
+func (r *CoverRepository) Save(cover *domain.Cover) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.covers[cover.BookID] = cover
+	return nil
+}
+
+// Get returns a book's cover. Returns domain.ErrNotFound if the book has none.
+func (r *CoverRepository) Get(bookID string) (*domain.Cover, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cover, ok := r.covers[bookID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return cover, nil
+}
+
+// RemoveBook forgets the cover of a deleted book. The blobs stay in the blob
+// store since other books may share identical content.
+func (r *CoverRepository) RemoveBook(bookID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.covers, bookID)
+	return nil
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// idempotencyKey identifies a record: keys are scoped to their owner.
+type idempotencyKey struct {
+	owner, key string
+}
+
+// IdempotencyRepository is a thread-safe, in-memory implementation of
+// domain.IdempotencyRepository.
+type IdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[idempotencyKey]*domain.IdempotencyRecord
+}
+
+// NewIdempotencyRepository creates and returns an empty IdempotencyRepository.
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{records: make(map[idempotencyKey]*domain.IdempotencyRecord)}
+}
+
+// Reserve stores a copy of rec unless a live record exists for its owner and
+// key, in which case it returns a copy of that record.
+func (r *IdempotencyRepository) Reserve(rec *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := idempotencyKey{rec.Owner, rec.Key}
+	if existing, ok := r.records[k]; ok && now.Before(existing.ExpiresAt) {
+		return copyIdempotencyRecord(existing), nil
+	}
+	r.records[k] = copyIdempotencyRecord(rec)
+	return nil, nil
+}
+
+// Complete attaches a copy of resp to a record. Returns domain.ErrNotFound if
+// there is no such record.
+func (r *IdempotencyRepository) Complete(owner, key string, resp domain.IdempotentResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, ok := r.records[idempotencyKey{owner, key}]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	resp.Body = append([]byte(nil), resp.Body...)
+	rec.Response = &resp
+	return nil
+}
+
+// Release removes the stored record if it has rec's Fingerprint and
+// CreatedAt. Releasing an unknown or replaced reservation is not an error.
+func (r *IdempotencyRepository) Release(rec *domain.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := idempotencyKey{rec.Owner, rec.Key}
+	if stored, ok := r.records[k]; ok && stored.Fingerprint == rec.Fingerprint && stored.CreatedAt.Equal(rec.CreatedAt) {
+		delete(r.records, k)
+	}
+	return nil
+}
+
+// PurgeExpired removes records that expired before now. O(n).
+func (r *IdempotencyRepository) PurgeExpired(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for k, rec := range r.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(r.records, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func copyIdempotencyRecord(rec *domain.IdempotencyRecord) *domain.IdempotencyRecord {
+	c := *rec
+	if rec.Response != nil {
+		resp := *rec.Response
+		resp.Body = append([]byte(nil), resp.Body...)
+		c.Response = &resp
+	}
+	return &c
+}
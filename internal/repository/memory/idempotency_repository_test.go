@@ -0,0 +1,82 @@
+package memory_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// TestIdempotencyReserve verifies that of concurrent reservations of a key
+// exactly one succeeds, and that an expired record no longer holds the key.
+func TestIdempotencyReserve(t *testing.T) {
+	repo := memory.NewIdempotencyRepository()
+	now := time.Now()
+	rec := func(fingerprint string) *domain.IdempotencyRecord {
+		return &domain.IdempotencyRecord{Owner: "ann", Key: "k", Fingerprint: fingerprint, ExpiresAt: now.Add(time.Hour)}
+	}
+
+	var reserved atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if existing, err := repo.Reserve(rec("a"), now); err == nil && existing == nil {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := reserved.Load(); n != 1 {
+		t.Fatalf("%d reservations succeeded, want 1", n)
+	}
+
+	_ = repo.Complete("ann", "k", domain.IdempotentResponse{Status: 201, Body: []byte("done")})
+	existing, _ := repo.Reserve(rec("b"), now)
+	if existing == nil || existing.Fingerprint != "a" || existing.Response == nil || string(existing.Response.Body) != "done" {
+		t.Fatalf("Reserve of a held key: got %+v", existing)
+	}
+
+	if n, _ := repo.PurgeExpired(now); n != 0 {
+		t.Errorf("PurgeExpired before expiry removed %d", n)
+	}
+	later := now.Add(time.Hour)
+	if existing, _ := repo.Reserve(rec("b"), later); existing != nil {
+		t.Errorf("Reserve of an expired key: got %+v, want success", existing)
+	}
+	if n, _ := repo.PurgeExpired(later.Add(time.Hour)); n != 1 {
+		t.Errorf("PurgeExpired after expiry removed %d, want 1", n)
+	}
+}
+
+// TestIdempotencyReleaseKeepsNewerReservation verifies that releasing an
+// expired reservation does not remove the one that replaced it.
+func TestIdempotencyReleaseKeepsNewerReservation(t *testing.T) {
+	repo := memory.NewIdempotencyRepository()
+	now := time.Now()
+	old := &domain.IdempotencyRecord{Owner: "ann", Key: "k", Fingerprint: "a", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	repo.Reserve(old, now)
+
+	later := now.Add(2 * time.Hour)
+	newer := &domain.IdempotencyRecord{Owner: "ann", Key: "k", Fingerprint: "a", CreatedAt: later, ExpiresAt: later.Add(time.Hour)}
+	if existing, _ := repo.Reserve(newer, later); existing != nil {
+		t.Fatalf("Reserve after expiry: got %+v, want success", existing)
+	}
+	if err := repo.Release(old); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if existing, _ := repo.Reserve(newer, later); existing == nil || !existing.CreatedAt.Equal(later) {
+		t.Fatalf("after releasing the old reservation: got %+v, want the newer one", existing)
+	}
+
+	if err := repo.Release(newer); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if existing, _ := repo.Reserve(newer, later); existing != nil {
+		t.Errorf("after releasing the newer reservation: got %+v, want the key free", existing)
+	}
+}
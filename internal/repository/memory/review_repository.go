@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"math"
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// ReviewRepository is a thread-safe, in-memory implementation of domain.ReviewRepository.
+// Reviews are indexed by book so that listing and aggregating a book's reviews
+// never scans unrelated data.
+type ReviewRepository struct {
+	mu       sync.RWMutex
+	reviews  map[string]*domain.Review
+	byBook   map[string][]string // bookID -> review IDs in creation order
+	byAuthor map[string]string   // bookID + "\x00" + username -> review ID
+}
+
+// NewReviewRepository creates and returns an initialised ReviewRepository.
+func NewReviewRepository() *ReviewRepository {
+	return &ReviewRepository{
+		reviews:  make(map[string]*domain.Review),
+		byBook:   make(map[string][]string),
+		byAuthor: make(map[string]string),
+	}
+}
+
+func authorKey(bookID, username string) string {
+	return bookID + "\x00" + username
+}
+
+// Create stores a new review. Returns domain.ErrConflict if the user has
+// already reviewed the book.
+func (r *ReviewRepository) Create(review *domain.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := authorKey(review.BookID, review.Username)
+	if _, exists := r.byAuthor[key]; exists {
+		return domain.ErrConflict
+	}
+	r.reviews[review.ID] = review
+	r.byBook[review.BookID] = append(r.byBook[review.BookID], review.ID)
+	r.byAuthor[key] = review.ID
+	return nil
+}
+
+// GetByID returns a single review by ID. Returns domain.ErrNotFound if absent.
+func (r *ReviewRepository) GetByID(id string) (*domain.Review, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	review, ok := r.reviews[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return review, nil
+}
+
+// ListByBook returns every review of a book in creation order.
+func (r *ReviewRepository) ListByBook(bookID string) ([]*domain.Review, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.byBook[bookID]
+	out := make([]*domain.Review, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, r.reviews[id])
+	}
+	return out, nil
+}
+
+// ListByBooks returns the reviews of each of bookIDs under a single lock
+// acquisition, so a batch sees one consistent state.
+func (r *ReviewRepository) ListByBooks(bookIDs []string) (map[string][]*domain.Review, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string][]*domain.Review, len(bookIDs))
+	for _, bookID := range bookIDs {
+		ids := r.byBook[bookID]
+		if len(ids) == 0 {
+			continue
+		}
+		reviews := make([]*domain.Review, len(ids))
+		for i, id := range ids {
+			reviews[i] = r.reviews[id]
+		}
+		out[bookID] = reviews
+	}
+	return out, nil
+}
+
+// Update replaces the stored review. Returns domain.ErrNotFound if the ID is absent.
+// The book and author of a review are immutable.
+func (r *ReviewRepository) Update(review *domain.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.reviews[review.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	if existing.BookID != review.BookID || existing.Username != review.Username {
+		return domain.ErrInvalidData
+	}
+	r.reviews[review.ID] = review
+	return nil
+}
+
+// Delete removes a review by ID. Returns domain.ErrNotFound if the ID is absent.
+func (r *ReviewRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	review, ok := r.reviews[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.reviews, id)
+	delete(r.byAuthor, authorKey(review.BookID, review.Username))
+
+	ids := r.byBook[review.BookID]
+	for i, rid := range ids {
+		if rid == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(r.byBook, review.BookID)
+	} else {
+		r.byBook[review.BookID] = ids
+	}
+	return nil
+}
+
+// RemoveBook deletes every review of a book, so that none outlives a purged
+// book. A book without reviews is not an error.
+func (r *ReviewRepository) RemoveBook(bookID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range r.byBook[bookID] {
+		review := r.reviews[id]
+		delete(r.reviews, id)
+		delete(r.byAuthor, authorKey(review.BookID, review.Username))
+	}
+	delete(r.byBook, bookID)
+	return nil
+}
+
+// Summary computes the average rating (rounded to two decimals) and review
+// count for a book. A book without reviews yields a zero summary.
+func (r *ReviewRepository) Summary(bookID string) (domain.RatingSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.byBook[bookID]
+	if len(ids) == 0 {
+		return domain.RatingSummary{}, nil
+	}
+	sum := 0
+	for _, id := range ids {
+		sum += r.reviews[id].Rating
+	}
+	avg := float64(sum) / float64(len(ids))
+	return domain.RatingSummary{
+		Average: math.Round(avg*100) / 100,
+		Count:   len(ids),
+	}, nil
+}
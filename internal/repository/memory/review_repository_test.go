@@ -0,0 +1,103 @@
+package memory_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// TestReviewOnePerUser verifies that concurrent reviews of the same book by the
+// same user result in exactly one stored review.
+func TestReviewOnePerUser(t *testing.T) {
+	const n = 50
+	repo := memory.NewReviewRepository()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		conflicts int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := repo.Create(&domain.Review{ID: fmt.Sprintf("r-%d", i), BookID: "b", Username: "alice", Rating: 3})
+			if err == domain.ErrConflict {
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if conflicts != n-1 {
+		t.Errorf("expected %d conflicts, got %d", n-1, conflicts)
+	}
+	reviews, _ := repo.ListByBook("b")
+	if len(reviews) != 1 {
+		t.Errorf("expected 1 review, got %d", len(reviews))
+	}
+}
+
+// TestReviewSummary verifies the aggregate is recomputed after updates and deletes.
+func TestReviewSummary(t *testing.T) {
+	repo := memory.NewReviewRepository()
+	for i, rating := range []int{5, 4, 4} {
+		_ = repo.Create(&domain.Review{ID: fmt.Sprintf("r-%d", i), BookID: "b", Username: fmt.Sprintf("u%d", i), Rating: rating})
+	}
+
+	got, _ := repo.Summary("b")
+	if got.Count != 3 || got.Average != 4.33 {
+		t.Errorf("summary: got %+v, want {Average:4.33 Count:3}", got)
+	}
+
+	_ = repo.Update(&domain.Review{ID: "r-1", BookID: "b", Username: "u1", Rating: 1})
+	_ = repo.Delete("r-2")
+
+	got, _ = repo.Summary("b")
+	if got.Count != 2 || got.Average != 3 {
+		t.Errorf("summary after changes: got %+v, want {Average:3 Count:2}", got)
+	}
+
+	// The user whose review was deleted may review again.
+	if err := repo.Create(&domain.Review{ID: "r-3", BookID: "b", Username: "u2", Rating: 2}); err != nil {
+		t.Errorf("re-review after delete: %v", err)
+	}
+
+	empty, _ := repo.Summary("missing")
+	if empty != (domain.RatingSummary{}) {
+		t.Errorf("summary of unreviewed book: got %+v, want zero", empty)
+	}
+}
+
+// TestReviewListByBooks verifies a batch lookup matches per-book lookups and
+// leaves out books without reviews.
+func TestReviewListByBooks(t *testing.T) {
+	repo := memory.NewReviewRepository()
+	for i, bookID := range []string{"a", "b", "a", "c", "a"} {
+		_ = repo.Create(&domain.Review{ID: fmt.Sprintf("r-%d", i), BookID: bookID, Username: fmt.Sprintf("u%d", i), Rating: 3})
+	}
+
+	got, err := repo.ListByBooks([]string{"a", "c", "missing"})
+	if err != nil {
+		t.Fatalf("ListByBooks: %v", err)
+	}
+	if _, ok := got["missing"]; ok || len(got) != 2 {
+		t.Errorf("keys: got %v, want a and c", got)
+	}
+	for _, bookID := range []string{"a", "c"} {
+		want, _ := repo.ListByBook(bookID)
+		if len(got[bookID]) != len(want) {
+			t.Fatalf("book %s: got %d reviews, want %d", bookID, len(got[bookID]), len(want))
+		}
+		for i := range want {
+			if got[bookID][i].ID != want[i].ID {
+				t.Errorf("book %s review %d: got %s, want %s", bookID, i, got[bookID][i].ID, want[i].ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// RevisionRepository is a thread-safe, append-only, in-memory implementation
+// of domain.RevisionRepository. Stored revisions are deep copies and are never
+// modified after Append.
+type RevisionRepository struct {
+	mu     sync.RWMutex
+	byBook map[string][]*domain.Revision // bookID -> revisions, index = number-1
+}
+
+// NewRevisionRepository creates and returns an initialised RevisionRepository.
+func NewRevisionRepository() *RevisionRepository {
+	return &RevisionRepository{byBook: make(map[string][]*domain.Revision)}
+}
+
+// Append numbers rev and stores a copy of it.
+func (r *RevisionRepository) Append(rev *domain.Revision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev.Number = len(r.byBook[rev.BookID]) + 1
+	stored := copyRevision(rev)
+	r.byBook[rev.BookID] = append(r.byBook[rev.BookID], stored)
+	return nil
+}
+
+// List returns a book's revisions, oldest first. Returns domain.ErrNotFound if
+// the book has no history.
+func (r *RevisionRepository) List(bookID string) ([]*domain.Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revs, ok := r.byBook[bookID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	out := make([]*domain.Revision, len(revs))
+	for i, rev := range revs {
+		out[i] = copyRevision(rev)
+	}
+	return out, nil
+}
+
+// Get returns a single revision. Returns domain.ErrNotFound if absent.
+func (r *RevisionRepository) Get(bookID string, number int) (*domain.Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revs := r.byBook[bookID]
+	if number < 1 || number > len(revs) {
+		return nil, domain.ErrNotFound
+	}
+	return copyRevision(revs[number-1]), nil
+}
+
+func copyRevision(rev *domain.Revision) *domain.Revision {
+	c := *rev
+	c.Before = copyBook(rev.Before)
+	c.After = copyBook(rev.After)
+	return &c
+}
+
+func copyBook(b *domain.Book) *domain.Book {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	c.Tags = slices.Clone(b.Tags)
+	if b.DeletedAt != nil {
+		at := *b.DeletedAt
+		c.DeletedAt = &at
+	}
+	return &c
+}
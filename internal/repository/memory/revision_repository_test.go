@@ -0,0 +1,56 @@
+package memory_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// TestRevisionNumbering verifies concurrent appends receive distinct,
+// gap-free numbers per book.
+func TestRevisionNumbering(t *testing.T) {
+	const n = 100
+	repo := memory.NewRevisionRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = repo.Append(&domain.Revision{BookID: "b", Action: domain.RevisionUpdate})
+		}()
+	}
+	wg.Wait()
+
+	revs, err := repo.List("b")
+	if err != nil || len(revs) != n {
+		t.Fatalf("List: got %d revisions, %v; want %d", len(revs), err, n)
+	}
+	for i, rev := range revs {
+		if rev.Number != i+1 {
+			t.Fatalf("revision %d has number %d", i, rev.Number)
+		}
+	}
+}
+
+// TestRevisionsAreImmutable verifies that neither the appended book nor a
+// returned revision can be used to rewrite history.
+func TestRevisionsAreImmutable(t *testing.T) {
+	repo := memory.NewRevisionRepository()
+	book := newBook(1)
+	_ = repo.Append(&domain.Revision{BookID: book.ID, Action: domain.RevisionCreate, After: book})
+
+	book.Title = "mutated after append"
+	got, _ := repo.Get(book.ID, 1)
+	got.After.Author = "mutated after get"
+
+	again, _ := repo.Get(book.ID, 1)
+	if again.After.Title != "Title 1" || again.After.Author != "Author 1" {
+		t.Errorf("stored revision changed: %+v", again.After)
+	}
+	if _, err := repo.Get(book.ID, 2); err != domain.ErrNotFound {
+		t.Errorf("Get missing revision: want ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"cmp"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// DefaultBookShards is the number of shards of a ShardedBookRepository when
+// none is given.
+const DefaultBookShards = 16
+
+// ShardedBookRepository is an in-memory domain.BookRepository for write-heavy
+// workloads. Books are partitioned by a hash of their ID over shards that
+// each have their own lock, so writes to books in different shards do not
+// wait for each other, and a listing holds one shard's lock at a time.
+//
+// Every book takes a number from a global sequence as it is inserted, under
+// its shard's lock, so each shard holds its books in sequence order. Listings
+// merge the shards by sequence number and so return books in insertion order,
+// as BookRepository does. A listing, PurgeTrashed and Stats see each shard at
+// a slightly different moment: a write to a shard already visited is missed.
+//
+// Books are copied on the way in and on the way out, as by BookRepository. It
+// also implements domain.BookOutbox: events are recorded under the shard lock
+// of their write, so the events of each book are in commit order.
+type ShardedBookRepository struct {
+	seed   maphash.Seed
+	seq    atomic.Uint64 // sequence number of the last inserted book
+	shards []bookShard
+	bookOutbox
+}
+
+type bookShard struct {
+	mu sync.RWMutex
+	bookSet
+	_ [64]byte // keeps the locks of neighbouring shards on separate cache lines
+}
+
+// NewShardedBookRepository creates a repository with n shards, or
+// DefaultBookShards if n is not positive.
+func NewShardedBookRepository(n int) *ShardedBookRepository {
+	if n <= 0 {
+		n = DefaultBookShards
+	}
+	r := &ShardedBookRepository{
+		seed:       maphash.MakeSeed(),
+		shards:     make([]bookShard, n),
+		bookOutbox: newBookOutbox(),
+	}
+	for i := range r.shards {
+		r.shards[i].bookSet = newBookSet()
+	}
+	return r
+}
+
+// shard returns the shard holding the book with the given ID.
+func (r *ShardedBookRepository) shard(id string) *bookShard {
+	return &r.shards[maphash.String(r.seed, id)%uint64(len(r.shards))]
+}
+
+// Create stores a copy of a new book. O(1) amortised.
+func (r *ShardedBookRepository) Create(book *domain.Book, events ...domain.BookEvent) error {
+	s := r.shard(book.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.record(s.create(book, r.seq.Add(1)), events)
+	return nil
+}
+
+// GetByID returns a copy of a single book by ID. Returns domain.ErrNotFound
+// if absent or trashed.
+func (r *ShardedBookRepository) GetByID(id string) (*domain.Book, error) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	book, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyBook(book), nil
+}
+
+// GetAll returns books matching the filter, in insertion order unless sorted,
+// plus the total count before pagination. Each shard is filtered as by
+// BookRepository.GetAll, then the shards are merged: O(k log s) for k
+// matching books in s shards.
+func (r *ShardedBookRepository) GetAll(filter domain.BookFilter) ([]*domain.Book, int, error) {
+	books, total := page(r.list(filter), filter, nil)
+	return books, total, nil
+}
+
+// GetAllFaceted is GetAll counting the facets of the matching books.
+func (r *ShardedBookRepository) GetAllFaceted(filter domain.BookFilter) ([]*domain.Book, int, domain.BookFacets, error) {
+	counts := newFacetCounts()
+	books, total := page(r.list(filter), filter, &counts)
+	return books, total, counts.facets(), nil
+}
+
+// sequenced is a stored book with its sequence number, taken under the lock
+// of its shard.
+type sequenced struct {
+	book *domain.Book
+	seq  uint64
+}
+
+// list returns the stored books matching filter, in insertion order.
+func (r *ShardedBookRepository) list(filter domain.BookFilter) []*domain.Book {
+	match := matcher(filter)
+	runs := make([][]sequenced, len(r.shards))
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		run := make([]sequenced, 0, s.size(filter.Author, filter.Year))
+		s.each(filter.Author, filter.Year, func(e *bookEntry) {
+			if match(e.book) {
+				run = append(run, sequenced{e.book, e.seq})
+			}
+		})
+		s.mu.RUnlock()
+		runs[i] = run
+	}
+
+	return mergeRuns(runs)
+}
+
+// mergeRuns merges runs, each ordered by sequence number, into one slice of
+// books in sequence order. The runs are kept in a binary min-heap by the
+// sequence number of their first book: O(n log r) for n books in r runs.
+func mergeRuns(runs [][]sequenced) []*domain.Book {
+	h := make([][]sequenced, 0, len(runs))
+	n := 0
+	for _, run := range runs {
+		if len(run) > 0 {
+			h = append(h, run)
+			n += len(run)
+		}
+	}
+	for i := len(h)/2 - 1; i >= 0; i-- {
+		siftDown(h, i)
+	}
+	books := make([]*domain.Book, 0, n)
+	for len(h) > 0 {
+		books = append(books, h[0][0].book)
+		if h[0] = h[0][1:]; len(h[0]) == 0 {
+			h[0] = h[len(h)-1]
+			h = h[:len(h)-1]
+		}
+		siftDown(h, 0)
+	}
+	return books
+}
+
+// siftDown moves h[i] down the min-heap h of non-empty runs ordered by the
+// sequence number of their first book.
+func siftDown(h [][]sequenced, i int) {
+	for {
+		least := i
+		if l := 2*i + 1; l < len(h) && h[l][0].seq < h[least][0].seq {
+			least = l
+		}
+		if r := 2*i + 2; r < len(h) && h[r][0].seq < h[least][0].seq {
+			least = r
+		}
+		if least == i {
+			return
+		}
+		h[i], h[least] = h[least], h[i]
+		i = least
+	}
+}
+
+// Update replaces the stored book with a copy of book. Returns
+// domain.ErrNotFound if the ID is absent or trashed. The trash state is owned
+// by SoftDelete and Restore, and the rating by UpdateRating, so the stored
+// ones are kept.
+func (r *ShardedBookRepository) Update(book *domain.Book, events ...domain.BookEvent) error {
+	s := r.shard(book.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.update(book)
+	if err != nil {
+		return err
+	}
+	r.record(stored, events)
+	return nil
+}
+
+// UpdateRating sets the rating of a book, trashed or not. Returns
+// domain.ErrNotFound if the ID is absent.
+func (r *ShardedBookRepository) UpdateRating(id string, rating domain.RatingSummary) error {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rate(id, rating)
+}
+
+// SoftDelete moves a book to the trash. Returns domain.ErrNotFound if the ID is
+// absent or already trashed.
+func (r *ShardedBookRepository) SoftDelete(id, deletedBy string, at time.Time, events ...domain.BookEvent) error {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trashed, err := s.softDelete(id, deletedBy, at)
+	if err != nil {
+		return err
+	}
+	r.record(trashed, events)
+	return nil
+}
+
+// Restore takes a book out of the trash. Returns domain.ErrNotFound if the ID
+// is absent or not trashed.
+func (r *ShardedBookRepository) Restore(id string, events ...domain.BookEvent) error {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored, err := s.restore(id)
+	if err != nil {
+		return err
+	}
+	r.record(restored, events)
+	return nil
+}
+
+// PurgeTrashed permanently deletes every book trashed before the cutoff, one
+// shard at a time, and returns their IDs in insertion order. Within a shard
+// a concurrent Restore either wins or loses as a whole. O(n).
+func (r *ShardedBookRepository) PurgeTrashed(before time.Time) ([]string, error) {
+	var purged []sequenced
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.Lock()
+		for _, e := range s.purge(before) {
+			purged = append(purged, sequenced{e.book, e.seq})
+		}
+		s.mu.Unlock()
+	}
+	slices.SortFunc(purged, func(a, b sequenced) int { return cmp.Compare(a.seq, b.seq) })
+
+	var ids []string
+	for _, p := range purged {
+		ids = append(ids, p.book.ID)
+	}
+	return ids, nil
+}
+
+// Delete permanently removes a book by ID, trashed or not. Returns
+// domain.ErrNotFound if the ID is absent. O(1).
+func (r *ShardedBookRepository) Delete(id string) error {
+	s := r.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delete(id)
+}
+
+// Stats aggregates the live books matching query, one shard at a time under
+// its read lock, then orders the groups. O(n + g log g) for g groups.
+func (r *ShardedBookRepository) Stats(query domain.BookStatsQuery) ([]domain.BookGroup, error) {
+	groups, ok := newBookGroups(query.GroupBy)
+	if !ok {
+		return nil, domain.ErrInvalidData
+	}
+
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		s.each(query.Author, 0, groups.add)
+		s.mu.RUnlock()
+	}
+	return groups.sorted(), nil
+}
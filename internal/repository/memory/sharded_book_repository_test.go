@@ -0,0 +1,134 @@
+package memory_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// TestShardedMatchesBookRepository applies the same random writes to a
+// ShardedBookRepository and a BookRepository and verifies that they answer
+// every read alike, insertion order included.
+func TestShardedMatchesBookRepository(t *testing.T) {
+	sharded, plain := memory.NewShardedBookRepository(3), memory.NewBookRepository()
+	rng := rand.New(rand.NewPCG(1, 2))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	authors := []string{"Austen", "Herbert", "Le Guin"}
+	tags := []string{"classic", "science fiction"}
+
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("book-%d", rng.IntN(150))
+		book := &domain.Book{
+			ID: id, Title: fmt.Sprintf("Title %d", i), Author: authors[rng.IntN(len(authors))],
+			Year: 1960 + rng.IntN(4), ReviewCount: rng.IntN(5), CreatedAt: start.Add(time.Duration(i) * time.Hour),
+			Tags: tags[:rng.IntN(len(tags)+1)],
+		}
+		at := start.Add(time.Duration(i) * time.Minute)
+		var errS, errP error
+		switch op := rng.IntN(10); {
+		case op < 4:
+			errS, errP = sharded.Create(book), plain.Create(book)
+		case op < 6:
+			errS, errP = sharded.Update(book), plain.Update(book)
+		case op < 8:
+			errS, errP = sharded.SoftDelete(id, "alice", at), plain.SoftDelete(id, "alice", at)
+		case op == 8:
+			errS, errP = sharded.Restore(id), plain.Restore(id)
+		default:
+			errS, errP = sharded.Delete(id), plain.Delete(id)
+		}
+		if errS != errP {
+			t.Fatalf("write %d to %s: sharded %v, plain %v", i, id, errS, errP)
+		}
+	}
+
+	listing := func(books []*domain.Book, total int, facets domain.BookFacets, err error) string {
+		var ids []string
+		for _, b := range books {
+			ids = append(ids, b.ID+"@"+b.Title)
+		}
+		return fmt.Sprint(total, ids, facets, err)
+	}
+	for _, filter := range []domain.BookFilter{
+		{},
+		{Trash: domain.IncludeTrashed},
+		{Trash: domain.OnlyTrashed, Page: 2, Limit: 5},
+		{Author: "Herbert"},
+		{Year: 1961, Sort: "-" + domain.SortByReviews},
+		{Author: "Austen", Year: 1962, Trash: domain.IncludeTrashed},
+		{Page: 3, Limit: 7, Sort: domain.SortByReviews},
+		{Tag: "science fiction"},
+		{Query: "herbert TITLE 1", Trash: domain.IncludeTrashed},
+	} {
+		got, want := listing(sharded.GetAllFaceted(filter)), listing(plain.GetAllFaceted(filter))
+		if got != want {
+			t.Errorf("GetAllFaceted(%+v):\n sharded %s\n plain   %s", filter, got, want)
+		}
+	}
+	for _, groupBy := range []string{domain.GroupByAuthor, domain.GroupByYear, domain.GroupByDecade, domain.GroupByCreatedMonth} {
+		got, errS := sharded.Stats(domain.BookStatsQuery{GroupBy: groupBy})
+		want, errP := plain.Stats(domain.BookStatsQuery{GroupBy: groupBy})
+		if fmt.Sprint(got, errS) != fmt.Sprint(want, errP) {
+			t.Errorf("Stats by %s: sharded %v, plain %v", groupBy, got, want)
+		}
+	}
+
+	cutoff := start.Add(1500 * time.Minute)
+	got, _ := sharded.PurgeTrashed(cutoff)
+	want, _ := plain.PurgeTrashed(cutoff)
+	if len(want) == 0 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PurgeTrashed: sharded %v, plain %v", got, want)
+	}
+}
+
+// TestShardedConcurrentWrites verifies that concurrent writers to every shard
+// lose nothing, that each writer's books come back in the order it created
+// them, and that every write records its event (run with -race).
+func TestShardedConcurrentWrites(t *testing.T) {
+	const writers, perWriter = 8, 50
+	repo := memory.NewShardedBookRepository(4)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				b := newBook(w*perWriter + i)
+				b.Author = fmt.Sprintf("Writer %d", w)
+				if err := repo.Create(b, domain.BookEvent{Type: domain.EventBookCreated}); err != nil {
+					t.Errorf("Create: %v", err)
+				}
+				_, _, _ = repo.GetAll(domain.BookFilter{Page: 1, Limit: 10})
+				b.Title = "Updated"
+				if err := repo.Update(b, domain.BookEvent{Type: domain.EventBookUpdated}); err != nil {
+					t.Errorf("Update: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, total, _ := repo.GetAll(domain.BookFilter{}); total != writers*perWriter {
+		t.Errorf("total: got %d, want %d", total, writers*perWriter)
+	}
+	for w := 0; w < writers; w++ {
+		books, _, _ := repo.GetAll(domain.BookFilter{Author: fmt.Sprintf("Writer %d", w)})
+		if len(books) != perWriter {
+			t.Fatalf("writer %d: got %d books, want %d", w, len(books), perWriter)
+		}
+		for i, b := range books {
+			if want := fmt.Sprintf("book-%d", w*perWriter+i); b.ID != want || b.Title != "Updated" {
+				t.Fatalf("writer %d, book %d: got %s %q, want %s \"Updated\"", w, i, b.ID, b.Title, want)
+			}
+		}
+	}
+	if pending, _ := repo.Pending(0); len(pending) != 2*writers*perWriter {
+		t.Errorf("outbox: got %d events, want %d", len(pending), 2*writers*perWriter)
+	}
+}
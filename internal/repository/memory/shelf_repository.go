@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// ShelfRepository is a thread-safe, in-memory implementation of domain.ShelfRepository.
+// Shelves are copied on the way in and on the way out, as books are by
+// BookRepository, so a caller can never modify a stored shelf outside the lock.
+type ShelfRepository struct {
+	mu      sync.RWMutex
+	shelves map[string]*domain.Shelf
+	byOwner map[string][]string // owner -> shelf IDs in creation order
+	byToken map[string]string   // share token -> shelf ID
+}
+
+// NewShelfRepository creates and returns an initialised ShelfRepository.
+func NewShelfRepository() *ShelfRepository {
+	return &ShelfRepository{
+		shelves: make(map[string]*domain.Shelf),
+		byOwner: make(map[string][]string),
+		byToken: make(map[string]string),
+	}
+}
+
+// Create stores a new shelf. Returns domain.ErrConflict if the owner already
+// has a shelf with the same name.
+func (r *ShelfRepository) Create(shelf *domain.Shelf) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range r.byOwner[shelf.Owner] {
+		if r.shelves[id].Name == shelf.Name {
+			return domain.ErrConflict
+		}
+	}
+	r.shelves[shelf.ID] = copyShelf(shelf)
+	r.byOwner[shelf.Owner] = append(r.byOwner[shelf.Owner], shelf.ID)
+	if shelf.ShareToken != "" {
+		r.byToken[shelf.ShareToken] = shelf.ID
+	}
+	return nil
+}
+
+// GetByID returns a copy of a single shelf by ID. Returns domain.ErrNotFound
+// if absent.
+func (r *ShelfRepository) GetByID(id string) (*domain.Shelf, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shelf, ok := r.shelves[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return copyShelf(shelf), nil
+}
+
+// GetByShareToken returns a copy of the shelf shared under token. Returns
+// domain.ErrNotFound if no shelf is shared with that token.
+func (r *ShelfRepository) GetByShareToken(token string) (*domain.Shelf, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byToken[token]
+	if !ok || token == "" {
+		return nil, domain.ErrNotFound
+	}
+	return copyShelf(r.shelves[id]), nil
+}
+
+// ListByOwner returns copies of the owner's shelves in creation order.
+func (r *ShelfRepository) ListByOwner(owner string) ([]*domain.Shelf, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.byOwner[owner]
+	out := make([]*domain.Shelf, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, copyShelf(r.shelves[id]))
+	}
+	return out, nil
+}
+
+// Update replaces the stored shelf with a copy of shelf. Returns
+// domain.ErrNotFound if the ID is absent.
+func (r *ShelfRepository) Update(shelf *domain.Shelf) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.shelves[shelf.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	if existing.Owner != shelf.Owner {
+		return domain.ErrInvalidData
+	}
+	if existing.ShareToken != shelf.ShareToken {
+		delete(r.byToken, existing.ShareToken)
+		if shelf.ShareToken != "" {
+			r.byToken[shelf.ShareToken] = shelf.ID
+		}
+	}
+	r.shelves[shelf.ID] = copyShelf(shelf)
+	return nil
+}
+
+// Delete removes a shelf by ID. Returns domain.ErrNotFound if the ID is absent.
+func (r *ShelfRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shelf, ok := r.shelves[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.shelves, id)
+	delete(r.byToken, shelf.ShareToken)
+
+	ids := r.byOwner[shelf.Owner]
+	for i, sid := range ids {
+		if sid == id {
+			r.byOwner[shelf.Owner] = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// RemoveBook drops bookID from every shelf that holds it. An Update based on a
+// shelf read before the call writes the book back, so callers must serialise
+// the two; see usecase.ShelfUseCase.Cascade.
+func (r *ShelfRepository) RemoveBook(bookID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, shelf := range r.shelves {
+		idx := indexOf(shelf.BookIDs, bookID)
+		if idx < 0 {
+			continue
+		}
+		updated := *shelf
+		updated.BookIDs = make([]string, 0, len(shelf.BookIDs)-1)
+		updated.BookIDs = append(updated.BookIDs, shelf.BookIDs[:idx]...)
+		updated.BookIDs = append(updated.BookIDs, shelf.BookIDs[idx+1:]...)
+		r.shelves[id] = &updated
+	}
+	return nil
+}
+
+func copyShelf(s *domain.Shelf) *domain.Shelf {
+	c := *s
+	c.BookIDs = append(make([]string, 0, len(s.BookIDs)), s.BookIDs...)
+	return &c
+}
+
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,47 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+)
+
+// TestShelfRemoveBookCascades verifies that RemoveBook drops a book from every
+// owner's shelves while preserving the order of the remaining books.
+func TestShelfRemoveBookCascades(t *testing.T) {
+	repo := memory.NewShelfRepository()
+	_ = repo.Create(&domain.Shelf{ID: "s1", Owner: "alice", Name: "to-read", BookIDs: []string{"a", "b", "c"}})
+	_ = repo.Create(&domain.Shelf{ID: "s2", Owner: "bob", Name: "to-read", BookIDs: []string{"b"}})
+
+	before, _ := repo.GetByID("s1")
+	if err := repo.RemoveBook("b"); err != nil {
+		t.Fatalf("RemoveBook: %v", err)
+	}
+
+	s1, _ := repo.GetByID("s1")
+	if len(s1.BookIDs) != 2 || s1.BookIDs[0] != "a" || s1.BookIDs[1] != "c" {
+		t.Errorf("s1: got %v, want [a c]", s1.BookIDs)
+	}
+	s2, _ := repo.GetByID("s2")
+	if len(s2.BookIDs) != 0 {
+		t.Errorf("s2: got %v, want []", s2.BookIDs)
+	}
+	if len(before.BookIDs) != 3 {
+		t.Errorf("previously returned shelf was mutated: %v", before.BookIDs)
+	}
+}
+
+// TestShelfUniqueNamePerOwner verifies names are unique per owner only.
+func TestShelfUniqueNamePerOwner(t *testing.T) {
+	repo := memory.NewShelfRepository()
+	if err := repo.Create(&domain.Shelf{ID: "s1", Owner: "alice", Name: "favourites"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.Create(&domain.Shelf{ID: "s2", Owner: "alice", Name: "favourites"}); err != domain.ErrConflict {
+		t.Errorf("duplicate name: want ErrConflict, got %v", err)
+	}
+	if err := repo.Create(&domain.Shelf{ID: "s3", Owner: "bob", Name: "favourites"}); err != nil {
+		t.Errorf("same name for another owner: %v", err)
+	}
+}
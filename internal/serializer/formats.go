@@ -0,0 +1,103 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"sigs.k8s.io/yaml"
+)
+
+// Canonical media types of the built-in formats.
+const (
+	MediaTypeJSON        = "application/json"
+	MediaTypeXML         = "application/xml"
+	MediaTypeYAML        = "application/yaml"
+	MediaTypeMessagePack = "application/msgpack"
+	MediaTypeCBOR        = "application/cbor"
+)
+
+// JSON encodes with encoding/json.
+type JSON struct{}
+
+func (JSON) MediaType() string                  { return MediaTypeJSON }
+func (JSON) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSON) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// XML encodes with encoding/xml, so values need xml tags. A slice is wrapped
+// in an <items> element holding one element per value.
+type XML struct{}
+
+// xmlList is the document element of an encoded slice.
+type xmlList struct {
+	XMLName xml.Name `xml:"items"`
+	Items   []any
+}
+
+func (XML) MediaType() string { return MediaTypeXML }
+
+func (XML) Marshal(v any) ([]byte, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := xmlList{Items: make([]any, rv.Len())}
+		for i := range list.Items {
+			list.Items[i] = rv.Index(i).Interface()
+		}
+		v = list
+	}
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func (XML) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+// YAML converts to and from JSON, so values are described by their json
+// tags.
+type YAML struct{}
+
+func (YAML) MediaType() string                  { return MediaTypeYAML }
+func (YAML) Marshal(v any) ([]byte, error)      { return yaml.Marshal(v) }
+func (YAML) Unmarshal(data []byte, v any) error { return yaml.Unmarshal(data, v) }
+
+// MessagePack encodes structs as maps keyed by their json names, and times
+// with the MessagePack timestamp extension.
+type MessagePack struct{}
+
+func (MessagePack) MediaType() string { return MediaTypeMessagePack }
+
+func (MessagePack) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MessagePack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// CBOR encodes structs as maps keyed by their json names, and times as
+// RFC 3339 strings with tag 0 so that no precision is lost.
+type CBOR struct{}
+
+var cborEncMode = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+	if err != nil {
+		panic("serializer: cbor options: " + err.Error())
+	}
+	return mode
+}()
+
+func (CBOR) MediaType() string                  { return MediaTypeCBOR }
+func (CBOR) Marshal(v any) ([]byte, error)      { return cborEncMode.Marshal(v) }
+func (CBOR) Unmarshal(data []byte, v any) error { return cbor.Unmarshal(data, v) }
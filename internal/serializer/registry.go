@@ -0,0 +1,170 @@
+// Package serializer encodes and decodes API payloads in the media types a
+// client asks for. A Registry holds the supported formats, picks the one for
+// a response from an Accept header and the one for a request body from its
+// Content-Type.
+//
+// Values are described by their json struct tags in every format, so a DTO
+// is declared once; XML additionally uses xml tags, since element names and
+// attributes have no JSON equivalent.
+package serializer
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Serializer converts values to and from one format.
+type Serializer interface {
+	// MediaType is the canonical media type of the format.
+	MediaType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Registry maps media types to serializers. The first serializer registered
+// is the default, used when a client accepts anything. A Registry must not be
+// modified once in use.
+type Registry struct {
+	serializers []Serializer
+	types       map[string]Serializer   // media type or alias → serializer
+	aliases     map[Serializer][]string // serializer → its media types, canonical first
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]Serializer), aliases: make(map[Serializer][]string)}
+}
+
+// Default returns a registry of the built-in formats: JSON (the default),
+// XML, YAML, MessagePack and CBOR.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(JSON{})
+	r.Register(XML{}, "text/xml")
+	r.Register(YAML{}, "application/x-yaml", "text/yaml")
+	r.Register(MessagePack{}, "application/x-msgpack", "application/vnd.msgpack")
+	r.Register(CBOR{})
+	return r
+}
+
+// Register adds s under its media type and any aliases.
+func (r *Registry) Register(s Serializer, aliases ...string) {
+	r.serializers = append(r.serializers, s)
+	for _, t := range append([]string{s.MediaType()}, aliases...) {
+		t = strings.ToLower(t)
+		r.types[t] = s
+		r.aliases[s] = append(r.aliases[s], t)
+	}
+}
+
+// MediaTypes returns the canonical media type of every serializer in
+// registration order.
+func (r *Registry) MediaTypes() []string {
+	types := make([]string, len(r.serializers))
+	for i, s := range r.serializers {
+		types[i] = s.MediaType()
+	}
+	return types
+}
+
+// ForContentType returns the serializer for a Content-Type header value,
+// ignoring parameters such as charset.
+func (r *Registry) ForContentType(contentType string) (Serializer, bool) {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	s, ok := r.types[t]
+	return s, ok
+}
+
+// Negotiate picks the serializer for a response from an Accept header value
+// and returns it with the media type to label the response with. An empty
+// header accepts the default.
+//
+// Each serializer is ranked by the quality of the most specific media range
+// matching one of its types: an exact type beats type/*, which beats */*. The
+// highest quality wins; ties go to the range listed first, then to the
+// serializer registered first. A quality of 0 excludes a serializer. The
+// response is labelled with the type the range matched, or with the
+// canonical type for */*. ok is false when no serializer is acceptable.
+func (r *Registry) Negotiate(accept string) (s Serializer, mediaType string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		if len(r.serializers) == 0 {
+			return nil, "", false
+		}
+		return r.serializers[0], r.serializers[0].MediaType(), true
+	}
+
+	ranges := parseAccept(accept)
+	best := -1.0
+	bestIndex := 0
+	for _, candidate := range r.serializers {
+		q, index, matched := -1.0, 0, ""
+		specificity := -1
+		for i, rng := range ranges {
+			for _, t := range r.aliases[candidate] {
+				sp := rng.match(t)
+				if sp > specificity {
+					q, index, specificity = rng.q, i, sp
+					matched = t
+					if sp == 0 {
+						matched = candidate.MediaType()
+					}
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > best || (q == best && index < bestIndex) {
+			s, mediaType, ok = candidate, matched, true
+			best, bestIndex = q, index
+		}
+	}
+	return s, mediaType, ok
+}
+
+// mediaRange is one element of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || typ == "" || subtype == "" {
+			continue
+		}
+		rng := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+					rng.q = q
+				}
+			}
+		}
+		ranges = append(ranges, rng)
+	}
+	return ranges
+}
+
+// match returns how specifically the range matches mediaType: 2 for an exact
+// match, 1 for type/*, 0 for */* and -1 for no match.
+func (m mediaRange) match(mediaType string) int {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	switch {
+	case m.typ == typ && m.subtype == subtype:
+		return 2
+	case m.typ == typ && m.subtype == "*":
+		return 1
+	case m.typ == "*" && m.subtype == "*":
+		return 0
+	}
+	return -1
+}
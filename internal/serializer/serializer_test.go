@@ -0,0 +1,151 @@
+package serializer_test
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/serializer"
+)
+
+type note struct {
+	XMLName  xml.Name   `json:"-" xml:"note"`
+	ID       string     `json:"id" xml:"id"`
+	Rating   float64    `json:"rating" xml:"rating"`
+	Count    int        `json:"count,omitempty" xml:"count,omitempty"`
+	At       time.Time  `json:"at" xml:"at"`
+	Archived *time.Time `json:"archived,omitempty" xml:"archived,omitempty"`
+	Tags     []string   `json:"tags" xml:"tag"`
+}
+
+// TestRoundTrip verifies that every built-in format decodes what it encodes,
+// including times to the nanosecond, nil pointers and slices.
+func TestRoundTrip(t *testing.T) {
+	at := time.Date(2026, time.October, 18, 9, 30, 15, 123456789, time.UTC)
+	archived := at.Add(time.Hour)
+	values := []note{
+		{ID: "a", Rating: 4.5, Count: 3, At: at, Archived: &archived, Tags: []string{"x", "y"}},
+		{ID: "b", At: at, Tags: []string{}},
+	}
+
+	registry := serializer.Default()
+	for _, mediaType := range registry.MediaTypes() {
+		s, ok := registry.ForContentType(mediaType)
+		if !ok {
+			t.Fatalf("%s is not registered", mediaType)
+		}
+		t.Run(mediaType, func(t *testing.T) {
+			for _, want := range values {
+				data, err := s.Marshal(want)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+				var got note
+				if err := s.Unmarshal(data, &got); err != nil {
+					t.Fatalf("unmarshal %q: %v", data, err)
+				}
+				assertNote(t, got, want)
+			}
+
+			data, err := s.Marshal(values)
+			if err != nil {
+				t.Fatalf("marshal list: %v", err)
+			}
+			var got []note
+			if mediaType == serializer.MediaTypeXML {
+				var list struct {
+					Notes []note `xml:"note"`
+				}
+				err = s.Unmarshal(data, &list)
+				got = list.Notes
+			} else {
+				err = s.Unmarshal(data, &got)
+			}
+			if err != nil {
+				t.Fatalf("unmarshal list %q: %v", data, err)
+			}
+			if len(got) != len(values) {
+				t.Fatalf("list: got %d values, want %d", len(got), len(values))
+			}
+			for i := range values {
+				assertNote(t, got[i], values[i])
+			}
+		})
+	}
+}
+
+func assertNote(t *testing.T, got, want note) {
+	t.Helper()
+	if !got.At.Equal(want.At) || (got.Archived == nil) != (want.Archived == nil) ||
+		(got.Archived != nil && !got.Archived.Equal(*want.Archived)) {
+		t.Errorf("times: got %v/%v, want %v/%v", got.At, got.Archived, want.At, want.Archived)
+	}
+	got.At, got.Archived, got.XMLName = want.At, want.Archived, want.XMLName
+	if len(got.Tags) == 0 && len(want.Tags) == 0 {
+		got.Tags = want.Tags
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestXMLList verifies the document element of an encoded slice.
+func TestXMLList(t *testing.T) {
+	data, err := serializer.XML{}.Marshal([]any{note{ID: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.HasPrefix(s, xml.Header+"<items><note><id>a</id>") {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	registry := serializer.Default()
+	cases := []struct {
+		accept, want string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "text/xml"},
+		{"TEXT/YAML", "text/yaml"},
+		{"application/cbor, application/json", "application/cbor"},
+		{"application/json;q=0.5, application/msgpack", "application/msgpack"},
+		{"application/*;q=0.2, application/yaml;q=0.9", "application/yaml"},
+		{"text/*", "text/xml"},
+		{"application/json;q=0, */*;q=0.1", "application/xml"},
+		{"text/html, */*;q=0.8", "application/json"},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+		{"garbage", ""},
+	}
+	for _, tc := range cases {
+		_, got, ok := registry.Negotiate(tc.accept)
+		if got != tc.want || ok != (tc.want != "") {
+			t.Errorf("Accept %q: got %q, %v; want %q", tc.accept, got, ok, tc.want)
+		}
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	registry := serializer.Default()
+	cases := map[string]string{
+		"application/json":                serializer.MediaTypeJSON,
+		"application/json; charset=utf-8": serializer.MediaTypeJSON,
+		"Application/XML":                 serializer.MediaTypeXML,
+		"application/x-yaml":              serializer.MediaTypeYAML,
+		"application/vnd.msgpack":         serializer.MediaTypeMessagePack,
+		"application/cbor":                serializer.MediaTypeCBOR,
+		"text/plain":                      "",
+		"":                                "",
+	}
+	for contentType, want := range cases {
+		s, ok := registry.ForContentType(contentType)
+		if ok != (want != "") || (ok && s.MediaType() != want) {
+			t.Errorf("Content-Type %q: got %v, %v; want %q", contentType, s, ok, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// defaultAuditQueryLimit caps Query results when the filter sets no limit.
+const defaultAuditQueryLimit = 100
+
+// AuditUseCase implements domain.AuditUseCase on top of an append-only
+// repository, maintaining the SHA-256 hash chain.
+type AuditUseCase struct {
+	repo domain.AuditRepository
+
+	// mu makes reading the chain head and appending the next entry atomic.
+	mu sync.Mutex
+}
+
+// NewAuditUseCase wires the use-case to a repository.
+func NewAuditUseCase(repo domain.AuditRepository) *AuditUseCase {
+	return &AuditUseCase{repo: repo}
+}
+
+// Record links entry to the current chain head and appends it.
+func (uc *AuditUseCase) Record(entry domain.AuditEntry) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	entry.Seq = 1
+	entry.PrevHash = ""
+	last, err := uc.repo.Last()
+	switch err {
+	case nil:
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+	case domain.ErrNotFound:
+	default:
+		return err
+	}
+	entry.Time = entry.Time.UTC()
+
+	hash, err := AuditHash(&entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	return uc.repo.Append(&entry)
+}
+
+// Query returns entries matching filter, newest first.
+func (uc *AuditUseCase) Query(filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	all, err := uc.repo.All()
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditQueryLimit
+	}
+
+	out := make([]*domain.AuditEntry, 0)
+	for i := len(all) - 1; i >= 0 && len(out) < limit; i-- {
+		e := all[i]
+		if filter.Subject != "" && e.Subject != filter.Subject {
+			continue
+		}
+		if filter.Action != "" && e.Action != filter.Action {
+			continue
+		}
+		if !filter.Since.IsZero() && e.Time.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && e.Time.After(filter.Until) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// Verify walks the whole chain and reports the first broken link.
+func (uc *AuditUseCase) Verify() (*domain.AuditVerification, error) {
+	all, err := uc.repo.All()
+	if err != nil {
+		return nil, err
+	}
+	v := VerifyAuditChain(all)
+	return &v, nil
+}
+
+// AuditHash computes the chain hash of an entry: SHA-256 over the JSON
+// encoding of the entry with its Hash field cleared. PrevHash is part of that
+// encoding, which is what links each entry to its predecessor.
+func AuditHash(entry *domain.AuditEntry) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	raw, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", fmt.Errorf("encode audit entry: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditChain checks that entries are numbered 1..n without gaps, that
+// each links to its predecessor's hash, and that every hash matches the
+// entry's contents. It cannot detect removal of entries from the end of the
+// log; compare LastHash with an externally recorded value for that.
+func VerifyAuditChain(entries []*domain.AuditEntry) domain.AuditVerification {
+	v := domain.AuditVerification{Valid: true, Entries: len(entries)}
+	prev := ""
+	for i, e := range entries {
+		want := uint64(i + 1)
+		switch {
+		case e.Seq != want:
+			return invalid(v, want, fmt.Sprintf("expected seq %d, found %d", want, e.Seq))
+		case e.PrevHash != prev:
+			return invalid(v, e.Seq, "prev_hash does not match the preceding entry")
+		}
+		hash, err := AuditHash(e)
+		if err != nil {
+			return invalid(v, e.Seq, err.Error())
+		}
+		if hash != e.Hash {
+			return invalid(v, e.Seq, "hash does not match entry contents")
+		}
+		prev = e.Hash
+	}
+	v.LastHash = prev
+	return v
+}
+
+func invalid(v domain.AuditVerification, seq uint64, reason string) domain.AuditVerification {
+	v.Valid = false
+	v.FirstInvalidSeq = seq
+	v.Reason = reason
+	return v
+}
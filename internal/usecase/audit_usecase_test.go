@@ -0,0 +1,80 @@
+package usecase_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+func recordN(t *testing.T, uc *usecase.AuditUseCase, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := uc.Record(domain.AuditEntry{
+				Time:    time.Now(),
+				Subject: fmt.Sprintf("user-%d", i%3),
+				Action:  "GET /books",
+				Outcome: domain.AuditSuccess,
+				Status:  200,
+			})
+			if err != nil {
+				t.Errorf("Record: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestAuditChainIntact verifies concurrently recorded entries form a valid chain.
+func TestAuditChainIntact(t *testing.T) {
+	uc := usecase.NewAuditUseCase(memory.NewAuditRepository())
+	recordN(t, uc, 50)
+
+	v, err := uc.Verify()
+	if err != nil || !v.Valid || v.Entries != 50 {
+		t.Fatalf("Verify: got %+v, %v; want valid chain of 50", v, err)
+	}
+
+	mine, _ := uc.Query(domain.AuditFilter{Subject: "user-1", Limit: 1000})
+	for _, e := range mine {
+		if e.Subject != "user-1" {
+			t.Errorf("Query by subject returned %q", e.Subject)
+		}
+	}
+}
+
+// TestAuditChainDetectsTampering verifies that editing, removing or
+// reordering entries is reported at the first affected entry.
+func TestAuditChainDetectsTampering(t *testing.T) {
+	repo := memory.NewAuditRepository()
+	uc := usecase.NewAuditUseCase(repo)
+	recordN(t, uc, 5)
+	entries, _ := repo.All()
+
+	edited := append([]*domain.AuditEntry(nil), entries...)
+	e := *edited[2]
+	e.Subject = "mallory"
+	edited[2] = &e
+	if v := usecase.VerifyAuditChain(edited); v.Valid || v.FirstInvalidSeq != 3 {
+		t.Errorf("edited entry: got %+v, want invalid at seq 3", v)
+	}
+
+	removed := append(append([]*domain.AuditEntry(nil), entries[:1]...), entries[2:]...)
+	if v := usecase.VerifyAuditChain(removed); v.Valid || v.FirstInvalidSeq != 2 {
+		t.Errorf("removed entry: got %+v, want invalid at seq 2", v)
+	}
+
+	swapped := append([]*domain.AuditEntry(nil), entries...)
+	swapped[3], swapped[4] = swapped[4], swapped[3]
+	if v := usecase.VerifyAuditChain(swapped); v.Valid || v.FirstInvalidSeq != 4 {
+		t.Errorf("reordered entries: got %+v, want invalid at seq 4", v)
+	}
+}
@@ -49,6 +49,39 @@ func (uc *AuthUseCase) GenerateToken(username, password string) (string, error)
 
 // ValidateToken parses and verifies a JWT, returning the subject claim on success.
 func (uc *AuthUseCase) ValidateToken(tokenStr string) (string, error) {
+	claims, err := parseToken(tokenStr)
+	if err != nil {
+		return "", err
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", domain.ErrUnauthorized
+	}
+
+	return sub, nil
+}
+
+// TokenExpiry parses and verifies a JWT, returning its exp claim on success.
+// A token without exp yields the zero time.
+func (uc *AuthUseCase) TokenExpiry(tokenStr string) (time.Time, error) {
+	claims, err := parseToken(tokenStr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return time.Time{}, domain.ErrUnauthorized
+	}
+	if exp == nil {
+		return time.Time{}, nil
+	}
+	return exp.Time, nil
+}
+
+// parseToken verifies a JWT's signature and time claims and returns its claims.
+func parseToken(tokenStr string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
@@ -57,18 +90,12 @@ func (uc *AuthUseCase) ValidateToken(tokenStr string) (string, error) {
 	})
 
 	if err != nil || !token.Valid {
-		return "", domain.ErrUnauthorized
+		return nil, domain.ErrUnauthorized
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", domain.ErrUnauthorized
+		return nil, domain.ErrUnauthorized
 	}
-
-	sub, ok := claims["sub"].(string)
-	if !ok || sub == "" {
-		return "", domain.ErrUnauthorized
-	}
-
-	return sub, nil
+	return claims, nil
 }
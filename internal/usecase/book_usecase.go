@@ -2,39 +2,88 @@
 package usecase
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/validation"
 	"github.com/google/uuid"
 )
 
-// BookUseCase implements domain.BookUseCase.
+// derivedBookFields are maintained outside BookUseCase (see ReviewUseCase) and
+// are therefore left out of revision diffs and rollbacks.
+var derivedBookFields = map[string]bool{
+	"average_rating": true,
+	"review_count":   true,
+}
+
+// bookLockStripes is the number of locks BookUseCase spreads books over.
+const bookLockStripes = 64
+
+// BookUseCase implements domain.BookUseCase and domain.RevisionUseCase.
+// Every successful create, update, delete and restore is recorded as an
+// immutable revision, and a domain.BookEvent is written to the repository's
+// outbox together with the change (see OutboxRelay).
+//
+// The writes to each book are serialised, from reading the book to appending
+// the revision, so a book's revisions are in commit order and each Before is
+// the state the write replaced. The revision store is separate from the
+// repository, so a failed Append cannot undo the write: it is logged, and the
+// book's history misses that revision.
 type BookUseCase struct {
-	repo domain.BookRepository
+	repo       domain.BookRepository
+	revisions  domain.RevisionRepository
+	dependents []domain.BookDependent
+
+	// locks serialise the writes to each book, striped by a hash of its ID.
+	locks [bookLockStripes]sync.Mutex
+	seed  maphash.Seed
 }
 
-// NewBookUseCase wires the use-case to a repository.
-func NewBookUseCase(repo domain.BookRepository) *BookUseCase {
-	return &BookUseCase{repo: repo}
+// NewBookUseCase wires the use-case to its repositories.
+// Every dependent is asked to drop its references to a book once that book is
+// purged from the trash.
+func NewBookUseCase(repo domain.BookRepository, revisions domain.RevisionRepository, dependents ...domain.BookDependent) *BookUseCase {
+	return &BookUseCase{repo: repo, revisions: revisions, dependents: dependents, seed: maphash.MakeSeed()}
 }
 
-// CreateBook validates input, assigns a UUID, and persists a new book.
-func (uc *BookUseCase) CreateBook(title, author string, year int) (*domain.Book, error) {
-	if title == "" || author == "" {
-		return nil, domain.ErrInvalidData
-	}
+// lock takes the write lock of a book and returns its unlock function.
+func (uc *BookUseCase) lock(id string) func() {
+	mu := &uc.locks[maphash.String(uc.seed, id)%bookLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
 
+// CreateBook normalizes and validates input, assigns a UUID, and persists a
+// new book.
+func (uc *BookUseCase) CreateBook(input domain.BookInput, actor string) (*domain.Book, error) {
 	book := &domain.Book{
 		ID:        uuid.New().String(),
-		Title:     title,
-		Author:    author,
-		Year:      year,
+		Title:     input.Title,
+		Author:    input.Author,
+		Year:      input.Year,
+		ISBN:      input.ISBN,
+		Tags:      input.Tags,
 		CreatedAt: time.Now().UTC(),
 	}
+	if err := validation.Struct(book); err != nil {
+		return nil, err
+	}
 
-	if err := uc.repo.Create(book); err != nil {
+	// The book is published through the outbox before the revision is
+	// appended, so lock out updates made in between.
+	defer uc.lock(book.ID)()
+	if err := uc.repo.Create(book, event(domain.EventBookCreated, actor)); err != nil {
 		return nil, err
 	}
+	uc.record(domain.RevisionCreate, actor, nil, book, 0)
 	return book, nil
 }
 
@@ -49,29 +98,296 @@ func (uc *BookUseCase) GetBooks(filter domain.BookFilter) ([]*domain.Book, int,
 	return uc.repo.GetAll(filter)
 }
 
-// UpdateBook replaces the mutable fields of an existing book.
-func (uc *BookUseCase) UpdateBook(id, title, author string, year int) (*domain.Book, error) {
-	if title == "" || author == "" {
-		return nil, domain.ErrInvalidData
+// GetBooksFaceted lists books like GetBooks, with the repository counting the
+// facets of the matching books as it filters them.
+func (uc *BookUseCase) GetBooksFaceted(filter domain.BookFilter) ([]*domain.Book, int, domain.BookFacets, error) {
+	return uc.repo.GetAllFaceted(filter)
+}
+
+// GetBookStats aggregates the live books through the repository, which can
+// compute the groups where the books are stored.
+func (uc *BookUseCase) GetBookStats(query domain.BookStatsQuery) ([]domain.BookGroup, error) {
+	if !domain.ValidGroupBy(query.GroupBy) {
+		return nil, fmt.Errorf("%w: unknown group %q", domain.ErrInvalidData, query.GroupBy)
 	}
+	return uc.repo.Stats(query)
+}
+
+// UpdateBook replaces the mutable fields of an existing book. Nil Tags keep
+// the book's tags.
+func (uc *BookUseCase) UpdateBook(id string, input domain.BookInput, actor string) (*domain.Book, error) {
+	return uc.change(id, actor, func(book *domain.Book) {
+		book.Title = input.Title
+		book.Author = input.Author
+		book.Year = input.Year
+		book.ISBN = input.ISBN
+		if input.Tags != nil {
+			book.Tags = input.Tags
+		}
+	})
+}
+
+// PatchBook changes the fields set in patch and keeps the others.
+func (uc *BookUseCase) PatchBook(id string, patch domain.BookPatch, actor string) (*domain.Book, error) {
+	return uc.change(id, actor, func(book *domain.Book) {
+		if patch.Title != nil {
+			book.Title = *patch.Title
+		}
+		if patch.Author != nil {
+			book.Author = *patch.Author
+		}
+		if patch.Year != nil {
+			book.Year = *patch.Year
+		}
+		if patch.ISBN != nil {
+			book.ISBN = *patch.ISBN
+		}
+		if patch.Tags != nil {
+			book.Tags = *patch.Tags
+		}
+	})
+}
+
+// change applies edit to a copy of a live book, validates the result and
+// stores it as an update. The book as fetched is kept as the revision's
+// before state.
+func (uc *BookUseCase) change(id, actor string, edit func(*domain.Book)) (*domain.Book, error) {
+	defer uc.lock(id)()
 
 	existing, err := uc.repo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
+	updated := *existing
+	edit(&updated)
+	if err := validation.Struct(&updated); err != nil {
+		return nil, err
+	}
+
+	if err := uc.repo.Update(&updated, event(domain.EventBookUpdated, actor)); err != nil {
+		return nil, err
+	}
+	uc.record(domain.RevisionUpdate, actor, existing, &updated, 0)
+	return &updated, nil
+}
+
+// DeleteBook moves a book to the trash, recording who deleted it. Dependent
+// stores keep their references so that a restore brings everything back.
+func (uc *BookUseCase) DeleteBook(id, actor string) error {
+	defer uc.lock(id)()
+
+	before, err := uc.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	at := time.Now().UTC()
+	if err := uc.repo.SoftDelete(id, actor, at, event(domain.EventBookDeleted, actor)); err != nil {
+		return err
+	}
 
-	existing.Title = title
-	existing.Author = author
-	existing.Year = year
+	after := *before
+	after.DeletedAt = &at
+	after.DeletedBy = actor
+	uc.record(domain.RevisionDelete, actor, before, &after, 0)
+	return nil
+}
+
+// GetTrash lists trashed books, optionally filtered and paginated.
+func (uc *BookUseCase) GetTrash(filter domain.BookFilter) ([]*domain.Book, int, error) {
+	filter.Trash = domain.OnlyTrashed
+	return uc.repo.GetAll(filter)
+}
 
-	if err := uc.repo.Update(existing); err != nil {
+// RestoreBook takes a book out of the trash.
+func (uc *BookUseCase) RestoreBook(id, actor string) (*domain.Book, error) {
+	defer uc.lock(id)()
+
+	if err := uc.repo.Restore(id, event(domain.EventBookRestored, actor)); err != nil {
+		return nil, err
+	}
+	book, err := uc.repo.GetByID(id)
+	if err != nil {
 		return nil, err
 	}
-	return existing, nil
+	uc.record(domain.RevisionRestore, actor, uc.latestState(id), book, 0)
+	return book, nil
+}
+
+// PurgeTrash permanently deletes books trashed before the cutoff and cascades
+// the removal to every dependent store (e.g. shelves, covers, reviews). Revisions are
+// kept as the permanent record of the book.
+//
+// The repository has forgotten the purged books by the time the cascade runs,
+// so nothing could retry it: a failing dependent does not stop the others,
+// and every failure is returned together.
+func (uc *BookUseCase) PurgeTrash(before time.Time) (int, error) {
+	purged, err := uc.repo.PurgeTrashed(before)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, id := range purged {
+		for _, d := range uc.dependents {
+			if err := d.RemoveBook(id); err != nil {
+				errs = append(errs, fmt.Errorf("remove book %s from dependents: %w", id, err))
+			}
+		}
+	}
+	return len(purged), errors.Join(errs...)
 }
 
-// DeleteBook removes a book by ID.
-func (uc *BookUseCase) DeleteBook(id string) error {
-	return uc.repo.Delete(id)
+// GetRevisions lists a book's revisions, oldest first. History outlives the
+// book itself, so trashed and purged books still have revisions.
+func (uc *BookUseCase) GetRevisions(bookID string) ([]*domain.Revision, error) {
+	return uc.revisions.List(bookID)
 }
 
+// GetRevision returns a single revision of a book.
+func (uc *BookUseCase) GetRevision(bookID string, number int) (*domain.Revision, error) {
+	return uc.revisions.Get(bookID, number)
+}
+
+// DiffRevisions compares the book state after revision from with the state
+// after revision to.
+func (uc *BookUseCase) DiffRevisions(bookID string, from, to int) (*domain.RevisionDiff, error) {
+	a, err := uc.revisions.Get(bookID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := uc.revisions.Get(bookID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diffBooks(a.After, b.After)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.RevisionDiff{BookID: bookID, From: from, To: to, Changes: changes}, nil
+}
+
+// RollbackBook restores a live book's title, author, year, ISBN and tags to
+// their values after the given revision.
+func (uc *BookUseCase) RollbackBook(bookID string, number int, actor string) (*domain.Book, error) {
+	defer uc.lock(bookID)()
+
+	rev, err := uc.revisions.Get(bookID, number)
+	if err != nil {
+		return nil, err
+	}
+	target := rev.After
+	if target == nil {
+		return nil, domain.ErrInvalidData
+	}
+
+	before, err := uc.repo.GetByID(bookID)
+	if err != nil {
+		return nil, err
+	}
+	updated := *before
+	updated.Title = target.Title
+	updated.Author = target.Author
+	updated.Year = target.Year
+	updated.ISBN = target.ISBN
+	updated.Tags = target.Tags
+
+	if err := uc.repo.Update(&updated, event(domain.EventBookUpdated, actor)); err != nil {
+		return nil, err
+	}
+	uc.record(domain.RevisionRollback, actor, before, &updated, number)
+	return &updated, nil
+}
+
+// record appends a revision of a committed write; callers hold the book's
+// lock. The repository stores its own copies of the books. A failure is only
+// logged: the write has committed and reporting it as failed would mislead.
+func (uc *BookUseCase) record(action, actor string, before, after *domain.Book, restoredFrom int) {
+	bookID := ""
+	if after != nil {
+		bookID = after.ID
+	} else if before != nil {
+		bookID = before.ID
+	}
+	err := uc.revisions.Append(&domain.Revision{
+		BookID:       bookID,
+		Action:       action,
+		Actor:        actor,
+		At:           time.Now().UTC(),
+		Before:       before,
+		After:        after,
+		RestoredFrom: restoredFrom,
+	})
+	if err != nil {
+		log.Printf("revisions: %s of book %s not recorded: %v", action, bookID, err)
+	}
+}
+
+// event describes a change for the repository's outbox, which fills in the
+// event ID and the book snapshot when the write commits.
+func event(eventType, actor string) domain.BookEvent {
+	return domain.BookEvent{
+		Type:       eventType,
+		Actor:      actor,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// latestState returns the book state recorded by its most recent revision, or
+// nil when the book has no history.
+func (uc *BookUseCase) latestState(id string) *domain.Book {
+	revs, err := uc.revisions.List(id)
+	if err != nil || len(revs) == 0 {
+		return nil
+	}
+	return revs[len(revs)-1].After
+}
+
+// diffBooks compares two book states field by field, using their JSON names.
+// Derived fields are ignored; a nil state has no fields.
+func diffBooks(a, b *domain.Book) ([]domain.FieldChange, error) {
+	am, err := bookFields(a)
+	if err != nil {
+		return nil, err
+	}
+	bm, err := bookFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		names = append(names, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]domain.FieldChange, 0)
+	for _, name := range names {
+		if derivedBookFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(am[name], bm[name]) {
+			changes = append(changes, domain.FieldChange{Field: name, From: am[name], To: bm[name]})
+		}
+	}
+	return changes, nil
+}
+
+func bookFields(b *domain.Book) (map[string]any, error) {
+	fields := map[string]any{}
+	if b == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
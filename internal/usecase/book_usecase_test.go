@@ -0,0 +1,241 @@
+package usecase_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// failingRevisions fails every Append, as a full or unreachable store would.
+type failingRevisions struct {
+	*memory.RevisionRepository
+}
+
+func (failingRevisions) Append(*domain.Revision) error { return errors.New("revision store down") }
+
+// slowRevisions takes a while to append, so that without serialisation other
+// writes to the book would commit before a revision is stored.
+type slowRevisions struct {
+	*memory.RevisionRepository
+}
+
+func (r slowRevisions) Append(rev *domain.Revision) error {
+	time.Sleep(200 * time.Microsecond)
+	return r.RevisionRepository.Append(rev)
+}
+
+// TestDiffRevisions verifies that a diff lists the changed fields between the
+// states after two revisions, leaving out the derived rating fields.
+func TestDiffRevisions(t *testing.T) {
+	books := memory.NewBookRepository()
+	uc := usecase.NewBookUseCase(books, memory.NewRevisionRepository())
+	book, _ := uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+	books.UpdateRating(book.ID, domain.RatingSummary{Average: 5, Count: 1})
+	uc.PatchBook(book.ID, domain.BookPatch{Title: ptr("Dune Messiah"), Year: ptr(1969)}, "bob")
+
+	diff, err := uc.DiffRevisions(book.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffRevisions: %v", err)
+	}
+	want := []domain.FieldChange{
+		{Field: "title", From: "Dune", To: "Dune Messiah"},
+		{Field: "year", From: float64(1965), To: float64(1969)},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("changes: got %+v, want %+v", diff.Changes, want)
+	}
+	if diff, _ := uc.DiffRevisions(book.ID, 2, 2); len(diff.Changes) != 0 {
+		t.Errorf("diff with itself: got %+v", diff.Changes)
+	}
+	if _, err := uc.DiffRevisions(book.ID, 1, 3); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("diff with missing revision: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestRollbackBook verifies that a rollback restores the fields of an earlier
+// revision, keeps the rating and is itself recorded as a new revision.
+func TestRollbackBook(t *testing.T) {
+	books := memory.NewBookRepository()
+	uc := usecase.NewBookUseCase(books, memory.NewRevisionRepository())
+	book, _ := uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965, ISBN: "9780441013593"}, "alice")
+	uc.UpdateBook(book.ID, domain.BookInput{Title: "Dune Messiah", Author: "F. Herbert", Year: 1969}, "bob")
+	books.UpdateRating(book.ID, domain.RatingSummary{Average: 4, Count: 2})
+
+	restored, err := uc.RollbackBook(book.ID, 1, "carol")
+	if err != nil {
+		t.Fatalf("RollbackBook: %v", err)
+	}
+	stored, _ := uc.GetBook(book.ID)
+	for _, b := range []*domain.Book{restored, stored} {
+		if b.Title != "Dune" || b.Author != "Frank Herbert" || b.Year != 1965 || b.ISBN != "9780441013593" {
+			t.Errorf("rolled back book: got %+v", b)
+		}
+	}
+	if stored.AverageRating != 4 || stored.ReviewCount != 2 {
+		t.Errorf("rating after rollback: got %.2f over %d, want 4 over 2", stored.AverageRating, stored.ReviewCount)
+	}
+
+	revs, _ := uc.GetRevisions(book.ID)
+	if len(revs) != 3 {
+		t.Fatalf("revisions: got %d, want 3", len(revs))
+	}
+	last := revs[2]
+	if last.Action != domain.RevisionRollback || last.RestoredFrom != 1 || last.Actor != "carol" || last.Before.Title != "Dune Messiah" {
+		t.Errorf("rollback revision: got %+v", last)
+	}
+	if diff, _ := uc.DiffRevisions(book.ID, 1, 3); len(diff.Changes) != 0 {
+		t.Errorf("diff with the restored revision: got %+v", diff.Changes)
+	}
+
+	uc.DeleteBook(book.ID, "alice")
+	if _, err := uc.RollbackBook(book.ID, 2, "alice"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("rollback of trashed book: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestBookTags verifies that a full update without tags keeps a book's tags,
+// so that clients predating them do not clear them, that empty tags and a
+// patch change them, and that a rollback restores them.
+func TestBookTags(t *testing.T) {
+	uc := usecase.NewBookUseCase(memory.NewBookRepository(), memory.NewRevisionRepository())
+	book, err := uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Tags: []string{"Classic", "classic"}}, "alice")
+	if err != nil {
+		t.Fatalf("CreateBook: %v", err)
+	}
+	steps := []struct {
+		name string
+		do   func() (*domain.Book, error)
+		want string
+	}{
+		{"create", func() (*domain.Book, error) { return uc.GetBook(book.ID) }, "[classic]"},
+		{"update without tags", func() (*domain.Book, error) {
+			return uc.UpdateBook(book.ID, domain.BookInput{Title: "Dune", Author: "Frank Herbert"}, "alice")
+		}, "[classic]"},
+		{"patch", func() (*domain.Book, error) {
+			return uc.PatchBook(book.ID, domain.BookPatch{Tags: &[]string{"classic", "Science Fiction"}}, "alice")
+		}, "[classic science fiction]"},
+		{"update with empty tags", func() (*domain.Book, error) {
+			return uc.UpdateBook(book.ID, domain.BookInput{Title: "Dune", Author: "Frank Herbert", Tags: []string{}}, "alice")
+		}, "[]"},
+		{"rollback", func() (*domain.Book, error) { return uc.RollbackBook(book.ID, 3, "alice") }, "[classic science fiction]"},
+	}
+	for _, step := range steps {
+		got, err := step.do()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		stored, _ := uc.GetBook(book.ID)
+		if fmt.Sprint(got.Tags) != step.want || fmt.Sprint(stored.Tags) != step.want {
+			t.Errorf("%s: got %q, stored %q, want %s", step.name, got.Tags, stored.Tags, step.want)
+		}
+	}
+}
+
+// TestRevisionsFollowCommitOrder runs concurrent updates of one book and
+// verifies that each revision's Before is the previous revision's After, so
+// the history is in commit order. Slow appends leave other writers time to
+// commit between a write and its revision, which serialisation must prevent.
+func TestRevisionsFollowCommitOrder(t *testing.T) {
+	const writers, rounds = 4, 10
+	uc := usecase.NewBookUseCase(memory.NewBookRepository(), slowRevisions{memory.NewRevisionRepository()})
+	book, _ := uc.CreateBook(domain.BookInput{Title: "Title", Author: "Author"}, "alice")
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				title := fmt.Sprintf("Title %d-%d", w, i)
+				if _, err := uc.PatchBook(book.ID, domain.BookPatch{Title: &title}, "alice"); err != nil {
+					t.Errorf("PatchBook: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	revs, _ := uc.GetRevisions(book.ID)
+	if len(revs) != 1+writers*rounds {
+		t.Fatalf("revisions: got %d, want %d", len(revs), 1+writers*rounds)
+	}
+	for i := 1; i < len(revs); i++ {
+		if revs[i].Before.Title != revs[i-1].After.Title {
+			t.Fatalf("revision %d: Before %q, previous After %q", revs[i].Number, revs[i].Before.Title, revs[i-1].After.Title)
+		}
+	}
+	if current, _ := uc.GetBook(book.ID); current.Title != revs[len(revs)-1].After.Title {
+		t.Errorf("book %q, last revision %q", current.Title, revs[len(revs)-1].After.Title)
+	}
+}
+
+// TestFailedRevisionDoesNotFailWrite verifies that a committed write is
+// reported as done when its revision cannot be stored.
+func TestFailedRevisionDoesNotFailWrite(t *testing.T) {
+	uc := usecase.NewBookUseCase(memory.NewBookRepository(), failingRevisions{memory.NewRevisionRepository()})
+
+	book, err := uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert"}, "alice")
+	if err != nil {
+		t.Fatalf("CreateBook: %v", err)
+	}
+	if _, err := uc.UpdateBook(book.ID, domain.BookInput{Title: "Dune Messiah", Author: "Frank Herbert"}, "alice"); err != nil {
+		t.Errorf("UpdateBook: %v", err)
+	}
+	if err := uc.DeleteBook(book.ID, "alice"); err != nil {
+		t.Errorf("DeleteBook: %v", err)
+	}
+	if _, err := uc.RestoreBook(book.ID, "alice"); err != nil {
+		t.Errorf("RestoreBook: %v", err)
+	}
+}
+
+// failingDependent fails to forget the books in fail and records the others.
+type failingDependent struct {
+	fail    map[string]bool
+	removed []string
+}
+
+func (d *failingDependent) RemoveBook(bookID string) error {
+	if d.fail[bookID] {
+		return errors.New("cover store down")
+	}
+	d.removed = append(d.removed, bookID)
+	return nil
+}
+
+// TestPurgeTrashCascadesPastFailures verifies that a dependent failing for
+// one purged book neither stops the cascade for the other books nor keeps
+// later dependents from running, and that every failure is reported.
+func TestPurgeTrashCascadesPastFailures(t *testing.T) {
+	repo := memory.NewBookRepository()
+	flaky, after := &failingDependent{fail: map[string]bool{}}, &failingDependent{}
+	uc := usecase.NewBookUseCase(repo, memory.NewRevisionRepository(), flaky, after)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		book, _ := uc.CreateBook(domain.BookInput{Title: fmt.Sprintf("Book %d", i), Author: "Frank Herbert"}, "alice")
+		uc.DeleteBook(book.ID, "alice")
+		ids = append(ids, book.ID)
+	}
+	flaky.fail[ids[0]], flaky.fail[ids[2]] = true, true
+
+	n, err := uc.PurgeTrash(time.Now().Add(time.Minute))
+	if n != 3 || err == nil {
+		t.Fatalf("PurgeTrash: got %d, %v; want 3 and the dependent's errors", n, err)
+	}
+	for _, id := range []string{ids[0], ids[2]} {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("error %q does not name %s", err, id)
+		}
+	}
+	if fmt.Sprint(flaky.removed) != fmt.Sprint(ids[1:2]) || fmt.Sprint(after.removed) != fmt.Sprint(ids) {
+		t.Errorf("removed: got %v and %v, want %v and %v", flaky.removed, after.removed, ids[1:2], ids)
+	}
+}
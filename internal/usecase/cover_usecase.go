@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder with image.Decode
+)
+
+// Accepted cover formats, as reported by http.DetectContentType.
+const (
+	mimeJPEG = "image/jpeg"
+	mimePNG  = "image/png"
+	mimeWebP = "image/webp"
+)
+
+// thumbnailJPEGQuality is the encoder quality used for JPEG thumbnails.
+const thumbnailJPEGQuality = 85
+
+// maxConcurrentDecodes bounds how many uploads are decoded at once. A cover
+// at domain.MaxCoverDimension decodes to up to 256 MB, so decoding every
+// concurrent upload at the same time would let a handful of requests exhaust
+// memory; further uploads wait for a slot instead.
+const maxConcurrentDecodes = 2
+
+// CoverUseCase implements domain.CoverUseCase.
+// The uploaded file is stored unchanged as the "original" rendition; each
+// thumbnail is re-encoded as PNG when the source is PNG (to keep transparency)
+// and as JPEG otherwise, since the standard library has no WebP encoder.
+type CoverUseCase struct {
+	covers  domain.CoverRepository
+	books   domain.BookRepository
+	blobs   domain.BlobStore
+	decodes chan struct{} // one slot per decode in progress
+}
+
+// NewCoverUseCase wires the use-case to its repositories and blob store.
+func NewCoverUseCase(covers domain.CoverRepository, books domain.BookRepository, blobs domain.BlobStore) *CoverUseCase {
+	return &CoverUseCase{
+		covers:  covers,
+		books:   books,
+		blobs:   blobs,
+		decodes: make(chan struct{}, maxConcurrentDecodes),
+	}
+}
+
+// UploadCover validates an image upload and stores it with its thumbnails,
+// replacing any previous cover of the book.
+func (uc *CoverUseCase) UploadCover(bookID string, data []byte) (*domain.Cover, error) {
+	if len(data) == 0 {
+		return nil, domain.ErrInvalidData
+	}
+	if len(data) > domain.MaxCoverSize {
+		return nil, domain.ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case mimeJPEG, mimePNG, mimeWebP:
+	default:
+		return nil, domain.ErrUnsupportedMedia
+	}
+
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, domain.ErrInvalidData
+	}
+	if cfg.Width > domain.MaxCoverDimension || cfg.Height > domain.MaxCoverDimension {
+		return nil, domain.ErrTooLarge
+	}
+
+	// Hold a decode slot until the thumbnails are encoded and src is garbage.
+	uc.decodes <- struct{}{}
+	defer func() { <-uc.decodes }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, domain.ErrInvalidData
+	}
+
+	key, err := uc.blobs.Put(data)
+	if err != nil {
+		return nil, err
+	}
+	cover := &domain.Cover{
+		BookID: bookID,
+		Images: map[string]domain.CoverImage{
+			domain.CoverSizeOriginal: {
+				Key:         key,
+				ContentType: contentType,
+				Width:       cfg.Width,
+				Height:      cfg.Height,
+				Size:        len(data),
+			},
+		},
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	for size, width := range domain.CoverThumbnailWidths {
+		img, err := uc.thumbnail(src, width, contentType == mimePNG)
+		if err != nil {
+			return nil, fmt.Errorf("generate %s thumbnail: %w", size, err)
+		}
+		cover.Images[size] = img
+	}
+
+	if err := uc.covers.Save(cover); err != nil {
+		return nil, err
+	}
+	return cover, nil
+}
+
+// GetCover returns the requested rendition of a live book's cover. The cover
+// of a trashed book is kept for a restore but not served.
+func (uc *CoverUseCase) GetCover(bookID, size string) (domain.CoverImage, []byte, time.Time, error) {
+	if size == "" {
+		size = domain.CoverSizeOriginal
+	}
+	if _, ok := domain.CoverThumbnailWidths[size]; !ok && size != domain.CoverSizeOriginal {
+		return domain.CoverImage{}, nil, time.Time{}, domain.ErrInvalidData
+	}
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		return domain.CoverImage{}, nil, time.Time{}, err
+	}
+
+	cover, err := uc.covers.Get(bookID)
+	if err != nil {
+		return domain.CoverImage{}, nil, time.Time{}, err
+	}
+	img, ok := cover.Images[size]
+	if !ok {
+		return domain.CoverImage{}, nil, time.Time{}, domain.ErrNotFound
+	}
+	data, err := uc.blobs.Get(img.Key)
+	if err != nil {
+		return domain.CoverImage{}, nil, time.Time{}, err
+	}
+	return img, data, cover.UpdatedAt, nil
+}
+
+// thumbnail scales src down to at most maxWidth pixels wide and stores the
+// encoded result in the blob store.
+func (uc *CoverUseCase) thumbnail(src image.Image, maxWidth int, asPNG bool) (domain.CoverImage, error) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxWidth {
+		h = h * maxWidth / w
+		w = maxWidth
+		if h < 1 {
+			h = 1
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	contentType := mimeJPEG
+	if asPNG {
+		contentType = mimePNG
+		if err := png.Encode(&buf, dst); err != nil {
+			return domain.CoverImage{}, err
+		}
+	} else if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return domain.CoverImage{}, err
+	}
+
+	key, err := uc.blobs.Put(buf.Bytes())
+	if err != nil {
+		return domain.CoverImage{}, err
+	}
+	return domain.CoverImage{
+		Key:         key,
+		ContentType: contentType,
+		Width:       w,
+		Height:      h,
+		Size:        buf.Len(),
+	}, nil
+}
@@ -0,0 +1,130 @@
+package usecase_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// newCoverFixture wires the cover use case to a blob store in a temporary
+// directory and creates one book.
+func newCoverFixture(t *testing.T) (*usecase.CoverUseCase, *usecase.BookUseCase, *domain.Book) {
+	t.Helper()
+	blobs, err := filesystem.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBlobStore: %v", err)
+	}
+	books, covers := memory.NewBookRepository(), memory.NewCoverRepository()
+	bookUC := usecase.NewBookUseCase(books, memory.NewRevisionRepository(), covers)
+	book, err := bookUC.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert"}, "alice")
+	if err != nil {
+		t.Fatalf("CreateBook: %v", err)
+	}
+	return usecase.NewCoverUseCase(covers, books, blobs), bookUC, book
+}
+
+func encodeImage(t *testing.T, w, h int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encode %dx%d: %v", w, h, err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(buf, img, nil)
+}
+func encodeGIF(buf *bytes.Buffer, img image.Image) error { return gif.Encode(buf, img, nil) }
+
+// TestUploadCoverValidation verifies that uploads are sniffed from their
+// bytes and held to the size and dimension limits.
+func TestUploadCoverValidation(t *testing.T) {
+	uc, _, book := newCoverFixture(t)
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want error
+	}{
+		"empty":             {nil, domain.ErrInvalidData},
+		"text":              {[]byte("definitely not an image"), domain.ErrUnsupportedMedia},
+		"gif":               {encodeImage(t, 10, 10, encodeGIF), domain.ErrUnsupportedMedia},
+		"over 5 MiB":        {make([]byte, domain.MaxCoverSize+1), domain.ErrTooLarge},
+		"too wide":          {encodeImage(t, domain.MaxCoverDimension+1, 1, encodePNG), domain.ErrTooLarge},
+		"too tall":          {encodeImage(t, 1, domain.MaxCoverDimension+1, encodePNG), domain.ErrTooLarge},
+		"truncated png":     {encodeImage(t, 10, 10, encodePNG)[:40], domain.ErrInvalidData},
+		"at the dimensions": {encodeImage(t, domain.MaxCoverDimension, 1, encodePNG), nil},
+	} {
+		if _, err := uc.UploadCover(book.ID, tc.data); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+	if _, err := uc.UploadCover("missing", encodeImage(t, 10, 10, encodePNG)); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown book: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestUploadCoverThumbnails verifies the renditions stored for an upload:
+// thumbnails are scaled to their widths, keep the aspect ratio, are never
+// upscaled and keep PNG for PNG sources only.
+func TestUploadCoverThumbnails(t *testing.T) {
+	uc, _, book := newCoverFixture(t)
+
+	cover, err := uc.UploadCover(book.ID, encodeImage(t, 800, 1200, encodePNG))
+	if err != nil {
+		t.Fatalf("UploadCover(png): %v", err)
+	}
+	want := map[string][2]int{"original": {800, 1200}, "small": {128, 192}, "medium": {320, 480}, "large": {640, 960}}
+	for size, dims := range want {
+		img := cover.Images[size]
+		if img.Width != dims[0] || img.Height != dims[1] || img.ContentType != "image/png" {
+			t.Errorf("%s: got %dx%d %s, want %dx%d image/png", size, img.Width, img.Height, img.ContentType, dims[0], dims[1])
+		}
+		got, data, _, err := uc.GetCover(book.ID, size)
+		if err != nil || got.Key != img.Key || len(data) != img.Size {
+			t.Errorf("GetCover(%s): got %+v, %d bytes, %v", size, got, len(data), err)
+		}
+	}
+
+	cover, err = uc.UploadCover(book.ID, encodeImage(t, 200, 100, encodeJPEG))
+	if err != nil {
+		t.Fatalf("UploadCover(jpeg): %v", err)
+	}
+	for size, width := range map[string]int{"small": 128, "medium": 200, "large": 200} {
+		img := cover.Images[size]
+		if img.Width != width || img.Height != width/2 || img.ContentType != "image/jpeg" {
+			t.Errorf("jpeg %s: got %dx%d %s, want %dx%d image/jpeg", size, img.Width, img.Height, img.ContentType, width, width/2)
+		}
+	}
+	if _, _, _, err := uc.GetCover(book.ID, "huge"); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("GetCover(huge): got %v, want ErrInvalidData", err)
+	}
+}
+
+// TestCoverOfTrashedBook verifies that a trashed book's cover is not served
+// but comes back with the book.
+func TestCoverOfTrashedBook(t *testing.T) {
+	uc, books, book := newCoverFixture(t)
+	if _, err := uc.UploadCover(book.ID, encodeImage(t, 10, 10, encodePNG)); err != nil {
+		t.Fatalf("UploadCover: %v", err)
+	}
+
+	books.DeleteBook(book.ID, "alice")
+	if _, _, _, err := uc.GetCover(book.ID, ""); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetCover of trashed book: got %v, want ErrNotFound", err)
+	}
+	books.RestoreBook(book.ID, "alice")
+	if _, _, _, err := uc.GetCover(book.ID, ""); err != nil {
+		t.Errorf("GetCover after restore: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyUseCase implements domain.IdempotencyUseCase.
+type IdempotencyUseCase struct {
+	repo domain.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyUseCase returns a use-case that remembers each key for ttl
+// after its first use, in flight or not.
+func NewIdempotencyUseCase(repo domain.IdempotencyRepository, ttl time.Duration) *IdempotencyUseCase {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &IdempotencyUseCase{repo: repo, ttl: ttl}
+}
+
+// Begin reserves key for the request, or reports what became of the request
+// that reserved it first.
+func (uc *IdempotencyUseCase) Begin(owner, key, fingerprint string) (*domain.IdempotencyRecord, error) {
+	now := time.Now().UTC()
+	rec := &domain.IdempotencyRecord{
+		Owner:       owner,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(uc.ttl),
+	}
+	existing, err := uc.repo.Reserve(rec, now)
+	switch {
+	case err != nil:
+		return nil, err
+	case existing == nil:
+		return rec, nil
+	case existing.Fingerprint != fingerprint:
+		return nil, domain.ErrIdempotencyKeyReused
+	case existing.Response == nil:
+		return nil, domain.ErrIdempotencyKeyInUse
+	}
+	return existing, nil
+}
+
+// Complete stores the response to replay for the key.
+func (uc *IdempotencyUseCase) Complete(owner, key string, resp domain.IdempotentResponse) error {
+	return uc.repo.Complete(owner, key, resp)
+}
+
+// Abandon forgets the reservation, so that a retry carries the request out
+// again. A newer reservation of the same key is left alone.
+func (uc *IdempotencyUseCase) Abandon(rec *domain.IdempotencyRecord) error {
+	return uc.repo.Release(rec)
+}
+
+// PurgeExpired removes keys older than the TTL.
+func (uc *IdempotencyUseCase) PurgeExpired() (int, error) {
+	return uc.repo.PurgeExpired(time.Now().UTC())
+}
+
+// Run purges expired keys every interval until ctx is cancelled. Expired keys
+// are ignored whether or not they have been purged; purging bounds memory.
+func (uc *IdempotencyUseCase) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := uc.PurgeExpired(); err != nil {
+				log.Printf("idempotency purge: %v", err)
+			}
+		}
+	}
+}
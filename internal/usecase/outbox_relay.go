@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// outboxBatchSize caps the events relayed per RelayOnce call.
+const outboxBatchSize = 256
+
+// OutboxRelay moves book events from a repository's outbox to the event
+// publisher and the sinks. Events are marked dispatched only after they were
+// published and every sink accepted them, so delivery is at least once; the
+// event ID lets the publisher, the sinks and downstream consumers drop
+// duplicates.
+type OutboxRelay struct {
+	outbox    domain.BookOutbox
+	publisher domain.BookEventPublisher
+	sinks     []domain.BookEventSink
+	interval  time.Duration
+}
+
+// NewOutboxRelay returns a relay that wakes on every outbox notification and,
+// as a fallback, every interval. Consumers that must not lose events across
+// a restart, such as the webhook queue, are passed as sinks rather than
+// subscribing to the in-memory publisher.
+func NewOutboxRelay(outbox domain.BookOutbox, publisher domain.BookEventPublisher, interval time.Duration, sinks ...domain.BookEventSink) *OutboxRelay {
+	return &OutboxRelay{outbox: outbox, publisher: publisher, sinks: sinks, interval: interval}
+}
+
+// Run relays pending events until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.RelayOnce()
+			if err != nil {
+				log.Printf("outbox relay: %v", err)
+			}
+			if err != nil || n < outboxBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.outbox.Notify():
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events in commit order, hands
+// them to the sinks and marks them dispatched. It returns how many events
+// were relayed. If a sink fails, the events before the failing one are still
+// marked dispatched and the rest stay pending for the next call.
+func (r *OutboxRelay) RelayOnce() (int, error) {
+	pending, err := r.outbox.Pending(outboxBatchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	ids := make([]string, 0, len(pending))
+	for _, event := range pending {
+		// Sinks get the published event, which carries its sequence number.
+		published := r.publisher.Publish(event)
+		if err = r.accept(published); err != nil {
+			break
+		}
+		ids = append(ids, event.ID)
+	}
+	if len(ids) == 0 {
+		return 0, err
+	}
+	return len(ids), errors.Join(err, r.outbox.MarkDispatched(ids...))
+}
+
+func (r *OutboxRelay) accept(event domain.BookEvent) error {
+	for _, sink := range r.sinks {
+		if err := sink.Accept(event); err != nil {
+			return fmt.Errorf("hand over event %s: %w", event.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/eventbus"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// flakyOutbox fails MarkDispatched a set number of times, as if the relay
+// crashed between publishing and acknowledging.
+type flakyOutbox struct {
+	*memory.BookRepository
+	failures int
+}
+
+func (o *flakyOutbox) MarkDispatched(ids ...string) error {
+	if o.failures > 0 {
+		o.failures--
+		return errors.New("ack lost")
+	}
+	return o.BookRepository.MarkDispatched(ids...)
+}
+
+// TestOutboxRelayAtLeastOnce verifies that use-case writes reach subscribers
+// through the relay in commit order, and that an event republished after a
+// lost acknowledgement is delivered only once.
+func TestOutboxRelayAtLeastOnce(t *testing.T) {
+	repo := memory.NewBookRepository()
+	outbox := &flakyOutbox{BookRepository: repo, failures: 1}
+	broker := eventbus.NewBroker(16, 16)
+	relay := usecase.NewOutboxRelay(outbox, broker, time.Hour)
+	uc := usecase.NewBookUseCase(repo, memory.NewRevisionRepository())
+
+	sub := broker.Subscribe(0)
+	defer sub.Close()
+
+	book, _ := uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+	uc.UpdateBook(book.ID, domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1966}, "alice")
+
+	if n, err := relay.RelayOnce(); n != 2 || err == nil {
+		t.Fatalf("first relay: got %d, %v; want 2 and the ack error", n, err)
+	}
+	if n, err := relay.RelayOnce(); n != 2 || err != nil {
+		t.Fatalf("second relay: got %d, %v; want 2, nil", n, err)
+	}
+	if n, _ := relay.RelayOnce(); n != 0 {
+		t.Fatalf("third relay: got %d, want 0", n)
+	}
+
+	var got []domain.BookEvent
+	for len(got) < 2 {
+		select {
+		case e := <-sub.Events():
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d events, want 2", len(got))
+		}
+	}
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("duplicate delivery: %+v", e)
+	default:
+	}
+	if got[0].Type != domain.EventBookCreated || got[1].Type != domain.EventBookUpdated || got[1].Book.Year != 1966 {
+		t.Errorf("events: got %+v", got)
+	}
+}
+
+// TestOutboxRelayRunWakesOnNotify verifies Run relays new events without
+// waiting for its polling interval.
+func TestOutboxRelayRunWakesOnNotify(t *testing.T) {
+	repo := memory.NewBookRepository()
+	broker := eventbus.NewBroker(16, 16)
+	sub := broker.Subscribe(0)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go usecase.NewOutboxRelay(repo, broker, time.Hour).Run(ctx)
+
+	uc := usecase.NewBookUseCase(repo, memory.NewRevisionRepository())
+	uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+
+	select {
+	case e := <-sub.Events():
+		if e.Type != domain.EventBookCreated || e.ID == "" {
+			t.Errorf("event: got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not relayed")
+	}
+}
+
+// flakySink fails a set number of times before accepting events.
+type flakySink struct {
+	failures int
+	accepted []string
+}
+
+func (s *flakySink) Accept(event domain.BookEvent) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("queue unavailable")
+	}
+	s.accepted = append(s.accepted, event.ID)
+	return nil
+}
+
+// TestOutboxRelayWaitsForSinks verifies that an event stays in the outbox
+// until every sink has accepted it.
+func TestOutboxRelayWaitsForSinks(t *testing.T) {
+	repo := memory.NewBookRepository()
+	sink := &flakySink{failures: 1}
+	relay := usecase.NewOutboxRelay(repo, eventbus.NewBroker(16, 16), time.Hour, sink)
+	uc := usecase.NewBookUseCase(repo, memory.NewRevisionRepository())
+	uc.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+
+	if n, err := relay.RelayOnce(); n != 0 || err == nil {
+		t.Fatalf("relay with failing sink: got %d, %v; want 0 and the sink error", n, err)
+	}
+	if pending, _ := repo.Pending(0); len(pending) != 1 {
+		t.Fatalf("pending after sink failure: got %d, want 1", len(pending))
+	}
+	if n, err := relay.RelayOnce(); n != 1 || err != nil {
+		t.Fatalf("second relay: got %d, %v; want 1, nil", n, err)
+	}
+	if pending, _ := repo.Pending(0); len(pending) != 0 || len(sink.accepted) != 1 {
+		t.Errorf("after relay: %d pending, %d accepted; want 0 and 1", len(pending), len(sink.accepted))
+	}
+}
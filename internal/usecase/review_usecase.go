@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ReviewUseCase implements domain.ReviewUseCase.
+// After every review mutation it recomputes the book's rating summary and
+// writes it back onto the book, so that GET /books can sort by rating without
+// joining against the review store. The write-back touches only the rating
+// (see domain.BookRepository.UpdateRating), so it cannot race a book edit.
+type ReviewUseCase struct {
+	reviews domain.ReviewRepository
+	books   domain.BookRepository
+
+	// mu makes CreateReview's book check and insert atomic with respect to
+	// the purge cascade, so no review outlives its book.
+	mu sync.Mutex
+	// summaryMu serialises summary write-backs so two concurrent reviews of the
+	// same book cannot overwrite each other's aggregate with a stale value.
+	summaryMu sync.Mutex
+}
+
+// NewReviewUseCase wires the use-case to its repositories.
+func NewReviewUseCase(reviews domain.ReviewRepository, books domain.BookRepository) *ReviewUseCase {
+	return &ReviewUseCase{reviews: reviews, books: books}
+}
+
+// CreateReview validates input and stores the user's review of a book.
+// Returns domain.ErrConflict if the user has already reviewed the book.
+func (uc *ReviewUseCase) CreateReview(bookID, username string, rating int, body string) (*domain.Review, error) {
+	body = strings.TrimSpace(body)
+	if err := validateReview(rating, body); err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	// Checked under the lock, so a purge cascade waiting for it always runs
+	// after the review is stored.
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		uc.mu.Unlock()
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	review := &domain.Review{
+		ID:        uuid.New().String(),
+		BookID:    bookID,
+		Username:  username,
+		Rating:    rating,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := uc.reviews.Create(review)
+	uc.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.refreshSummary(bookID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// GetReviews lists the reviews of an existing book.
+func (uc *ReviewUseCase) GetReviews(bookID string) ([]*domain.Review, error) {
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		return nil, err
+	}
+	return uc.reviews.ListByBook(bookID)
+}
+
+// GetReviewsByBooks lists the reviews of several books with one repository call.
+func (uc *ReviewUseCase) GetReviewsByBooks(bookIDs []string) (map[string][]*domain.Review, error) {
+	return uc.reviews.ListByBooks(bookIDs)
+}
+
+// UpdateReview replaces the rating and body of a review owned by username.
+func (uc *ReviewUseCase) UpdateReview(bookID, reviewID, username string, rating int, body string) (*domain.Review, error) {
+	body = strings.TrimSpace(body)
+	if err := validateReview(rating, body); err != nil {
+		return nil, err
+	}
+
+	existing, err := uc.ownedReview(bookID, reviewID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *existing
+	updated.Rating = rating
+	updated.Body = body
+	updated.UpdatedAt = time.Now().UTC()
+
+	if err := uc.reviews.Update(&updated); err != nil {
+		return nil, err
+	}
+	if err := uc.refreshSummary(bookID); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteReview removes a review owned by username.
+func (uc *ReviewUseCase) DeleteReview(bookID, reviewID, username string) error {
+	if _, err := uc.ownedReview(bookID, reviewID, username); err != nil {
+		return err
+	}
+	if err := uc.reviews.Delete(reviewID); err != nil {
+		return err
+	}
+	return uc.refreshSummary(bookID)
+}
+
+// ownedReview loads a review and checks that it belongs to the given live
+// book and user.
+func (uc *ReviewUseCase) ownedReview(bookID, reviewID, username string) (*domain.Review, error) {
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		return nil, err
+	}
+	review, err := uc.reviews.GetByID(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.BookID != bookID {
+		return nil, domain.ErrNotFound
+	}
+	if review.Username != username {
+		return nil, domain.ErrForbidden
+	}
+	return review, nil
+}
+
+// refreshSummary recomputes a book's rating aggregate and stores it on the
+// book, even if the book was trashed since the review changed.
+func (uc *ReviewUseCase) refreshSummary(bookID string) error {
+	uc.summaryMu.Lock()
+	defer uc.summaryMu.Unlock()
+
+	summary, err := uc.reviews.Summary(bookID)
+	if err != nil {
+		return err
+	}
+	return uc.books.UpdateRating(bookID, summary)
+}
+
+// Cascade returns the domain.BookDependent that deletes a purged book's
+// reviews. It removes them under the use case's lock, so that a concurrent
+// CreateReview that already found the book cannot store a review after the
+// removal. Register it with the book use case instead of the review
+// repository.
+func (uc *ReviewUseCase) Cascade() domain.BookDependent {
+	return reviewCascade{uc}
+}
+
+type reviewCascade struct{ uc *ReviewUseCase }
+
+func (c reviewCascade) RemoveBook(bookID string) error {
+	c.uc.mu.Lock()
+	defer c.uc.mu.Unlock()
+	return c.uc.reviews.RemoveBook(bookID)
+}
+
+func validateReview(rating int, body string) error {
+	if rating < domain.MinRating || rating > domain.MaxRating {
+		return domain.ErrInvalidData
+	}
+	if utf8.RuneCountInString(body) > domain.MaxReviewBodyLength {
+		return domain.ErrInvalidData
+	}
+	return nil
+}
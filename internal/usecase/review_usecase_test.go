@@ -0,0 +1,179 @@
+package usecase_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// newReviewFixture wires the review and book use cases to shared repositories,
+// with reviews as a dependent of books, and creates one book.
+func newReviewFixture(t *testing.T) (*usecase.ReviewUseCase, *usecase.BookUseCase, *domain.Book) {
+	t.Helper()
+	books := memory.NewBookRepository()
+	reviewUC := usecase.NewReviewUseCase(memory.NewReviewRepository(), books)
+	bookUC := usecase.NewBookUseCase(books, memory.NewRevisionRepository(), reviewUC.Cascade())
+	book, err := bookUC.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+	if err != nil {
+		t.Fatalf("CreateBook: %v", err)
+	}
+	return reviewUC, bookUC, book
+}
+
+func assertRating(t *testing.T, books *usecase.BookUseCase, id string, avg float64, count int) {
+	t.Helper()
+	book, err := books.GetBook(id)
+	if err != nil {
+		t.Fatalf("GetBook: %v", err)
+	}
+	if book.AverageRating != avg || book.ReviewCount != count {
+		t.Errorf("rating: got %.2f over %d, want %.2f over %d", book.AverageRating, book.ReviewCount, avg, count)
+	}
+}
+
+// TestReviewAggregates verifies that creating, updating and deleting reviews
+// recomputes the book's average rating and review count.
+func TestReviewAggregates(t *testing.T) {
+	reviews, books, book := newReviewFixture(t)
+
+	a, err := reviews.CreateReview(book.ID, "alice", 5, "Great")
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	assertRating(t, books, book.ID, 5, 1)
+
+	if _, err := reviews.CreateReview(book.ID, "bob", 2, ""); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	assertRating(t, books, book.ID, 3.5, 2)
+
+	if _, err := reviews.UpdateReview(book.ID, a.ID, "alice", 3, "Fine"); err != nil {
+		t.Fatalf("UpdateReview: %v", err)
+	}
+	assertRating(t, books, book.ID, 2.5, 2)
+
+	if err := reviews.DeleteReview(book.ID, a.ID, "alice"); err != nil {
+		t.Fatalf("DeleteReview: %v", err)
+	}
+	assertRating(t, books, book.ID, 2, 1)
+}
+
+// TestBookEditKeepsRating verifies that a book edit based on a copy fetched
+// before a review does not write back the stale rating.
+func TestBookEditKeepsRating(t *testing.T) {
+	reviews, books, book := newReviewFixture(t)
+
+	// book was fetched before the review, as by a concurrent PUT.
+	if _, err := reviews.CreateReview(book.ID, "alice", 4, ""); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	updated, err := books.PatchBook(book.ID, domain.BookPatch{Title: ptr("Dune Messiah")}, "bob")
+	if err != nil {
+		t.Fatalf("PatchBook: %v", err)
+	}
+	if updated.Title != "Dune Messiah" {
+		t.Errorf("title: got %q", updated.Title)
+	}
+	assertRating(t, books, book.ID, 4, 1)
+}
+
+// TestReviewsOfTrashedBook verifies that reviews of a trashed book cannot be
+// changed, and that they are kept until the book is purged.
+func TestReviewsOfTrashedBook(t *testing.T) {
+	reviews, books, book := newReviewFixture(t)
+	review, _ := reviews.CreateReview(book.ID, "alice", 4, "")
+	if err := books.DeleteBook(book.ID, "alice"); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+
+	if err := reviews.DeleteReview(book.ID, review.ID, "alice"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("DeleteReview of trashed book: got %v, want ErrNotFound", err)
+	}
+	if _, err := books.RestoreBook(book.ID, "alice"); err != nil {
+		t.Fatalf("RestoreBook: %v", err)
+	}
+	if got, _ := reviews.GetReviews(book.ID); len(got) != 1 {
+		t.Fatalf("reviews after restore: got %d, want 1", len(got))
+	}
+	assertRating(t, books, book.ID, 4, 1)
+
+	if err := books.DeleteBook(book.ID, "alice"); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+	if n, err := books.PurgeTrash(time.Now().Add(time.Minute)); n != 1 || err != nil {
+		t.Fatalf("PurgeTrash: got %d, %v", n, err)
+	}
+	if got, _ := reviews.GetReviewsByBooks([]string{book.ID}); len(got) != 0 {
+		t.Errorf("reviews after purge: got %v, want none", got)
+	}
+}
+
+// pausingBooks pauses the first GetByID after it has found the book, until
+// resume is closed.
+type pausingBooks struct {
+	domain.BookRepository
+	once   sync.Once
+	found  chan struct{}
+	resume chan struct{}
+}
+
+func (b *pausingBooks) GetByID(id string) (*domain.Book, error) {
+	book, err := b.BookRepository.GetByID(id)
+	b.once.Do(func() {
+		close(b.found)
+		<-b.resume
+	})
+	return book, err
+}
+
+// signalDependent closes itself when the purge cascade reaches it.
+type signalDependent chan struct{}
+
+func (s signalDependent) RemoveBook(string) error {
+	close(s)
+	return nil
+}
+
+// TestReviewDuringPurge verifies that a review whose book is purged between
+// the book check and the insert does not outlive the book.
+func TestReviewDuringPurge(t *testing.T) {
+	books, reviewRepo := memory.NewBookRepository(), memory.NewReviewRepository()
+	paused := &pausingBooks{BookRepository: books, found: make(chan struct{}), resume: make(chan struct{})}
+	reviewUC := usecase.NewReviewUseCase(reviewRepo, paused)
+	cascading := make(signalDependent)
+	bookUC := usecase.NewBookUseCase(books, memory.NewRevisionRepository(), cascading, reviewUC.Cascade())
+	book, _ := bookUC.CreateBook(domain.BookInput{Title: "Dune", Author: "Frank Herbert", Year: 1965}, "alice")
+
+	created := make(chan struct{})
+	go func() {
+		defer close(created)
+		reviewUC.CreateReview(book.ID, "alice", 5, "")
+	}()
+	<-paused.found
+
+	if err := bookUC.DeleteBook(book.ID, "alice"); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+	purged := make(chan error, 1)
+	go func() {
+		_, err := bookUC.PurgeTrash(time.Now().Add(time.Minute))
+		purged <- err
+	}()
+	<-cascading
+	close(paused.resume)
+	<-created
+	if err := <-purged; err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+
+	if got, _ := reviewRepo.ListByBook(book.ID); len(got) != 0 {
+		t.Errorf("reviews after purge: got %d, want none", len(got))
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,381 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ShelfUseCase implements domain.ShelfUseCase.
+// The built-in shelves are created lazily the first time a user touches their
+// shelves, and are kept mutually exclusive: placing a book on one built-in
+// shelf takes it off the others.
+type ShelfUseCase struct {
+	shelves domain.ShelfRepository
+	books   domain.BookRepository
+
+	// mu serialises shelf mutations; moving a book between built-in shelves
+	// updates several shelves and must not interleave with other moves, and
+	// every read-modify-write of a shelf must not interleave with the purge
+	// cascade (see Cascade).
+	mu sync.Mutex
+}
+
+// NewShelfUseCase wires the use-case to its repositories.
+func NewShelfUseCase(shelves domain.ShelfRepository, books domain.BookRepository) *ShelfUseCase {
+	return &ShelfUseCase{shelves: shelves, books: books}
+}
+
+// GetShelves returns the owner's shelves, built-in ones first.
+func (uc *ShelfUseCase) GetShelves(owner string) ([]*domain.Shelf, error) {
+	if err := uc.ensureDefaults(owner); err != nil {
+		return nil, err
+	}
+	return uc.shelves.ListByOwner(owner)
+}
+
+// CreateShelf creates an empty custom shelf. Returns domain.ErrConflict if the
+// owner already has a shelf with that name.
+func (uc *ShelfUseCase) CreateShelf(owner, name string) (*domain.Shelf, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > domain.MaxShelfNameLength {
+		return nil, domain.ErrInvalidData
+	}
+	if err := uc.ensureDefaults(owner); err != nil {
+		return nil, err
+	}
+
+	shelf := newShelf(owner, name, false)
+	if err := uc.shelves.Create(shelf); err != nil {
+		return nil, err
+	}
+	return shelf, nil
+}
+
+// GetShelf returns one of the owner's shelves.
+func (uc *ShelfUseCase) GetShelf(owner, id string) (*domain.Shelf, error) {
+	return uc.ownedShelf(owner, id)
+}
+
+// DeleteShelf removes a custom shelf. Built-in shelves cannot be deleted.
+func (uc *ShelfUseCase) DeleteShelf(owner, id string) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	shelf, err := uc.ownedShelf(owner, id)
+	if err != nil {
+		return err
+	}
+	if shelf.Default {
+		return domain.ErrForbidden
+	}
+	return uc.shelves.Delete(id)
+}
+
+// AddBook inserts a book at position (0-based; negative or past the end
+// appends). Returns domain.ErrConflict if the book is already on the shelf.
+func (uc *ShelfUseCase) AddBook(owner, shelfID, bookID string, position int) (*domain.Shelf, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	// Checked under the lock, so a purge cascade waiting for it always runs
+	// after the book is added.
+	if _, err := uc.books.GetByID(bookID); err != nil {
+		return nil, err
+	}
+	shelf, err := uc.ownedShelf(owner, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	if indexOf(shelf.BookIDs, bookID) >= 0 {
+		return nil, domain.ErrConflict
+	}
+
+	if shelf.Default {
+		if err := uc.removeFromOtherDefaults(owner, shelf.ID, bookID); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, 0, len(shelf.BookIDs)+1)
+	if position < 0 || position > len(shelf.BookIDs) {
+		position = len(shelf.BookIDs)
+	}
+	ids = append(ids, shelf.BookIDs[:position]...)
+	ids = append(ids, bookID)
+	ids = append(ids, shelf.BookIDs[position:]...)
+
+	return uc.saveBooks(shelf, ids)
+}
+
+// RemoveBook takes a book off a shelf.
+func (uc *ShelfUseCase) RemoveBook(owner, shelfID, bookID string) (*domain.Shelf, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	shelf, err := uc.ownedShelf(owner, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	idx := indexOf(shelf.BookIDs, bookID)
+	if idx < 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	ids := make([]string, 0, len(shelf.BookIDs)-1)
+	ids = append(ids, shelf.BookIDs[:idx]...)
+	ids = append(ids, shelf.BookIDs[idx+1:]...)
+	return uc.saveBooks(shelf, ids)
+}
+
+// ReorderBooks replaces the shelf order. bookIDs must be a permutation of the
+// books currently on the shelf.
+func (uc *ShelfUseCase) ReorderBooks(owner, shelfID string, bookIDs []string) (*domain.Shelf, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	shelf, err := uc.ownedShelf(owner, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	if !samePermutation(shelf.BookIDs, bookIDs) {
+		return nil, domain.ErrInvalidData
+	}
+	return uc.saveBooks(shelf, append([]string(nil), bookIDs...))
+}
+
+// GetProgress counts the books on each built-in shelf.
+func (uc *ShelfUseCase) GetProgress(owner string) (*domain.ShelfProgress, error) {
+	shelves, err := uc.GetShelves(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &domain.ShelfProgress{}
+	for _, s := range shelves {
+		if !s.Default {
+			continue
+		}
+		switch s.Name {
+		case domain.ShelfToRead:
+			p.ToRead = len(s.BookIDs)
+		case domain.ShelfReading:
+			p.Reading = len(s.BookIDs)
+		case domain.ShelfDone:
+			p.Done = len(s.BookIDs)
+		}
+	}
+	p.Total = p.ToRead + p.Reading + p.Done
+	if p.Total > 0 {
+		p.PercentDone = math.Round(float64(p.Done)/float64(p.Total)*10000) / 100
+	}
+	return p, nil
+}
+
+// ShareShelf assigns the shelf a public share token, keeping an existing one.
+func (uc *ShelfUseCase) ShareShelf(owner, id string) (*domain.Shelf, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	shelf, err := uc.ownedShelf(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if shelf.ShareToken != "" {
+		return shelf, nil
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	updated := *shelf
+	updated.ShareToken = token
+	updated.UpdatedAt = time.Now().UTC()
+	if err := uc.shelves.Update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// UnshareShelf revokes the shelf's share token; the old link stops working.
+func (uc *ShelfUseCase) UnshareShelf(owner, id string) (*domain.Shelf, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	shelf, err := uc.ownedShelf(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *shelf
+	updated.ShareToken = ""
+	updated.UpdatedAt = time.Now().UTC()
+	if err := uc.shelves.Update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetSharedShelf resolves a share token to a read-only view of the shelf.
+func (uc *ShelfUseCase) GetSharedShelf(token string) (*domain.SharedShelf, error) {
+	shelf, err := uc.shelves.GetByShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*domain.Book, 0, len(shelf.BookIDs))
+	for _, id := range shelf.BookIDs {
+		book, err := uc.books.GetByID(id)
+		if err == domain.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return &domain.SharedShelf{Name: shelf.Name, Owner: shelf.Owner, Books: books}, nil
+}
+
+// Cascade returns the domain.BookDependent that drops a purged book from every
+// shelf. It removes the book under the use case's lock, so that a concurrent
+// AddBook or ReorderBooks cannot write back a shelf read before the removal.
+// Register it with the book use case instead of the shelf repository.
+func (uc *ShelfUseCase) Cascade() domain.BookDependent {
+	return shelfCascade{uc}
+}
+
+type shelfCascade struct{ uc *ShelfUseCase }
+
+func (c shelfCascade) RemoveBook(bookID string) error {
+	c.uc.mu.Lock()
+	defer c.uc.mu.Unlock()
+	return c.uc.shelves.RemoveBook(bookID)
+}
+
+// ensureDefaults creates any missing built-in shelves for owner. Concurrent
+// callers may race to create the same shelf; the loser's ErrConflict is ignored.
+func (uc *ShelfUseCase) ensureDefaults(owner string) error {
+	existing, err := uc.shelves.ListByOwner(owner)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		if s.Default {
+			have[s.Name] = true
+		}
+	}
+	for _, name := range domain.DefaultShelves {
+		if have[name] {
+			continue
+		}
+		if err := uc.shelves.Create(newShelf(owner, name, true)); err != nil && err != domain.ErrConflict {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedShelf loads a shelf and hides shelves belonging to other users.
+func (uc *ShelfUseCase) ownedShelf(owner, id string) (*domain.Shelf, error) {
+	shelf, err := uc.shelves.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if shelf.Owner != owner {
+		return nil, domain.ErrNotFound
+	}
+	return shelf, nil
+}
+
+// removeFromOtherDefaults takes bookID off every built-in shelf except keepID.
+func (uc *ShelfUseCase) removeFromOtherDefaults(owner, keepID, bookID string) error {
+	shelves, err := uc.shelves.ListByOwner(owner)
+	if err != nil {
+		return err
+	}
+	for _, s := range shelves {
+		if !s.Default || s.ID == keepID {
+			continue
+		}
+		idx := indexOf(s.BookIDs, bookID)
+		if idx < 0 {
+			continue
+		}
+		ids := make([]string, 0, len(s.BookIDs)-1)
+		ids = append(ids, s.BookIDs[:idx]...)
+		ids = append(ids, s.BookIDs[idx+1:]...)
+		if _, err := uc.saveBooks(s, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveBooks stores a copy of shelf with the given book order.
+func (uc *ShelfUseCase) saveBooks(shelf *domain.Shelf, bookIDs []string) (*domain.Shelf, error) {
+	updated := *shelf
+	updated.BookIDs = bookIDs
+	updated.UpdatedAt = time.Now().UTC()
+	if err := uc.shelves.Update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func newShelf(owner, name string, isDefault bool) *domain.Shelf {
+	now := time.Now().UTC()
+	return &domain.Shelf{
+		ID:        uuid.New().String(),
+		Owner:     owner,
+		Name:      name,
+		Default:   isDefault,
+		BookIDs:   []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// newShareToken returns 128 bits of randomness, hex-encoded.
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate share token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func samePermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int, len(a))
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package usecase_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/memory"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// newShelfFixture wires the shelf and book use cases, with the shelf cascade
+// as a dependent of books, and creates n books.
+func newShelfFixture(t *testing.T, n int) (*usecase.ShelfUseCase, *usecase.BookUseCase, []*domain.Book) {
+	t.Helper()
+	books := memory.NewBookRepository()
+	shelves := usecase.NewShelfUseCase(memory.NewShelfRepository(), books)
+	bookUC := usecase.NewBookUseCase(books, memory.NewRevisionRepository(), shelves.Cascade())
+
+	var created []*domain.Book
+	for i := 0; i < n; i++ {
+		book, err := bookUC.CreateBook(domain.BookInput{Title: fmt.Sprintf("Book %d", i), Author: "Frank Herbert"}, "alice")
+		if err != nil {
+			t.Fatalf("CreateBook: %v", err)
+		}
+		created = append(created, book)
+	}
+	return shelves, bookUC, created
+}
+
+// defaultShelves returns the owner's built-in shelves by name.
+func defaultShelves(t *testing.T, uc *usecase.ShelfUseCase, owner string) map[string]*domain.Shelf {
+	t.Helper()
+	shelves, err := uc.GetShelves(owner)
+	if err != nil {
+		t.Fatalf("GetShelves: %v", err)
+	}
+	byName := make(map[string]*domain.Shelf)
+	for _, s := range shelves {
+		if s.Default {
+			byName[s.Name] = s
+		}
+	}
+	return byName
+}
+
+// TestDefaultShelvesAreExclusive verifies that placing a book on a built-in
+// shelf takes it off the others, while custom shelves keep it.
+func TestDefaultShelvesAreExclusive(t *testing.T) {
+	uc, _, books := newShelfFixture(t, 1)
+	id := books[0].ID
+	defaults := defaultShelves(t, uc, "alice")
+	custom, err := uc.CreateShelf("alice", "favourites")
+	if err != nil {
+		t.Fatalf("CreateShelf: %v", err)
+	}
+
+	for _, name := range []string{domain.ShelfToRead, domain.ShelfReading, domain.ShelfDone} {
+		if _, err := uc.AddBook("alice", defaults[name].ID, id, -1); err != nil {
+			t.Fatalf("AddBook(%s): %v", name, err)
+		}
+	}
+	if _, err := uc.AddBook("alice", custom.ID, id, -1); err != nil {
+		t.Fatalf("AddBook(custom): %v", err)
+	}
+
+	defaults = defaultShelves(t, uc, "alice")
+	for name, want := range map[string]int{domain.ShelfToRead: 0, domain.ShelfReading: 0, domain.ShelfDone: 1} {
+		if got := len(defaults[name].BookIDs); got != want {
+			t.Errorf("%s: got %d books, want %d", name, got, want)
+		}
+	}
+	if got, _ := uc.GetShelf("alice", custom.ID); len(got.BookIDs) != 1 {
+		t.Errorf("custom shelf: got %v, want the book", got.BookIDs)
+	}
+	if err := uc.DeleteShelf("alice", defaults[domain.ShelfDone].ID); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("DeleteShelf(built-in): got %v, want ErrForbidden", err)
+	}
+}
+
+// TestShelfProgress verifies the counts and percentage of finished books.
+func TestShelfProgress(t *testing.T) {
+	uc, _, books := newShelfFixture(t, 3)
+	defaults := defaultShelves(t, uc, "alice")
+	uc.AddBook("alice", defaults[domain.ShelfToRead].ID, books[0].ID, -1)
+	uc.AddBook("alice", defaults[domain.ShelfReading].ID, books[1].ID, -1)
+	uc.AddBook("alice", defaults[domain.ShelfDone].ID, books[2].ID, -1)
+
+	got, err := uc.GetProgress("alice")
+	if err != nil {
+		t.Fatalf("GetProgress: %v", err)
+	}
+	want := domain.ShelfProgress{ToRead: 1, Reading: 1, Done: 1, Total: 3, PercentDone: 33.33}
+	if *got != want {
+		t.Errorf("progress: got %+v, want %+v", *got, want)
+	}
+	if got, _ := uc.GetProgress("bob"); *got != (domain.ShelfProgress{}) {
+		t.Errorf("empty progress: got %+v", *got)
+	}
+}
+
+// TestShelfSharing verifies that a share token gives read-only access to the
+// live books of a shelf, that other users cannot see the shelf, and that
+// unsharing revokes the token.
+func TestShelfSharing(t *testing.T) {
+	uc, bookUC, books := newShelfFixture(t, 2)
+	shelf, _ := uc.CreateShelf("alice", "sci-fi")
+	uc.AddBook("alice", shelf.ID, books[0].ID, -1)
+	uc.AddBook("alice", shelf.ID, books[1].ID, -1)
+	bookUC.DeleteBook(books[1].ID, "alice")
+
+	if _, err := uc.ShareShelf("bob", shelf.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ShareShelf by another user: got %v, want ErrNotFound", err)
+	}
+	shared, err := uc.ShareShelf("alice", shelf.ID)
+	if err != nil || shared.ShareToken == "" {
+		t.Fatalf("ShareShelf: got %+v, %v", shared, err)
+	}
+	if again, _ := uc.ShareShelf("alice", shelf.ID); again.ShareToken != shared.ShareToken {
+		t.Errorf("resharing changed the token")
+	}
+
+	view, err := uc.GetSharedShelf(shared.ShareToken)
+	if err != nil {
+		t.Fatalf("GetSharedShelf: %v", err)
+	}
+	if view.Name != "sci-fi" || view.Owner != "alice" || len(view.Books) != 1 || view.Books[0].ID != books[0].ID {
+		t.Errorf("shared view: got %+v, want the one live book", view)
+	}
+
+	if _, err := uc.UnshareShelf("alice", shelf.ID); err != nil {
+		t.Fatalf("UnshareShelf: %v", err)
+	}
+	if _, err := uc.GetSharedShelf(shared.ShareToken); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetSharedShelf after unshare: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestShelfPurgeCascade verifies that purging a book removes it from every
+// shelf and that it cannot be added back.
+func TestShelfPurgeCascade(t *testing.T) {
+	uc, bookUC, books := newShelfFixture(t, 2)
+	defaults := defaultShelves(t, uc, "alice")
+	custom, _ := uc.CreateShelf("alice", "favourites")
+	for _, id := range []string{defaults[domain.ShelfReading].ID, custom.ID} {
+		uc.AddBook("alice", id, books[0].ID, -1)
+		uc.AddBook("alice", id, books[1].ID, -1)
+	}
+
+	bookUC.DeleteBook(books[0].ID, "alice")
+	if n, err := bookUC.PurgeTrash(time.Now().Add(time.Minute)); n != 1 || err != nil {
+		t.Fatalf("PurgeTrash: got %d, %v", n, err)
+	}
+	for _, id := range []string{defaults[domain.ShelfReading].ID, custom.ID} {
+		if got, _ := uc.GetShelf("alice", id); len(got.BookIDs) != 1 || got.BookIDs[0] != books[1].ID {
+			t.Errorf("shelf %s after purge: got %v", id, got.BookIDs)
+		}
+	}
+	if _, err := uc.AddBook("alice", custom.ID, books[0].ID, 0); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("AddBook(purged): got %v, want ErrNotFound", err)
+	}
+}
+
+// TestShelvesAreCopied verifies that modifying a returned shelf changes
+// neither the stored shelf nor what the next caller sees.
+func TestShelvesAreCopied(t *testing.T) {
+	uc, _, books := newShelfFixture(t, 1)
+	shelf, _ := uc.CreateShelf("alice", "favourites")
+	got, _ := uc.AddBook("alice", shelf.ID, books[0].ID, -1)
+
+	got.BookIDs[0] = "tampered"
+	got.Name = "tampered"
+	if again, _ := uc.GetShelf("alice", shelf.ID); again.Name != "favourites" || again.BookIDs[0] != books[0].ID {
+		t.Errorf("stored shelf changed: got %+v", again)
+	}
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+// TrashPurger periodically hard-deletes books that have been in the trash for
+// longer than the retention window.
+type TrashPurger struct {
+	books     domain.BookUseCase
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewTrashPurger returns a purger that runs every interval and removes books
+// trashed more than retention ago.
+func NewTrashPurger(books domain.BookUseCase, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{books: books, retention: retention, interval: interval}
+}
+
+// Run purges once immediately and then on every tick until ctx is cancelled.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.PurgeOnce(time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce removes books trashed before now minus the retention window.
+func (p *TrashPurger) PurgeOnce(now time.Time) int {
+	n, err := p.books.PurgeTrash(now.Add(-p.retention))
+	if err != nil {
+		log.Printf("trash purge: %v", err)
+	}
+	if n > 0 {
+		log.Printf("trash purge: permanently deleted %d book(s)", n)
+	}
+	return n
+}
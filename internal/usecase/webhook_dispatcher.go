@@ -0,0 +1,385 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/google/uuid"
+)
+
+// Headers sent with every webhook request.
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookDispatcherConfig tunes delivery. Zero fields take the values from
+// DefaultWebhookDispatcherConfig.
+type WebhookDispatcherConfig struct {
+	// MaxAttempts is how many consecutive failures send a delivery to the
+	// dead-letter list.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; each further retry
+	// doubles it, up to MaxDelay. Every wait is jittered into [d/2, d].
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Timeout bounds a single HTTP request.
+	Timeout time.Duration
+	// PollInterval is how often the queue is checked for due deliveries.
+	PollInterval time.Duration
+	// Workers is how many deliveries are sent concurrently.
+	Workers int
+	// BatchSize caps the deliveries picked up per poll.
+	BatchSize int
+	// AllowPrivateTargets lets requests reach loopback, private and
+	// link-local addresses. It is meant for tests and for deployments whose
+	// receivers live on the internal network; otherwise a webhook could make
+	// the API call its own infrastructure.
+	AllowPrivateTargets bool
+}
+
+// DefaultWebhookDispatcherConfig retries for roughly a day before giving up.
+func DefaultWebhookDispatcherConfig() WebhookDispatcherConfig {
+	return WebhookDispatcherConfig{
+		MaxAttempts:  12,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     6 * time.Hour,
+		Timeout:      10 * time.Second,
+		PollInterval: time.Second,
+		Workers:      4,
+		BatchSize:    100,
+	}
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook.
+type WebhookPayload struct {
+	ID        string           `json:"id"`
+	WebhookID string           `json:"webhook_id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      domain.BookEvent `json:"data"`
+}
+
+// WebhookDispatcher turns book events into queued deliveries and sends them.
+// It is a domain.BookEventSink: the outbox relay hands it every event before
+// marking the event dispatched, so no event is lost between the two.
+type WebhookDispatcher struct {
+	hooks      domain.WebhookRepository
+	deliveries domain.WebhookDeliveryRepository
+	cfg        WebhookDispatcherConfig
+	client     *http.Client
+
+	// Jitter maps a backoff delay to the delay actually used. Tests replace
+	// it to make retry times predictable.
+	Jitter func(time.Duration) time.Duration
+}
+
+// NewWebhookDispatcher wires the dispatcher to its repositories.
+func NewWebhookDispatcher(hooks domain.WebhookRepository, deliveries domain.WebhookDeliveryRepository, cfg WebhookDispatcherConfig) *WebhookDispatcher {
+	def := DefaultWebhookDispatcherConfig()
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = def.BaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = def.MaxDelay
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		// Check the address actually dialled, after DNS resolution and on
+		// every redirect, so a public name cannot point at a private host.
+		dialer := &net.Dialer{Timeout: cfg.Timeout, Control: publicTargetsOnly}
+		transport.DialContext = dialer.DialContext
+		// A proxy would connect on our behalf, bypassing the check.
+		transport.Proxy = nil
+	}
+	return &WebhookDispatcher{
+		hooks:      hooks,
+		deliveries: deliveries,
+		cfg:        cfg,
+		client:     &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		Jitter:     equalJitter,
+	}
+}
+
+// Run sends due deliveries until ctx is cancelled. Deliveries are queued by
+// Accept, which the outbox relay calls.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.DeliverDue(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// Accept implements domain.BookEventSink by queueing event.
+func (d *WebhookDispatcher) Accept(event domain.BookEvent) error {
+	_, err := d.Enqueue(event)
+	return err
+}
+
+// Enqueue queues event for every active webhook subscribed to its type and
+// returns how many deliveries were created. A delivery's ID is derived from
+// the event ID and the webhook ID, so queueing the same event again creates
+// no duplicates.
+func (d *WebhookDispatcher) Enqueue(event domain.BookEvent) (int, error) {
+	hooks, err := d.hooks.List("")
+	if err != nil {
+		return 0, err
+	}
+
+	var queued int
+	for _, hook := range hooks {
+		if !hook.Active || !hook.Subscribes(event.Type) {
+			continue
+		}
+		now := time.Now().UTC()
+		payload := WebhookPayload{
+			ID:        deliveryID(event, hook),
+			WebhookID: hook.ID,
+			Type:      event.Type,
+			CreatedAt: now,
+			Data:      event,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return queued, fmt.Errorf("encode webhook payload: %w", err)
+		}
+		delivery := &domain.WebhookDelivery{
+			ID:            payload.ID,
+			WebhookID:     hook.ID,
+			EventSeq:      event.Seq,
+			EventType:     event.Type,
+			Payload:       body,
+			Status:        domain.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := d.deliveries.Enqueue(delivery); err != nil {
+			if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrConflict) {
+				continue // webhook deleted since List, or event already queued
+			}
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// DeliverDue attempts every delivery due at now and returns how many
+// succeeded.
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context, now time.Time) int {
+	due, err := d.deliveries.Due(now, d.cfg.BatchSize)
+	if err != nil {
+		log.Printf("webhooks: load due deliveries: %v", err)
+		return 0
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	slots := make(chan struct{}, d.cfg.Workers)
+	for _, delivery := range due {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery *domain.WebhookDelivery) {
+			defer func() { <-slots; wg.Done() }()
+			if d.attempt(ctx, delivery, now) {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return succeeded
+}
+
+// attempt sends one delivery and records the outcome.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *domain.WebhookDelivery, now time.Time) bool {
+	hook, err := d.hooks.GetByID(delivery.WebhookID)
+	if err != nil {
+		return false // deleted together with its deliveries
+	}
+	if !hook.Active {
+		// Paused: keep the delivery without spending its retry budget.
+		delivery.NextAttemptAt = now.Add(d.cfg.MaxDelay)
+		d.save(delivery)
+		return false
+	}
+
+	started := time.Now()
+	status, sendErr := d.send(ctx, hook, delivery)
+	record := domain.DeliveryAttempt{
+		At:         now,
+		StatusCode: status,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+
+	delivery.History = append(delivery.History, record)
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = domain.DeliverySucceeded
+		delivery.NextAttemptAt = time.Time{}
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = domain.DeliveryDead
+		delivery.NextAttemptAt = time.Time{}
+	default:
+		delivery.NextAttemptAt = now.Add(d.Jitter(d.backoff(delivery.Attempts)))
+	}
+	d.save(delivery)
+	return sendErr == nil
+}
+
+// save records the outcome of an attempt. A delivery deleted with its
+// webhook is gone; one changed since it was loaded, for instance queued again
+// by a redelivery, keeps its newer state and this attempt goes unrecorded.
+func (d *WebhookDispatcher) save(delivery *domain.WebhookDelivery) {
+	err := d.deliveries.UpdateDelivery(delivery)
+	switch {
+	case err == nil, errors.Is(err, domain.ErrNotFound):
+	case errors.Is(err, domain.ErrConflict):
+		log.Printf("webhooks: delivery %s changed during an attempt; outcome not recorded", delivery.ID)
+	default:
+		log.Printf("webhooks: save delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// send POSTs the delivery. Any non-2xx status is an error; the status code is
+// returned whenever a response was received.
+func (d *WebhookDispatcher) send(ctx context.Context, hook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crud-test-webhooks/1")
+	req.Header.Set(WebhookIDHeader, hook.ID)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliveryNamespace scopes the name-based UUIDs of deliveries.
+var deliveryNamespace = uuid.MustParse("6f1c2a8e-4b5d-4f7a-9c3e-2d8b1a0e5f47")
+
+// deliveryID is a UUID derived from the event and webhook IDs, or a random
+// one for events without an ID.
+func deliveryID(event domain.BookEvent, hook *domain.Webhook) string {
+	if event.ID == "" {
+		return uuid.New().String()
+	}
+	return uuid.NewSHA1(deliveryNamespace, []byte(event.ID+"/"+hook.ID)).String()
+}
+
+// publicTargetsOnly is a net.Dialer Control function refusing connections to
+// addresses that are not public; see publicAddr.
+func publicTargetsOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addr) {
+		return fmt.Errorf("webhook target %s is not a public address", addr)
+	}
+	return nil
+}
+
+// backoff is the un-jittered wait after the given number of failed attempts.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseDelay
+	for i := 1; i < attempts && delay < d.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxDelay)
+}
+
+// equalJitter spreads retries over [d/2, d] so receivers recovering from an
+// outage are not hit by every queued delivery at once.
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half+1)
+}
+
+// SignWebhookPayload returns the X-Webhook-Signature value for body sent at
+// timestamp: "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with secret. Receivers recompute it, compare in
+// constant time and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,338 @@
+package usecase_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/repository/filesystem"
+	"github.com/andrimuhayat/crud-test/internal/usecase"
+)
+
+// receiver is an httptest webhook endpoint that verifies signatures and
+// answers with the next queued status code (200 once the queue is empty).
+type receiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	received []usecase.WebhookPayload
+
+	// onRequest, if set, runs while a request is being served.
+	onRequest func()
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ts, err := strconv.ParseInt(r.Header.Get(usecase.WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		rv.t.Errorf("timestamp header: %v", err)
+	}
+	want := usecase.SignWebhookPayload(rv.secret, ts, body)
+	if !hmac.Equal([]byte(r.Header.Get(usecase.WebhookSignatureHeader)), []byte(want)) {
+		rv.t.Errorf("signature: got %q, want %q", r.Header.Get(usecase.WebhookSignatureHeader), want)
+	}
+
+	var payload usecase.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		rv.t.Errorf("payload: %v", err)
+	}
+	if r.Header.Get(usecase.WebhookDeliveryHeader) != payload.ID {
+		rv.t.Errorf("delivery header %q does not match payload id %q", r.Header.Get(usecase.WebhookDeliveryHeader), payload.ID)
+	}
+
+	if rv.onRequest != nil {
+		rv.onRequest()
+	}
+	rv.mu.Lock()
+	rv.received = append(rv.received, payload)
+	status := http.StatusOK
+	if len(rv.statuses) > 0 {
+		status, rv.statuses = rv.statuses[0], rv.statuses[1:]
+	}
+	rv.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+type webhookFixture struct {
+	uc         *usecase.WebhookUseCase
+	dispatcher *usecase.WebhookDispatcher
+	hook       *domain.Webhook
+	rv         *receiver
+	repo       *filesystem.WebhookRepository
+}
+
+func newWebhookFixture(t *testing.T, statuses ...int) *webhookFixture {
+	t.Helper()
+	repo, err := filesystem.NewWebhookRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWebhookRepository: %v", err)
+	}
+	rv := &receiver{t: t, statuses: statuses}
+	srv := httptest.NewServer(rv)
+	t.Cleanup(srv.Close)
+
+	uc := usecase.NewWebhookUseCase(repo, repo)
+	uc.AllowPrivateTargets = true // the receiver listens on loopback
+	hook, err := uc.CreateWebhook("alice", srv.URL, []string{domain.EventBookCreated, domain.EventBookDeleted})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	rv.secret = hook.Secret
+
+	d := usecase.NewWebhookDispatcher(repo, repo, usecase.WebhookDispatcherConfig{
+		MaxAttempts:         3,
+		BaseDelay:           time.Minute,
+		MaxDelay:            time.Hour,
+		AllowPrivateTargets: true,
+	})
+	d.Jitter = func(d time.Duration) time.Duration { return d }
+	return &webhookFixture{uc: uc, dispatcher: d, hook: hook, rv: rv, repo: repo}
+}
+
+func bookEvent(seq uint64, eventType string) domain.BookEvent {
+	return domain.BookEvent{
+		Seq:    seq,
+		Type:   eventType,
+		BookID: "b1",
+		Book:   &domain.Book{ID: "b1", Title: "Dune", Author: "Frank Herbert"},
+	}
+}
+
+// TestWebhookDeliversSignedPayload verifies subscribed events are POSTed with
+// a valid signature and unsubscribed ones are not queued.
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	f := newWebhookFixture(t)
+
+	if n, _ := f.dispatcher.Enqueue(bookEvent(1, domain.EventBookCreated)); n != 1 {
+		t.Fatalf("Enqueue created: got %d deliveries, want 1", n)
+	}
+	if n, _ := f.dispatcher.Enqueue(bookEvent(2, domain.EventBookUpdated)); n != 0 {
+		t.Fatalf("Enqueue updated: got %d deliveries, want 0", n)
+	}
+
+	if n := f.dispatcher.DeliverDue(context.Background(), time.Now().UTC()); n != 1 {
+		t.Fatalf("DeliverDue: got %d, want 1", n)
+	}
+	if len(f.rv.received) != 1 || f.rv.received[0].Type != domain.EventBookCreated || f.rv.received[0].Data.BookID != "b1" {
+		t.Fatalf("received: got %+v", f.rv.received)
+	}
+
+	deliveries, _ := f.uc.GetDeliveries("alice", f.hook.ID, domain.DeliverySucceeded)
+	if len(deliveries) != 1 || len(deliveries[0].History) != 1 || deliveries[0].History[0].StatusCode != http.StatusOK {
+		t.Errorf("delivery log: got %+v", deliveries)
+	}
+}
+
+// TestWebhookRetriesWithBackoffThenDeadLetters verifies failed deliveries are
+// rescheduled with exponential backoff, dead-lettered after MaxAttempts, and
+// can be redelivered by hand with the same delivery ID.
+func TestWebhookRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	f := newWebhookFixture(t, 500, 503, 500)
+	ctx := context.Background()
+
+	f.dispatcher.Enqueue(bookEvent(1, domain.EventBookDeleted))
+	now := time.Now().UTC()
+
+	wantDelays := []time.Duration{time.Minute, 2 * time.Minute}
+	for i, delay := range wantDelays {
+		if n := f.dispatcher.DeliverDue(ctx, now); n != 0 {
+			t.Fatalf("attempt %d: got %d successes, want 0", i+1, n)
+		}
+		pending, _ := f.uc.GetDeliveries("alice", f.hook.ID, domain.DeliveryPending)
+		if len(pending) != 1 {
+			t.Fatalf("attempt %d: got %d pending, want 1", i+1, len(pending))
+		}
+		if got := pending[0].NextAttemptAt.Sub(now); got != delay {
+			t.Errorf("attempt %d: next attempt in %v, want %v", i+1, got, delay)
+		}
+		if n := f.dispatcher.DeliverDue(ctx, now.Add(delay-time.Second)); n != 0 || len(f.rv.received) != i+1 {
+			t.Fatalf("attempt %d: delivered before backoff elapsed", i+1)
+		}
+		now = now.Add(delay)
+	}
+
+	f.dispatcher.DeliverDue(ctx, now)
+	dead, _ := f.uc.GetDeadLetters("alice")
+	if len(dead) != 1 || dead[0].Attempts != 3 || len(dead[0].History) != 3 {
+		t.Fatalf("dead letters: got %+v, want one delivery with 3 attempts", dead)
+	}
+	if other, _ := f.uc.GetDeadLetters("bob"); len(other) != 0 {
+		t.Errorf("dead letters for another user: got %d, want 0", len(other))
+	}
+
+	if _, err := f.uc.Redeliver("bob", f.hook.ID, dead[0].ID); err != domain.ErrNotFound {
+		t.Errorf("Redeliver by non-owner: got %v, want ErrNotFound", err)
+	}
+	if _, err := f.uc.Redeliver("alice", f.hook.ID, dead[0].ID); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if _, err := f.uc.Redeliver("alice", f.hook.ID, dead[0].ID); err != domain.ErrConflict {
+		t.Errorf("Redeliver pending: got %v, want ErrConflict", err)
+	}
+	if n := f.dispatcher.DeliverDue(ctx, time.Now().UTC()); n != 1 {
+		t.Fatalf("DeliverDue after redeliver: got %d, want 1", n)
+	}
+
+	ids := map[string]bool{}
+	for _, p := range f.rv.received {
+		ids[p.ID] = true
+	}
+	if len(f.rv.received) != 4 || len(ids) != 1 {
+		t.Errorf("received %d requests with %d distinct IDs, want 4 with 1", len(f.rv.received), len(ids))
+	}
+	got, _ := f.uc.GetDelivery("alice", f.hook.ID, dead[0].ID)
+	if got.Status != domain.DeliverySucceeded || len(got.History) != 4 {
+		t.Errorf("after redelivery: got status %q with %d attempts logged", got.Status, len(got.History))
+	}
+}
+
+// TestWebhookQueueSurvivesRestart verifies pending deliveries are reloaded
+// from disk and that deleting a webhook drops its queue.
+func TestWebhookQueueSurvivesRestart(t *testing.T) {
+	path := t.TempDir()
+	repo, _ := filesystem.NewWebhookRepository(path)
+	uc := usecase.NewWebhookUseCase(repo, repo)
+	hook, err := uc.CreateWebhook("alice", "https://example.com/hook", nil)
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	usecase.NewWebhookDispatcher(repo, repo, usecase.WebhookDispatcherConfig{}).Enqueue(bookEvent(7, domain.EventBookUpdated))
+
+	reopened, err := filesystem.NewWebhookRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	due, _ := reopened.Due(time.Now().Add(time.Second), 0)
+	if len(due) != 1 || due[0].EventSeq != 7 {
+		t.Fatalf("Due after reopen: got %+v, want the seq 7 delivery", due)
+	}
+	stored, _ := reopened.GetByID(hook.ID)
+	if stored.Secret != hook.Secret {
+		t.Error("secret was not persisted")
+	}
+
+	if err := usecase.NewWebhookUseCase(reopened, reopened).DeleteWebhook("alice", hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if due, _ := reopened.Due(time.Now().Add(time.Second), 0); len(due) != 0 {
+		t.Errorf("Due after delete: got %d, want 0", len(due))
+	}
+}
+
+// TestWebhookValidation verifies URLs and event types are checked and that
+// secrets are hidden outside the create response.
+func TestWebhookValidation(t *testing.T) {
+	repo, _ := filesystem.NewWebhookRepository(t.TempDir())
+	uc := usecase.NewWebhookUseCase(repo, repo)
+
+	for _, tc := range []struct {
+		url    string
+		events []string
+	}{
+		{"", nil},
+		{"ftp://example.com/hook", nil},
+		{"/relative", nil},
+		{"https://example.com/hook", []string{"book.exploded"}},
+		{"http://localhost:8080/hook", nil},
+		{"http://api.localhost/hook", nil},
+		{"http://127.0.0.1/hook", nil},
+		{"http://[::1]/hook", nil},
+		{"http://10.0.0.8/hook", nil},
+		{"http://172.16.4.2/hook", nil},
+		{"http://192.168.1.1/hook", nil},
+		{"http://169.254.169.254/latest/meta-data", nil},
+		{"http://[fe80::1]/hook", nil},
+		{"http://[::ffff:127.0.0.1]/hook", nil},
+		{"http://0.0.0.0/hook", nil},
+	} {
+		if _, err := uc.CreateWebhook("alice", tc.url, tc.events); err != domain.ErrInvalidData {
+			t.Errorf("CreateWebhook(%q, %v): got %v, want ErrInvalidData", tc.url, tc.events, err)
+		}
+	}
+
+	hook, err := uc.CreateWebhook("alice", "https://example.com/hook", nil)
+	if err != nil || hook.Secret == "" {
+		t.Fatalf("CreateWebhook: got %+v, %v; want a secret", hook, err)
+	}
+	got, _ := uc.GetWebhook("alice", hook.ID)
+	if got.Secret != "" {
+		t.Error("GetWebhook exposed the secret")
+	}
+	if _, err := uc.GetWebhook("bob", hook.ID); err != domain.ErrNotFound {
+		t.Errorf("GetWebhook by non-owner: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestWebhookRefusesPrivateTargets verifies that the dispatcher does not
+// connect to a private address even when the URL passed validation, as a
+// public name resolving to one would.
+func TestWebhookRefusesPrivateTargets(t *testing.T) {
+	f := newWebhookFixture(t)
+	d := usecase.NewWebhookDispatcher(f.repo, f.repo, usecase.WebhookDispatcherConfig{MaxAttempts: 3})
+
+	d.Enqueue(bookEvent(1, domain.EventBookCreated))
+	if n := d.DeliverDue(context.Background(), time.Now().UTC()); n != 0 {
+		t.Fatalf("DeliverDue: got %d successes, want 0", n)
+	}
+	if len(f.rv.received) != 0 {
+		t.Fatalf("receiver on loopback got %d requests", len(f.rv.received))
+	}
+	pending, _ := f.uc.GetDeliveries("alice", f.hook.ID, domain.DeliveryPending)
+	if len(pending) != 1 || len(pending[0].History) != 1 || !strings.Contains(pending[0].History[0].Error, "not a public address") {
+		t.Errorf("delivery: got %+v, want a failed attempt naming the address", pending)
+	}
+}
+
+// TestWebhookEnqueueIsIdempotent verifies that an event handed over twice,
+// as after a crash before the outbox acknowledged it, is queued once.
+func TestWebhookEnqueueIsIdempotent(t *testing.T) {
+	f := newWebhookFixture(t)
+	event := bookEvent(1, domain.EventBookCreated)
+	event.ID = "event-1"
+
+	for i, want := range []int{1, 0} {
+		if n, err := f.dispatcher.Enqueue(event); n != want || err != nil {
+			t.Errorf("Enqueue %d: got %d, %v; want %d, nil", i+1, n, err, want)
+		}
+	}
+	if err := f.dispatcher.Accept(event); err != nil {
+		t.Errorf("Accept of a queued event: %v", err)
+	}
+	if all, _ := f.uc.GetDeliveries("alice", f.hook.ID, ""); len(all) != 1 {
+		t.Errorf("deliveries: got %d, want 1", len(all))
+	}
+}
+
+// TestWebhookRedeliverDuringAttempt verifies that an attempt that finishes
+// after its delivery was changed does not overwrite the newer state.
+func TestWebhookRedeliverDuringAttempt(t *testing.T) {
+	f := newWebhookFixture(t, 500)
+	f.dispatcher.Enqueue(bookEvent(1, domain.EventBookCreated))
+	delivery, _ := f.repo.Due(time.Now().Add(time.Second), 1)
+
+	// Another writer updates the delivery while the receiver is called.
+	f.rv.onRequest = func() {
+		current, _ := f.repo.GetDelivery(delivery[0].ID)
+		current.Status = domain.DeliveryDead
+		if err := f.repo.UpdateDelivery(current); err != nil {
+			t.Errorf("concurrent update: %v", err)
+		}
+	}
+	f.dispatcher.DeliverDue(context.Background(), time.Now().UTC())
+
+	got, _ := f.repo.GetDelivery(delivery[0].ID)
+	if got.Status != domain.DeliveryDead || got.Attempts != 0 || got.Version != 1 {
+		t.Errorf("delivery: got status %q, %d attempts, version %d; want the concurrent update", got.Status, got.Attempts, got.Version)
+	}
+}
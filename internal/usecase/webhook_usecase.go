@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/google/uuid"
+)
+
+// maxWebhookURLLength caps webhook target URLs.
+const maxWebhookURLLength = 2048
+
+// WebhookUseCase implements domain.WebhookUseCase.
+type WebhookUseCase struct {
+	hooks      domain.WebhookRepository
+	deliveries domain.WebhookDeliveryRepository
+
+	// AllowPrivateTargets accepts URLs naming loopback, private and
+	// link-local hosts. It must match the dispatcher's setting of the same
+	// name, which enforces the rule for names that resolve to such hosts.
+	AllowPrivateTargets bool
+}
+
+// NewWebhookUseCase wires the use-case to its repositories.
+func NewWebhookUseCase(hooks domain.WebhookRepository, deliveries domain.WebhookDeliveryRepository) *WebhookUseCase {
+	return &WebhookUseCase{hooks: hooks, deliveries: deliveries}
+}
+
+// CreateWebhook registers an active webhook with a freshly generated signing
+// secret. The returned webhook is the only place the secret is shown.
+func (uc *WebhookUseCase) CreateWebhook(owner, rawURL string, events []string) (*domain.Webhook, error) {
+	if err := uc.validateWebhook(rawURL, events); err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	hook := &domain.Webhook{
+		ID:        uuid.New().String(),
+		Owner:     owner,
+		URL:       rawURL,
+		Events:    normaliseEvents(events),
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := uc.hooks.Create(hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// GetWebhooks returns the owner's webhooks, oldest first, without secrets.
+func (uc *WebhookUseCase) GetWebhooks(owner string) ([]*domain.Webhook, error) {
+	hooks, err := uc.hooks.List(owner)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+	return hooks, nil
+}
+
+// GetWebhook returns one of the owner's webhooks without its secret.
+func (uc *WebhookUseCase) GetWebhook(owner, id string) (*domain.Webhook, error) {
+	hook, err := uc.ownedWebhook(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// UpdateWebhook replaces the URL, event list and active flag. Deliveries that
+// are already queued, including retries, go to the new URL.
+func (uc *WebhookUseCase) UpdateWebhook(owner, id, rawURL string, events []string, active bool) (*domain.Webhook, error) {
+	if err := uc.validateWebhook(rawURL, events); err != nil {
+		return nil, err
+	}
+	hook, err := uc.ownedWebhook(owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	hook.URL = rawURL
+	hook.Events = normaliseEvents(events)
+	hook.Active = active
+	hook.UpdatedAt = time.Now().UTC()
+	if err := uc.hooks.Update(hook); err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// DeleteWebhook removes the webhook and its delivery log.
+func (uc *WebhookUseCase) DeleteWebhook(owner, id string) error {
+	if _, err := uc.ownedWebhook(owner, id); err != nil {
+		return err
+	}
+	return uc.hooks.Delete(id)
+}
+
+// GetDeliveries lists a webhook's deliveries newest first, optionally limited
+// to one status.
+func (uc *WebhookUseCase) GetDeliveries(owner, webhookID, status string) ([]*domain.WebhookDelivery, error) {
+	if !validDeliveryStatus(status) {
+		return nil, domain.ErrInvalidData
+	}
+	if _, err := uc.ownedWebhook(owner, webhookID); err != nil {
+		return nil, err
+	}
+	return uc.deliveries.ListDeliveries(domain.DeliveryFilter{WebhookIDs: []string{webhookID}, Status: status})
+}
+
+// GetDelivery returns a single delivery of one of the owner's webhooks.
+func (uc *WebhookUseCase) GetDelivery(owner, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	if _, err := uc.ownedWebhook(owner, webhookID); err != nil {
+		return nil, err
+	}
+	delivery, err := uc.deliveries.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, domain.ErrNotFound
+	}
+	return delivery, nil
+}
+
+// GetDeadLetters lists dead deliveries across all of the owner's webhooks,
+// newest first.
+func (uc *WebhookUseCase) GetDeadLetters(owner string) ([]*domain.WebhookDelivery, error) {
+	hooks, err := uc.hooks.List(owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		// An empty ID filter would match every user's deliveries.
+		return []*domain.WebhookDelivery{}, nil
+	}
+	ids := make([]string, len(hooks))
+	for i, h := range hooks {
+		ids[i] = h.ID
+	}
+	return uc.deliveries.ListDeliveries(domain.DeliveryFilter{WebhookIDs: ids, Status: domain.DeliveryDead})
+}
+
+// Redeliver puts a finished delivery back in the queue for an immediate
+// attempt with a fresh retry budget. The payload, and therefore the delivery
+// ID the receiver deduplicates on, is unchanged. Returns domain.ErrConflict
+// if the delivery is pending or changed while being requeued.
+func (uc *WebhookUseCase) Redeliver(owner, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	delivery, err := uc.GetDelivery(owner, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == domain.DeliveryPending {
+		return nil, domain.ErrConflict
+	}
+
+	now := time.Now().UTC()
+	delivery.Status = domain.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := uc.deliveries.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ownedWebhook loads a webhook and hides it from anyone but its owner.
+func (uc *WebhookUseCase) ownedWebhook(owner, id string) (*domain.Webhook, error) {
+	hook, err := uc.hooks.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.Owner != owner {
+		return nil, domain.ErrNotFound
+	}
+	return hook, nil
+}
+
+// validateWebhook accepts absolute http(s) URLs and known event types. Unless
+// private targets are allowed, a URL naming localhost or a non-public IP
+// address is rejected here; names resolving to one are refused by the
+// dispatcher when it connects.
+func (uc *WebhookUseCase) validateWebhook(rawURL string, events []string) error {
+	if rawURL == "" || len(rawURL) > maxWebhookURLLength {
+		return domain.ErrInvalidData
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return domain.ErrInvalidData
+	}
+	if !uc.AllowPrivateTargets {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return domain.ErrInvalidData
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+			return domain.ErrInvalidData
+		}
+	}
+	for _, e := range events {
+		if !knownWebhookEvent(e) {
+			return domain.ErrInvalidData
+		}
+	}
+	return nil
+}
+
+// publicAddr reports whether addr may receive webhooks: it must not be
+// loopback, private, link-local, multicast or unspecified.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+func knownWebhookEvent(eventType string) bool {
+	for _, e := range domain.WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validDeliveryStatus(status string) bool {
+	switch status {
+	case "", domain.DeliveryPending, domain.DeliverySucceeded, domain.DeliveryDead:
+		return true
+	}
+	return false
+}
+
+// normaliseEvents drops duplicates and never returns nil, so an empty list is
+// stored (and rendered) as [] meaning "all events".
+func normaliseEvents(events []string) []string {
+	out := make([]string, 0, len(events))
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
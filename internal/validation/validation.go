@@ -0,0 +1,261 @@
+// Package validation checks structs against rules declared in their
+// `validate` field tags, normalizing values as it goes, and reports every
+// violation at once as a *domain.ValidationError.
+//
+// Rules are comma-separated and applied in order:
+//
+//	trim       trim a string and collapse inner runs of whitespace to one space
+//	required   the value must not be the zero value (after trim)
+//	omitempty  skip the remaining rules when the value is the zero value
+//	min=N      strings: at least N characters; integers: at least N
+//	max=N      strings: at most N characters; integers: at most N;
+//	           string slices: at most N elements
+//	year       an integer from domain.MinBookYear to next year
+//	isbn       a valid ISBN-10 or ISBN-13; hyphens and spaces are removed
+//	tags       a string slice of tags, each trimmed as by trim and lowered,
+//	           non-empty and at most domain.MaxTagLength characters;
+//	           duplicates are removed, keeping the first
+//
+// A nil pointer field is treated as absent and skips all of its rules, which
+// is what partial updates need; a non-nil pointer is checked through.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+)
+
+type rule struct {
+	name string
+	arg  int
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// fieldCache maps a struct type to its parsed []field.
+var fieldCache sync.Map
+
+// Struct normalizes and validates the struct v points to. It returns a
+// *domain.ValidationError, or nil when every rule holds.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: Struct needs a pointer to a struct, got %T", v))
+	}
+	rv = rv.Elem()
+
+	var errs []domain.FieldError
+	for _, f := range fieldsOf(rv.Type()) {
+		fv := rv.Field(f.index)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fe, ok := check(f, fv); !ok {
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return &domain.ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// check applies f's rules to v and returns the first violation.
+func check(f field, v reflect.Value) (domain.FieldError, bool) {
+	fail := func(code, format string, args ...any) (domain.FieldError, bool) {
+		return domain.FieldError{Field: f.name, Code: code, Message: f.name + " " + fmt.Sprintf(format, args...)}, false
+	}
+
+	for _, r := range f.rules {
+		switch r.name {
+		case "trim":
+			v.SetString(strings.Join(strings.Fields(v.String()), " "))
+		case "required":
+			if v.IsZero() {
+				return fail(domain.CodeRequired, "is required")
+			}
+		case "omitempty":
+			if v.IsZero() {
+				return domain.FieldError{}, true
+			}
+		case "min":
+			if v.Kind() == reflect.String {
+				if utf8.RuneCountInString(v.String()) < r.arg {
+					return fail(domain.CodeTooShort, "must be at least %d characters", r.arg)
+				}
+			} else if v.Int() < int64(r.arg) {
+				return fail(domain.CodeOutOfRange, "must be at least %d", r.arg)
+			}
+		case "max":
+			if v.Kind() == reflect.Slice {
+				if v.Len() > r.arg {
+					return fail(domain.CodeOutOfRange, "must have at most %d elements", r.arg)
+				}
+			} else if v.Kind() == reflect.String {
+				if utf8.RuneCountInString(v.String()) > r.arg {
+					return fail(domain.CodeTooLong, "must be at most %d characters", r.arg)
+				}
+			} else if v.Int() > int64(r.arg) {
+				return fail(domain.CodeOutOfRange, "must be at most %d", r.arg)
+			}
+		case "year":
+			if latest := time.Now().Year() + 1; v.Int() < domain.MinBookYear || v.Int() > int64(latest) {
+				return fail(domain.CodeOutOfRange, "must be between %d and %d", domain.MinBookYear, latest)
+			}
+		case "isbn":
+			isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(v.String()))
+			if !ValidISBN(isbn) {
+				return fail(domain.CodeInvalidFormat, "must be a valid ISBN-10 or ISBN-13")
+			}
+			v.SetString(isbn)
+		case "tags":
+			tags, code, msg := normalizeTags(v.Interface().([]string))
+			if code != "" {
+				return fail(code, "%s", msg)
+			}
+			v.Set(reflect.ValueOf(tags))
+		}
+	}
+	return domain.FieldError{}, true
+}
+
+// normalizeTags returns tags trimmed, lowered and without duplicates, or the
+// code and message of the first invalid tag.
+func normalizeTags(tags []string) (out []string, code, msg string) {
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		switch {
+		case tag == "":
+			return nil, domain.CodeRequired, "must not contain empty tags"
+		case utf8.RuneCountInString(tag) > domain.MaxTagLength:
+			return nil, domain.CodeTooLong, fmt.Sprintf("must each be at most %d characters", domain.MaxTagLength)
+		case !seen[tag]:
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out, "", ""
+}
+
+// ValidISBN reports whether s, without separators, is an ISBN-10 or ISBN-13
+// with a correct check digit.
+func ValidISBN(s string) bool {
+	switch len(s) {
+	case 10:
+		sum := 0
+		for i := 0; i < 10; i++ {
+			var d int
+			switch c := s[i]; {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += d * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i := 0; i < 13; i++ {
+			c := s[i]
+			if c < '0' || c > '9' {
+				return false
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = sf.Name
+		}
+		f := field{index: i, name: name}
+		kind := sf.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = sf.Type.Elem().Kind()
+		}
+		for _, spec := range strings.Split(tag, ",") {
+			r := parseRule(t, sf, spec)
+			if !ruleApplies(r.name, kind) {
+				panic(fmt.Sprintf("validation: rule %q does not apply to %s.%s (%s)", r.name, t, sf.Name, kind))
+			}
+			f.rules = append(f.rules, r)
+		}
+		fields = append(fields, f)
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func parseRule(t reflect.Type, sf reflect.StructField, spec string) rule {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(spec), "=")
+	r := rule{name: name}
+	switch name {
+	case "min", "max":
+		n, err := strconv.Atoi(arg)
+		if !hasArg || err != nil {
+			panic(fmt.Sprintf("validation: %s.%s: %s needs an integer argument", t, sf.Name, name))
+		}
+		r.arg = n
+	case "trim", "required", "omitempty", "year", "isbn", "tags":
+		if hasArg {
+			panic(fmt.Sprintf("validation: %s.%s: %s takes no argument", t, sf.Name, name))
+		}
+	default:
+		panic(fmt.Sprintf("validation: %s.%s: unknown rule %q", t, sf.Name, name))
+	}
+	return r
+}
+
+func ruleApplies(name string, kind reflect.Kind) bool {
+	isInt := kind >= reflect.Int && kind <= reflect.Int64
+	switch name {
+	case "trim", "isbn":
+		return kind == reflect.String
+	case "min":
+		return kind == reflect.String || isInt
+	case "max":
+		return kind == reflect.String || isInt || kind == reflect.Slice
+	case "year":
+		return isInt
+	case "tags":
+		return kind == reflect.Slice
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package validation_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/andrimuhayat/crud-test/internal/domain"
+	"github.com/andrimuhayat/crud-test/internal/validation"
+)
+
+func codes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var invalid *domain.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a *domain.ValidationError", err)
+	}
+	if !errors.Is(err, domain.ErrInvalidData) {
+		t.Error("ValidationError does not match ErrInvalidData")
+	}
+	got := make(map[string]string)
+	for _, f := range invalid.Fields {
+		got[f.Field] = f.Code
+	}
+	return got
+}
+
+// TestBookReportsEveryViolation verifies all invalid fields are reported in
+// one error, each with its own code.
+func TestBookReportsEveryViolation(t *testing.T) {
+	book := &domain.Book{
+		Title:  "   ",
+		Author: string(make([]rune, 201)),
+		Year:   99999,
+		ISBN:   "978-0-441-17271-0",
+	}
+	want := map[string]string{
+		"title":  domain.CodeRequired,
+		"author": domain.CodeTooLong,
+		"year":   domain.CodeOutOfRange,
+		"isbn":   domain.CodeInvalidFormat,
+	}
+	if got := codes(t, validation.Struct(book)); !reflect.DeepEqual(got, want) {
+		t.Errorf("codes: got %v, want %v", got, want)
+	}
+
+	book.Year = -1
+	if got := codes(t, validation.Struct(book))["year"]; got != domain.CodeOutOfRange {
+		t.Errorf("negative year: got %q", got)
+	}
+}
+
+// TestBookNormalizes verifies whitespace is collapsed and ISBNs are stored
+// without separators, and that zero optional fields pass.
+func TestBookNormalizes(t *testing.T) {
+	book := &domain.Book{Title: "  The   Left Hand\tof Darkness ", Author: "Ursula K. Le Guin", ISBN: "0-8044-2957-x"}
+	if err := validation.Struct(book); err != nil {
+		t.Fatalf("Struct: %v", err)
+	}
+	if book.Title != "The Left Hand of Darkness" || book.ISBN != "080442957X" {
+		t.Errorf("normalized: title %q, isbn %q", book.Title, book.ISBN)
+	}
+
+	for _, isbn := range []string{"9780441172719", "978 0 441 17271 9", "0441172717"} {
+		b := &domain.Book{Title: "Dune", Author: "Frank Herbert", ISBN: isbn}
+		if err := validation.Struct(b); err != nil {
+			t.Errorf("ISBN %q: %v", isbn, err)
+		}
+	}
+}
+
+// TestNilPointersAreAbsent verifies partial-update structs only validate the
+// fields that are present.
+func TestNilPointersAreAbsent(t *testing.T) {
+	type patch struct {
+		Title *string `json:"title" validate:"trim,required,max=5"`
+		Year  *int    `json:"year" validate:"omitempty,min=1,max=10"`
+	}
+	if err := validation.Struct(&patch{}); err != nil {
+		t.Errorf("empty patch: %v", err)
+	}
+
+	title, year := "  ", 11
+	p := &patch{Title: &title, Year: &year}
+	want := map[string]string{"title": domain.CodeRequired, "year": domain.CodeOutOfRange}
+	if got := codes(t, validation.Struct(p)); !reflect.DeepEqual(got, want) {
+		t.Errorf("codes: got %v, want %v", got, want)
+	}
+	if title != "" {
+		t.Errorf("title not trimmed through the pointer: %q", title)
+	}
+}
+
+// TestBookTags verifies tags are trimmed, lowered and deduplicated, and that
+// empty or overlong tags and too many tags are rejected.
+func TestBookTags(t *testing.T) {
+	book := &domain.Book{Title: "Dune", Author: "Frank Herbert", Tags: []string{" Science  Fiction", "classic", "CLASSIC"}}
+	if err := validation.Struct(book); err != nil {
+		t.Fatalf("Struct: %v", err)
+	}
+	if want := []string{"science fiction", "classic"}; !reflect.DeepEqual(book.Tags, want) {
+		t.Errorf("tags: got %q, want %q", book.Tags, want)
+	}
+
+	many := make([]string, 21)
+	for i := range many {
+		many[i] = string(rune('a' + i))
+	}
+	cases := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"classic", " "}, domain.CodeRequired},
+		{[]string{string(make([]rune, domain.MaxTagLength+1))}, domain.CodeTooLong},
+		{many, domain.CodeOutOfRange},
+	}
+	for _, tc := range cases {
+		b := &domain.Book{Title: "Dune", Author: "Frank Herbert", Tags: tc.tags}
+		if got := codes(t, validation.Struct(b))["tags"]; got != tc.want {
+			t.Errorf("tags %q: got %q, want %q", tc.tags, got, tc.want)
+		}
+	}
+}